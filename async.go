@@ -0,0 +1,144 @@
+package slice_on_disk
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// writer writes the spilled elements in the background.
+// The operations on an id always go to the same worker,
+// so they are applied in the order they were issued.
+type writer[T any] struct {
+	s       *storage.Storage
+	mu      sync.Mutex
+	seq     uint64
+	pending map[int]pendingWrite[T]
+	queues  []chan writeOp
+	wg      sync.WaitGroup
+	err     error
+	// see WithTracer
+	tracer Tracer
+}
+
+type pendingWrite[T any] struct {
+	t   T
+	seq uint64
+}
+
+type writeOp struct {
+	id     int
+	seq    uint64
+	remove bool
+}
+
+func newWriter[T any](s *storage.Storage, workers int) *writer[T] {
+	w := &writer[T]{
+		s:       s,
+		pending: make(map[int]pendingWrite[T]),
+		queues:  make([]chan writeOp, workers),
+	}
+	for i := range w.queues {
+		w.queues[i] = make(chan writeOp, 1024)
+		go w.work(w.queues[i])
+	}
+	return w
+}
+
+func (w *writer[T]) work(ch chan writeOp) {
+	for op := range ch {
+		if op.remove {
+			// the element may have been removed before it was ever written
+			var err error
+			if w.tracer != nil {
+				err = trace(w.tracer, w.s, "delete", -1, op.id, func() error {
+					return w.s.Delete(op.id)
+				})
+			} else {
+				err = w.s.Delete(op.id)
+			}
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("error removing file %s: %s", w.s.Path(op.id), err.Error())
+			}
+			w.wg.Done()
+			continue
+		}
+
+		w.mu.Lock()
+		p, ok := w.pending[op.id]
+		w.mu.Unlock()
+		// superseded by a later write of the same id
+		if !ok || p.seq != op.seq {
+			w.wg.Done()
+			continue
+		}
+
+		var err error
+		if w.tracer != nil {
+			err = trace(w.tracer, w.s, "write", -1, op.id, func() error {
+				return storage.Write(w.s, op.id, p.t)
+			})
+		} else {
+			err = storage.Write(w.s, op.id, p.t)
+		}
+		w.mu.Lock()
+		if err != nil {
+			// the element stays pending, so it can still be read
+			if w.err == nil {
+				w.err = err
+			}
+		} else if w.pending[op.id].seq == op.seq {
+			delete(w.pending, op.id)
+		}
+		w.mu.Unlock()
+		w.wg.Done()
+	}
+}
+
+func (w *writer[T]) write(id int, t T) {
+	w.mu.Lock()
+	w.seq++
+	seq := w.seq
+	w.pending[id] = pendingWrite[T]{t: t, seq: seq}
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	w.queues[id%len(w.queues)] <- writeOp{id: id, seq: seq}
+}
+
+// read returns the element if it is not on the disk yet
+func (w *writer[T]) read(id int) (T, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.pending[id]
+	return p.t, ok
+}
+
+func (w *writer[T]) remove(id int) {
+	w.mu.Lock()
+	delete(w.pending, id)
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	w.queues[id%len(w.queues)] <- writeOp{id: id, remove: true}
+}
+
+// sync waits for the queued operations and returns the first write error
+// since the previous sync
+func (w *writer[T]) sync() error {
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+func (w *writer[T]) stop() {
+	w.wg.Wait()
+	for _, ch := range w.queues {
+		close(ch)
+	}
+}
@@ -0,0 +1,65 @@
+package slice_on_disk
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+const backupVersion = 1
+
+// backupHeader starts the archive written by Backup. It is followed
+// by Len elements encoded in the same gob stream
+type backupHeader struct {
+	Version int
+	Len     int
+	Cap     int
+}
+
+func (c *config[T]) Backup(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+
+	e := gob.NewEncoder(w)
+	if err := e.Encode(backupHeader{Version: backupVersion, Len: c.len(), Cap: cap(c.slice)}); err != nil {
+		return err
+	}
+	return c.each(func(t T) error {
+		return e.Encode(t)
+	})
+}
+
+// Restore creates a Slicer from an archive written by Backup.
+// The in memory head gets the capacity of the original one.
+// rootPath and opts are the same as with New.
+func Restore[T any](r io.Reader, rootPath string, opts ...Option) (Slicer[T], error) {
+	d := gob.NewDecoder(r)
+	var h backupHeader
+	if err := d.Decode(&h); err != nil {
+		return nil, fmt.Errorf("could not read the backup header: %w", err)
+	}
+	if h.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", h.Version)
+	}
+
+	s, err := New(make([]T, 0, h.Cap), rootPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < h.Len; i++ {
+		var t T
+		if err := d.Decode(&t); err != nil {
+			s.Cleanup()
+			return nil, fmt.Errorf("could not read element %d of %d: %w", i, h.Len, err)
+		}
+		if err := s.Append(t); err != nil {
+			s.Cleanup()
+			return nil, err
+		}
+	}
+	return s, nil
+}
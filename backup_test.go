@@ -0,0 +1,47 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	s.Put(42, -42)
+
+	var buf bytes.Buffer
+	if err := s.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Restore[int](bytes.NewReader(buf.Bytes()), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Cleanup()
+
+	rc, _ := r.(*config[int])
+	if r.Len() != 100 || cap(rc.slice) != 10 {
+		t.Errorf("Len() = %d, cap = %d, want 100, 10", r.Len(), cap(rc.slice))
+	}
+	x, err := r.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range x {
+		want := i
+		if i == 42 {
+			want = -42
+		}
+		if x[i] != want {
+			t.Errorf("element %d: %d, want %d", i, x[i], want)
+		}
+	}
+
+	// a truncated archive is an error
+	if _, err := Restore[int](bytes.NewReader(buf.Bytes()[:buf.Len()/2]), os.TempDir()); err == nil {
+		t.Errorf("Restore() of a truncated archive succeeded")
+	}
+}
@@ -0,0 +1,488 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+func (c *config[T]) GetBatch(indices ...int) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	return c.getBatch(indices)
+}
+
+func (c *config[T]) getBatch(indices []int) ([]T, error) {
+	retval := make([]T, len(indices))
+	// the positions in retval of every disk id, so each file is read once
+	positions := make(map[int][]int)
+	for k, i := range indices {
+		if i < 0 || i >= c.len() {
+			return nil, IndexOutOfBounds
+		}
+		if i < len(c.slice) {
+			retval[k] = c.slice[i]
+			continue
+		}
+		id := c.diskSlice[i-len(c.slice)]
+		positions[id] = append(positions[id], k)
+	}
+
+	// in the file order, which is usually the order on the disk
+	ids := make([]int, 0, len(positions))
+	for id := range positions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	values := make([]T, len(ids))
+	if err := c.readInto(values, ids); err != nil {
+		return nil, err
+	}
+	for n, id := range ids {
+		for _, k := range positions[id] {
+			retval[k] = values[n]
+		}
+	}
+	return retval, nil
+}
+
+func (c *config[T]) PutBatch(start int, elements []T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+	if start < 0 || start+len(elements) > c.len() {
+		return IndexOutOfBounds
+	}
+
+	// the head part
+	n := 0
+	if start < len(c.slice) {
+		n = copy(c.slice[start:], elements)
+	}
+	// the disk part, each file is written once
+	for k := n; k < len(elements); k++ {
+		d := start + k - len(c.slice)
+		id, err := c.overwrite(c.diskSlice[d], elements[k])
+		if err != nil {
+			return err
+		}
+		c.diskSlice[d] = id
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Fill(v T, start, end int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+	if start < 0 || start > end || end > c.len() {
+		return IndexOutOfBounds
+	}
+
+	h := len(c.slice)
+	for i := start; i < min(end, h); i++ {
+		c.slice[i] = v
+	}
+	if end <= h {
+		return c.persist()
+	}
+	ids := c.diskSlice[max(start-h, 0) : end-h]
+	if c.async != nil || c.durable != nil {
+		// queued as is, there is nothing to encode, or kept for WithWAL
+		for k, id := range ids {
+			id, err := c.overwrite(id, v)
+			if err != nil {
+				return err
+			}
+			ids[k] = id
+		}
+		return c.persist()
+	}
+	for _, id := range ids {
+		c.refresh(id, v)
+	}
+	if err := storage.WriteAll(c.Storage, ids, v); err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+func (c *config[T]) DeleteRanges(ranges ...[2]int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+
+	// as [start, end) sorted by start, overlapping ones merged
+	spans := make([][2]int, 0, len(ranges))
+	for _, r := range ranges {
+		start, n := r[0], r[1]
+		if start < 0 || n < 0 || n > c.len()-start {
+			return fmt.Errorf("invalid parameters start=%d, todelete=%d for the slice of length %d", start, n, c.len())
+		}
+		if n > 0 {
+			spans = append(spans, [2]int{start, start + n})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	slices.SortFunc(spans, func(a, b [2]int) int { return a[0] - b[0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] <= last[1] {
+			last[1] = max(last[1], s[1])
+			continue
+		}
+		merged = append(merged, s)
+	}
+	if c.appendOnly && (len(merged) > 1 || merged[0][0] != 0) {
+		return ErrAppendOnly
+	}
+	return c.deleteSpans(merged)
+}
+
+// deleteSpans removes the elements of the spans, given as [start, end)
+// sorted by start and not overlapping, in one compaction pass
+func (c *config[T]) deleteSpans(merged [][2]int) error {
+	if len(merged) == 0 {
+		return nil
+	}
+	c.forget()
+	n := 0
+	for _, s := range merged {
+		n += s[1] - s[0]
+	}
+	c.hooks.deleted(n)
+	r := 0
+	deleted := func(i int) bool {
+		for r < len(merged) && merged[r][1] <= i {
+			r++
+		}
+		return r < len(merged) && merged[r][0] <= i
+	}
+
+	// one pass keeping the survivors, starting at the first deleted position
+	first, h := merged[0][0], len(c.slice)
+	if c.ttl > 0 {
+		w := first
+		for i := first; i < len(c.born); i++ {
+			if !deleted(i) {
+				c.born[w] = c.born[i]
+				w++
+			}
+		}
+		c.born = c.born[:w]
+		r = 0
+	}
+	w := first
+	for i := first; i < h; i++ {
+		if !deleted(i) {
+			c.slice[w] = c.slice[i]
+			w++
+		}
+	}
+	if w < h {
+		clear(c.slice[w:h])
+		c.slice = c.slice[:w]
+	}
+	w = max(first-h, 0)
+	var dropped []int
+	for i := w; i < len(c.diskSlice); i++ {
+		if deleted(i + h) {
+			dropped = append(dropped, c.diskSlice[i])
+			continue
+		}
+		c.diskSlice[w] = c.diskSlice[i]
+		w++
+	}
+	c.diskSlice = c.diskSlice[:w]
+	c.remove(dropped...)
+
+	if err := c.refill(); err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+// Batch stages Append, Put and Delete calls on a Slicer, see Slicer.Batch.
+// The indices refer to the Slicer as changed by the previous staged calls,
+// as if they were made one after the other. Nothing is checked or written
+// until Commit
+type Batch[T any] interface {
+	Append(elements ...T)
+	Put(index int, element T)
+	Delete(start, count int)
+	// Commit applies the staged calls all at once: if one of them is invalid
+	// or a disk write fails, the Slicer is left unchanged. The batch is
+	// empty afterwards either way and can be reused
+	Commit() error
+	// Rollback drops the staged calls
+	Rollback()
+}
+
+const (
+	opAppend = iota
+	opPut
+	opDelete
+)
+
+type op[T any] struct {
+	kind     int
+	start, n int
+	values   []T
+}
+
+type batch[T any] struct {
+	c   *config[T]
+	mu  sync.Mutex
+	ops []op[T]
+}
+
+func (c *config[T]) Batch() Batch[T] {
+	return &batch[T]{c: c}
+}
+
+func (b *batch[T]) stage(o op[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ops = append(b.ops, o)
+}
+
+func (b *batch[T]) Append(elements ...T) {
+	b.stage(op[T]{kind: opAppend, values: slices.Clone(elements)})
+}
+
+func (b *batch[T]) Put(index int, element T) {
+	b.stage(op[T]{kind: opPut, start: index, values: []T{element}})
+}
+
+func (b *batch[T]) Delete(start, count int) {
+	b.stage(op[T]{kind: opDelete, start: start, n: count})
+}
+
+func (b *batch[T]) Rollback() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ops = nil
+}
+
+func (b *batch[T]) Commit() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ops := b.ops
+	b.ops = nil
+
+	c := b.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	return c.logged(ops, func() error {
+		return c.commit(ops)
+	})
+}
+
+// commit applies ops to a plan of the result first, see rebuild,
+// ^k standing for the k-th staged value
+func (c *config[T]) commit(ops []op[T]) error {
+	plan := make([]int, c.len())
+	for p := range plan {
+		plan[p] = p
+	}
+	var values []T
+	appended, deleted := 0, 0
+	for _, o := range ops {
+		switch o.kind {
+		case opAppend:
+			for _, v := range o.values {
+				plan = append(plan, ^len(values))
+				values = append(values, v)
+			}
+			appended += len(o.values)
+		case opPut:
+			if c.appendOnly {
+				return ErrAppendOnly
+			}
+			if o.start < 0 || o.start >= len(plan) {
+				return IndexOutOfBounds
+			}
+			plan[o.start] = ^len(values)
+			values = append(values, o.values[0])
+		case opDelete:
+			if c.appendOnly && o.start != 0 {
+				return ErrAppendOnly
+			}
+			if o.start < 0 || o.n < 0 || o.start+o.n > len(plan) {
+				return fmt.Errorf("invalid parameters start=%d, todelete=%d for the slice of length %d", o.start, o.n, len(plan))
+			}
+			plan = slices.Delete(plan, o.start, o.start+o.n)
+			deleted += o.n
+		}
+	}
+	if over := len(plan) - c.maxLen; c.maxLen > 0 && over > 0 {
+		plan = plan[over:]
+		deleted += over
+	}
+
+	now := time.Now()
+	staged := make([]ref[T], len(values))
+	for k, v := range values {
+		staged[k] = ref[T]{v: v, id: -1, born: now}
+	}
+	written, err := c.rebuild(plan, staged)
+	if err != nil {
+		return err
+	}
+	c.forget()
+	c.hooks.deleted(deleted)
+	c.hooks.appended(appended)
+	c.hooks.spilled(written)
+	if appended > 0 {
+		c.wake()
+	}
+	return c.persist()
+}
+
+// ref is an element out of the Slicer: its value, or the disk file
+// holding it if id >= 0, and its append time with a TTL
+type ref[T any] struct {
+	v    T
+	id   int
+	born time.Time
+}
+
+// rebuild replaces the elements with the plan: every position holds the
+// current position of its element, or ^k for extra[k]. The values going
+// to the disk are written to new files, the existing files are never
+// overwritten: until the manifest is saved with the new disk tail,
+// the disk holds the Slicer as it was. Returns the number of files written
+func (c *config[T]) rebuild(plan []int, extra []ref[T]) (int, error) {
+	h := len(c.slice)
+	head := make([]T, min(cap(c.slice), len(plan)))
+	// the files of the extra elements moving to the head
+	var reclaimed []int
+	for i, p := range plan[:len(head)] {
+		switch {
+		case p < 0 && extra[^p].id < 0:
+			head[i] = extra[^p].v
+		case p >= 0 && p < h:
+			head[i] = c.slice[p]
+		default:
+			var id int
+			if p >= 0 {
+				id = c.diskSlice[p-h]
+			} else {
+				id = extra[^p].id
+				reclaimed = append(reclaimed, id)
+			}
+			t, err := c.read(id)
+			if err != nil {
+				return 0, fmt.Errorf(GetError, err)
+			}
+			head[i] = t
+		}
+	}
+
+	disk := make([]int, 0, len(plan)-len(head))
+	kept := make([]bool, len(c.diskSlice))
+	var written []int
+	for _, p := range plan[len(head):] {
+		if p >= h {
+			disk = append(disk, c.diskSlice[p-h])
+			kept[p-h] = true
+			continue
+		}
+		var t T
+		if p >= 0 {
+			t = c.slice[p]
+		} else if r := extra[^p]; r.id >= 0 {
+			disk = append(disk, r.id)
+			continue
+		} else {
+			t = r.v
+		}
+		if err := c.room(); err != nil {
+			c.remove(written...)
+			return 0, err
+		}
+		id, err := c.newID()
+		if err != nil {
+			c.remove(written...)
+			return 0, err
+		}
+		if err := c.write(id, t); err != nil {
+			c.Release(id)
+			c.remove(written...)
+			return 0, err
+		}
+		written = append(written, id)
+		disk = append(disk, id)
+	}
+
+	// the switch
+	c.replanned(plan)
+	if c.ttl > 0 {
+		born := make([]time.Time, len(plan))
+		for i, p := range plan {
+			if p >= 0 {
+				born[i] = c.born[p]
+			} else {
+				born[i] = extra[^p].born
+			}
+		}
+		c.born = born
+		c.shuffled = true
+	}
+	var dropped []int
+	for k, id := range c.diskSlice {
+		if !kept[k] {
+			dropped = append(dropped, id)
+		}
+	}
+	c.slice = c.slice[:len(head)]
+	copy(c.slice, head)
+	clear(c.slice[len(head):cap(c.slice)])
+	c.diskSlice = disk
+	c.remove(dropped...)
+	c.remove(reclaimed...)
+	return len(written), nil
+}
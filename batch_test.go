@@ -0,0 +1,192 @@
+package slice_on_disk
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestGetBatch(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"sequential": nil,
+		"parallel":   {WithParallelReads(4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := New(make([]int, 0, 10), os.TempDir(), opts...)
+			defer s.Cleanup()
+			for i := 0; i < 100; i++ {
+				s.Append(i)
+			}
+			s.Reverse()
+
+			indices := []int{99, 3, 50, 50, 10, 0, 75}
+			x, err := s.GetBatch(indices...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, i := range indices {
+				if x[k] != 99-i {
+					t.Errorf("element %d: %d, want %d", i, x[k], 99-i)
+				}
+			}
+			if _, err := s.GetBatch(1, 100); err != IndexOutOfBounds {
+				t.Errorf("GetBatch(1, 100) = %v, want IndexOutOfBounds", err)
+			}
+		})
+	}
+}
+
+func TestPutBatch(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	// across the head and the disk
+	if err := s.PutBatch(5, []int{-5, -6, -7, -8, -9, -10, -11}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		want := i
+		if i >= 5 && i < 12 {
+			want = -i
+		}
+		if x, _ := s.Get(i); x != want {
+			t.Errorf("element %d: %d, want %d", i, x, want)
+		}
+	}
+	if err := s.PutBatch(98, []int{1, 2, 3}); err != IndexOutOfBounds {
+		t.Errorf("PutBatch past the end = %v, want IndexOutOfBounds", err)
+	}
+}
+
+func TestDeleteRanges(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if err := s.DeleteRanges([2]int{50, 10}, [2]int{2, 3}, [2]int{8, 5}, [2]int{55, 10}, [2]int{99, 1}); err != nil {
+		t.Fatal(err)
+	}
+	var want []int
+	for i := 0; i < 100; i++ {
+		if (i < 2 || i >= 5) && (i < 8 || i >= 13) && (i < 50 || i >= 65) && i != 99 {
+			want = append(want, i)
+		}
+	}
+	got, err := s.Slice(0, s.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := s.DeleteRanges([2]int{0, 1}, [2]int{70, 10}); err == nil {
+		t.Error("DeleteRanges past the end succeeded")
+	}
+}
+
+func TestFill(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"files":   nil,
+		"packed":  {WithPacking(1024)},
+		"chunked": {WithChunking(4)},
+		"async":   {WithAsyncWrites(2)},
+	} {
+		s, err := New(make([]int, 0, 10), os.TempDir(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			s.Append(i)
+		}
+		s.Pin(50)
+		if err := s.Fill(-1, 5, 60); err != nil {
+			t.Fatal(err)
+		}
+		want := seq(0, 100)
+		for i := 5; i < 60; i++ {
+			want[i] = -1
+		}
+		got, err := s.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+		if err := s.Verify(); err != nil {
+			t.Error(err)
+		}
+		if err := s.Fill(0, 60, 101); err != IndexOutOfBounds {
+			t.Errorf("Fill past the end = %v, want IndexOutOfBounds", err)
+		}
+		s.Cleanup()
+	}
+}
+
+func TestCommit(t *testing.T) {
+	s, _ := New(make([]int, 0, 10), os.TempDir(), WithManifest())
+	for i := 0; i < 100; i++ {
+		s.Append(i)
+	}
+
+	b := s.Batch()
+	b.Append(100, 101)
+	b.Put(50, -50)
+	b.Delete(3, 12)
+	b.Put(0, -1)
+	b.Put(89, -89)
+	// nothing happens before the Commit
+	if x, _ := s.Get(50); x != 50 || s.Len() != 100 {
+		t.Fatalf("staged calls applied before the Commit")
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := append(seq(0, 100), 100, 101)
+	want[50] = -50
+	want = slices.Delete(want, 3, 15)
+	want[0], want[89] = -1, -89
+	got, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if s.MemLen() != 10 {
+		t.Errorf("MemLen() = %d, want 10", s.MemLen())
+	}
+	if err := s.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	// an invalid call leaves the Slicer unchanged
+	b.Append(1, 2, 3)
+	b.Put(5, 5)
+	b.Delete(90, 10)
+	if err := b.Commit(); err == nil {
+		t.Error("Commit() with a Delete past the end succeeded")
+	}
+	got, _ = s.Slice()
+	if !slices.Equal(got, want) {
+		t.Errorf("after a failed Commit: %v, want %v", got, want)
+	}
+	b.Put(0, 0)
+	b.Rollback()
+	if err := b.Commit(); err != nil {
+		t.Error(err)
+	}
+	if x, _ := s.Get(0); x != -1 {
+		t.Errorf("element 0: %d after Rollback, want -1", x)
+	}
+
+	// the process "dies": the disk tail survives as committed
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	got, _ = o.Slice()
+	if !slices.Equal(got, want[10:]) {
+		t.Errorf("after Open: %v, want %v", got, want[10:])
+	}
+}
@@ -0,0 +1,197 @@
+package slice_on_disk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrClosed is returned by the calls waiting for elements and by the
+// Err of a Cursor when the Slicer is cleaned up
+var ErrClosed = errors.New("slicer is cleaned up")
+
+func (c *config[T]) ToChan(ctx context.Context, consume bool) (<-chan T, func() error) {
+	ch := make(chan T)
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+		if consume {
+			err = c.drain(ctx, ch)
+		} else {
+			err = c.stream(ctx, ch)
+		}
+	}()
+	return ch, func() error {
+		<-done
+		return err
+	}
+}
+
+// stream sends the elements of a snapshot to ch,
+// so the Slicer isn't locked while the receiver works
+func (c *config[T]) stream(ctx context.Context, ch chan<- T) error {
+	snap, err := c.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Cleanup()
+	return snap.ForEach(func(_ int, v T) error {
+		select {
+		case ch <- v:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// drain pops the elements to ch, waiting for new ones when it is empty,
+// until the ctx is done or the Slicer is cleaned up
+func (c *config[T]) drain(ctx context.Context, ch chan<- T) error {
+	for {
+		t, born, err := c.pop(ctx)
+		if err == ErrClosed {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case ch <- t:
+			continue
+		case <-ctx.Done():
+		case <-c.done:
+		}
+		// not delivered
+		c.mu.Lock()
+		err = c.unpop(t, born)
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+}
+
+func (c *config[T]) BlockingPopFront(ctx context.Context) (T, error) {
+	t, _, err := c.pop(ctx)
+	return t, err
+}
+
+// pop removes and returns the first element with its append time,
+// waiting for an Append if there is none
+func (c *config[T]) pop(ctx context.Context) (T, time.Time, error) {
+	var zero T
+	for {
+		c.mu.Lock()
+		if c.readOnly {
+			c.mu.Unlock()
+			return zero, time.Time{}, ErrReadOnly
+		}
+		if err := c.expire(); err != nil {
+			c.mu.Unlock()
+			return zero, time.Time{}, err
+		}
+		if c.len() > 0 {
+			var born time.Time
+			if c.ttl > 0 {
+				born = c.born[0]
+			}
+			t, err := c.get(0)
+			if err == nil {
+				c.track(opDelete, 0)
+				if err = c.del(0, 1); err == nil {
+					c.record(1)
+				}
+				c.untrack()
+			}
+			if err == nil {
+				err = c.persist()
+			}
+			c.mu.Unlock()
+			return t, born, err
+		}
+		if c.notify == nil {
+			c.notify = make(chan struct{})
+		}
+		notify := c.notify
+		c.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return zero, time.Time{}, ctx.Err()
+		case <-c.done:
+			return zero, time.Time{}, ErrClosed
+		}
+	}
+}
+
+// unpop puts back at the front the element t appended at born,
+// after it was popped but could not be delivered
+func (c *config[T]) unpop(t T, born time.Time) error {
+	select {
+	case <-c.done:
+		// cleaned up already, the element has nowhere to go
+		return nil
+	default:
+	}
+	c.forget()
+	if c.ttl > 0 {
+		c.born = slices.Insert(c.born, 0, born)
+	}
+	c.hooks.appended(1)
+	if cap(c.slice) > 0 && len(c.slice) < cap(c.slice) {
+		c.slice = slices.Insert(c.slice, 0, t)
+		return c.persist()
+	}
+
+	// the last element of the head, or t itself without a head,
+	// goes to the front of the disk tail
+	spilled := t
+	if cap(c.slice) > 0 {
+		spilled = c.slice[len(c.slice)-1]
+	}
+	id, err := c.newID()
+	if err != nil {
+		return err
+	}
+	if err := c.write(id, spilled); err != nil {
+		c.Release(id)
+		return err
+	}
+	c.diskSlice = slices.Insert(c.diskSlice, 0, id)
+	c.hooks.spilled(1)
+	if cap(c.slice) > 0 {
+		copy(c.slice[1:], c.slice[:len(c.slice)-1])
+		c.slice[0] = t
+	}
+	return c.persist()
+}
+
+// wake wakes up the pops waiting for an Append
+func (c *config[T]) wake() {
+	if c.notify != nil {
+		close(c.notify)
+		c.notify = nil
+	}
+}
+
+func (c *config[T]) AppendFromChan(ctx context.Context, ch <-chan T) error {
+	for {
+		select {
+		case t, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := c.Append(t); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
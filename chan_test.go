@@ -0,0 +1,123 @@
+package slice_on_disk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestToChan(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	ch, wait := s.ToChan(context.Background(), false)
+	var got []int
+	for v := range ch {
+		got = append(got, v)
+	}
+	if err := wait(); err != nil || !slices.Equal(got, seq(0, 100)) {
+		t.Errorf("ToChan() = %v, %v", got, err)
+	}
+
+	// consuming: the element in flight when the ctx is done is put back
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, wait = s.ToChan(ctx, true)
+	for i := 0; i < 3; i++ {
+		if v := <-ch; v != i {
+			t.Errorf("received %d, want %d", v, i)
+		}
+	}
+	cancel()
+	for range ch {
+	}
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("ToChan() = %v, want context.Canceled", err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(x, seq(3, 100)) {
+		t.Errorf("Slice() = %v after consuming 3", x)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChanBuffer(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	in := make(chan int)
+	go func() {
+		for i := 0; i < 200; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	appended := make(chan error)
+	go func() {
+		appended <- s.AppendFromChan(context.Background(), in)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, wait := s.ToChan(ctx, true)
+	for i := 0; i < 200; i++ {
+		if v := <-out; v != i {
+			t.Fatalf("received %d, want %d", v, i)
+		}
+	}
+	if err := <-appended; err != nil {
+		t.Fatal(err)
+	}
+	// waiting for more until Cleanup
+	s.Cleanup()
+	for range out {
+	}
+	cancel()
+	if err := wait(); err != nil {
+		t.Errorf("ToChan() = %v after Cleanup", err)
+	}
+}
+
+func TestBlockingPopFront(t *testing.T) {
+	s, err := New(make([]int, 0, 2), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	popped := make(chan int)
+	go func() {
+		for {
+			v, err := s.BlockingPopFront(context.Background())
+			if err != nil {
+				if !errors.Is(err, ErrClosed) {
+					t.Errorf("BlockingPopFront() = %v, want ErrClosed", err)
+				}
+				close(popped)
+				return
+			}
+			popped <- v
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		s.Append(i)
+		if v := <-popped; v != i {
+			t.Errorf("popped %d, want %d", v, i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.BlockingPopFront(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("BlockingPopFront() = %v, want context.Canceled", err)
+	}
+	s.Cleanup()
+	<-popped
+}
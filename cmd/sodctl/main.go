@@ -0,0 +1,211 @@
+// Command sodctl inspects the Slicer directories left on the disk:
+//
+//	sodctl list <rootPath>                     the Slicer directories in rootPath
+//	sodctl info <dir>                          the counts and sizes of a directory
+//	sodctl dump [-type name] <dir> <index>     an element of the disk tail as JSON
+//	sodctl verify <dir>                        the checksums of every element
+//	sodctl orphans [-age d] [-rm] <rootPath>   the directories left behind
+//
+// The directories must be created WithManifest. The index counts from the
+// front of the disk tail, which is what survives the process. dump decodes
+// the element as one of the types below, or prints its raw payload with
+// -type raw, the default
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	sod "github.com/yurizf/slice-on-disk"
+)
+
+// the types dump can decode the elements to
+var types = map[string]func(dir string, index int) (any, error){
+	"string":            get[string],
+	"int":               get[int],
+	"int64":             get[int64],
+	"float64":           get[float64],
+	"bool":              get[bool],
+	"bytes":             get[[]byte],
+	"strings":           get[[]string],
+	"map[string]string": get[map[string]string],
+	"map[string]any":    get[map[string]any],
+}
+
+func get[T any](dir string, index int) (any, error) {
+	s, err := sod.OpenReadOnly[T](nil, dir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Cleanup()
+	return s.Get(index)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	var err error
+	switch cmd {
+	case "list":
+		err = list(args)
+	case "info":
+		err = info(args)
+	case "dump":
+		err = dump(args)
+	case "verify":
+		err = verify(args)
+	case "orphans":
+		err = orphans(args)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "sodctl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sodctl list|info|dump|verify|orphans [flags] <path> [index], see go doc")
+	os.Exit(2)
+}
+
+// arg returns the only argument of a command
+func arg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("want one path")
+	}
+	return args[0], nil
+}
+
+func list(args []string) error {
+	root, err := arg(args)
+	if err != nil {
+		return err
+	}
+	dirs, err := sod.ScanOrphans(root, 0)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if !d.Manifest {
+			fmt.Printf("%s\t%s\tno manifest\n", d.Path, d.ModTime.Format(time.RFC3339))
+			continue
+		}
+		i, err := sod.Inspect(d.Path)
+		if err != nil {
+			fmt.Printf("%s\t%s\t%v\n", d.Path, d.ModTime.Format(time.RFC3339), err)
+			continue
+		}
+		fmt.Printf("%s\t%s\t%d elements\t%d bytes\n", d.Path, d.ModTime.Format(time.RFC3339), i.Len, i.Bytes)
+	}
+	return nil
+}
+
+func info(args []string) error {
+	dir, err := arg(args)
+	if err != nil {
+		return err
+	}
+	i, err := sod.Inspect(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("elements: %d\nbytes:    %d\npacked:   %d\nchunked:  %d\nshards:   %d\n", i.Len, i.Bytes, i.Packed, i.Chunked, i.Shards)
+	fmt.Printf("version:  %d\ncodec:    %s\n", i.Version, i.Codec)
+	for _, r := range i.Roots {
+		fmt.Printf("root:     %s\n", r)
+	}
+	return nil
+}
+
+func dump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	typ := fs.String("type", "raw", "the type of the elements: raw or one of "+names())
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("want a directory and an index")
+	}
+	dir := fs.Arg(0)
+	index, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *typ == "raw" {
+		b, codec, err := sod.RawElement(dir, index)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("codec: %s, %d bytes\n%s", codec, len(b), hex.Dump(b))
+		return nil
+	}
+	fn, ok := types[*typ]
+	if !ok {
+		return fmt.Errorf("unknown type %s, want raw or one of %s", *typ, names())
+	}
+	v, err := fn(dir, index)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func names() string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprint(names)
+}
+
+func verify(args []string) error {
+	dir, err := arg(args)
+	if err != nil {
+		return err
+	}
+	n, err := sod.VerifyDir(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d elements ok\n", n)
+	return nil
+}
+
+func orphans(args []string) error {
+	fs := flag.NewFlagSet("orphans", flag.ExitOnError)
+	age := fs.Duration("age", 24*time.Hour, "the time since the last change")
+	rm := fs.Bool("rm", false, "remove the directories")
+	fs.Parse(args)
+	root, err := arg(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *rm {
+		removed, err := sod.RemoveOrphans(root, *age)
+		for _, path := range removed {
+			fmt.Println("removed", path)
+		}
+		return err
+	}
+	found, err := sod.ScanOrphans(root, *age)
+	if err != nil {
+		return err
+	}
+	for _, o := range found {
+		fmt.Printf("%s\t%s\tmanifest: %t\n", o.Path, o.ModTime.Format(time.RFC3339), o.Manifest)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	sod "github.com/yurizf/slice-on-disk"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestVerify(t *testing.T) {
+	// the elements are gob encoded
+	s, err := sod.New(make([]point, 0, 2), os.TempDir(), sod.WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	for i := 0; i < 10; i++ {
+		s.Append(point{i, -i})
+	}
+	if err := verify([]string{s.Dir()}); err != nil {
+		t.Errorf("verify() = %v", err)
+	}
+	if err := info([]string{s.Dir()}); err != nil {
+		t.Errorf("info() = %v", err)
+	}
+	if err := verify([]string{t.TempDir()}); err == nil {
+		t.Error("verify() of a directory without a manifest succeeded")
+	}
+}
@@ -0,0 +1,347 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// the suffix of the files being renumbered by Compact
+const compactSuffix = ".compact"
+
+// fileIDs returns the ids of the files of the disk tail, followed by the
+// copies of the head written by Flush, the ones kept for the checkpoint
+// of WithWAL, for the journal, the soft deleted elements and the
+// previous versions
+func (c *config[T]) fileIDs() []int {
+	ids := c.diskSlice
+	if len(c.journal) == 0 && len(c.hidden) == 0 && len(c.past) == 0 && len(c.flushed) == 0 && len(c.retained) == 0 {
+		return ids
+	}
+	ids = append(append(slices.Clone(ids), c.flushed...), c.retained...)
+	for _, ch := range append(slices.Clone(c.journal), c.hidden...) {
+		for _, r := range ch.removed {
+			if r.id >= 0 {
+				ids = append(ids, r.id)
+			}
+		}
+	}
+	for _, past := range c.past {
+		ids = append(ids, past...)
+	}
+	return ids
+}
+
+// liveFiles returns the names of the files of ids, the block files
+// and the manifest
+func (c *config[T]) liveFiles(ids []int) map[string]bool {
+	live := make(map[string]bool, len(ids)+1)
+	for _, id := range ids {
+		for _, name := range c.Names(id) {
+			live[name] = true
+		}
+	}
+	live[filepath.Join(c.RootPath, manifestName)] = true
+	live[filepath.Join(c.RootPath, walName)] = true
+	live[filepath.Join(c.RootPath, manifestLogName)] = true
+	for _, b := range c.Blocks() {
+		live[b] = true
+	}
+	return live
+}
+
+func (c *config[T]) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compact()
+}
+
+func (c *config[T]) compact() error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	// the readers attached with OpenReadOnly refer to the current numbering
+	lock, err := c.Flock(storage.AttachLock, true, false)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	// the manifest of WithWAL, a checkpoint, must list the current state.
+	// First, as it queues the removals of the files it replaces
+	if c.wal {
+		if err := c.checkpoint(); err != nil {
+			return err
+		}
+	}
+	// the queued removals refer to the old numbering
+	if err := c.sync(); err != nil {
+		return err
+	}
+	c.Wait()
+
+	// the soft deleted elements, the ones of the journal and the previous
+	// versions keep their files, numbered after the tail
+	ids := c.fileIDs()
+	live := c.liveFiles(ids)
+	files, err := c.Files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !live[f] {
+			if err := os.Remove(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	renumbered := make(map[int]int, len(ids))
+	for i, id := range ids {
+		renumbered[id] = i
+	}
+	// the manifest records the renumbering before the first rename, so
+	// that Open finishes it after a crash
+	if err := c.renumbering(renumbered, false); err != nil {
+		return err
+	}
+
+	// two passes, so a new number never hits a file that isn't renamed yet.
+	// The packed elements have no file of their own
+	var from []string
+	for _, id := range ids {
+		from = append(from, c.Names(id)...)
+	}
+	temp := temporary(from)
+	if err := move(from, temp); err != nil {
+		return c.unrenumber(err)
+	}
+	if err := c.renumbering(renumbered, true); err != nil {
+		move(temp, from)
+		return c.unrenumber(err)
+	}
+	c.Renumber(renumbered)
+	var to []string
+	for i := range ids {
+		to = append(to, c.Names(i)...)
+	}
+	if err := move(temp, to); err != nil {
+		inverse := make(map[int]int, len(ids))
+		for i, id := range ids {
+			inverse[i] = id
+		}
+		c.Renumber(inverse)
+		move(temp, from)
+		return c.unrenumber(err)
+	}
+	for _, ids := range [][]int{c.flushed, c.retained} {
+		for k, id := range ids {
+			ids[k] = renumbered[id]
+		}
+	}
+	if m := c.Replica(); m != nil {
+		m.Move(renumbered)
+	}
+	pinned := make(map[int]T, len(c.pinned))
+	warm := make(map[int]T, len(c.warm))
+	for i, id := range ids {
+		if t, ok := c.pinned[id]; ok {
+			pinned[i] = t
+		}
+		if t, ok := c.warm[id]; ok {
+			warm[i] = t
+		}
+	}
+	for i := range ids {
+		ids[i] = i
+	}
+	copy(c.diskSlice, ids)
+	// the elements the journal of WithUndo restores keep their files too
+	for _, ch := range append(slices.Clone(c.journal), c.hidden...) {
+		for k, r := range ch.removed {
+			if r.id >= 0 {
+				ch.removed[k].id = renumbered[r.id]
+			}
+		}
+	}
+	if len(c.past) > 0 {
+		past := make(map[int][]int, len(c.past))
+		for id, ids := range c.past {
+			for k, old := range ids {
+				ids[k] = renumbered[old]
+			}
+			past[renumbered[id]] = ids
+		}
+		c.past = past
+	}
+	c.pinned, c.warm = pinned, warm
+	if len(c.legacy) > 0 {
+		legacy := make(map[int]int, len(c.legacy))
+		for id, v := range c.legacy {
+			legacy[renumbered[id]] = v
+		}
+		c.legacy = legacy
+	}
+	// the loads in flight refer to the old numbering
+	clear(c.warming)
+	c.diskIndex = int64(len(ids))
+	c.garbage = 0
+	if err := c.Recount(ids); err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+// renumbering writes the manifest with the renumbering in progress,
+// if WithManifest is set
+func (c *config[T]) renumbering(ids map[int]int, renamed bool) error {
+	if !c.manifest {
+		return nil
+	}
+	m := c.state()
+	m.Renumbered, m.Renamed = ids, renamed
+	return c.saveAll(m)
+}
+
+// unrenumber writes the manifest back without the renumbering of
+// a Compact that failed with err and was rolled back
+func (c *config[T]) unrenumber(err error) error {
+	if e := c.persist(); e != nil {
+		err = errors.Join(err, e)
+	}
+	return fmt.Errorf("could not compact: %w", err)
+}
+
+// resume finishes the renaming of a Compact interrupted by a crash and
+// renumbers the elements the manifest m lists
+func (c *config[T]) resume(m *manifest) error {
+	listed := append(slices.Clone(m.Head), m.DiskSlice...)
+	var from []string
+	for _, id := range listed {
+		from = append(from, c.Names(id)...)
+	}
+	c.Renumber(m.Renumbered)
+	var to []string
+	for _, id := range listed {
+		to = append(to, c.Names(m.Renumbered[id])...)
+	}
+	// the files moved already are missing
+	temp := temporary(from)
+	if !m.Renamed {
+		if err := settle(from, temp); err != nil {
+			return err
+		}
+	}
+	if err := settle(temp, to); err != nil {
+		return err
+	}
+
+	for _, ids := range [][]int{m.Head, m.DiskSlice} {
+		for k, id := range ids {
+			ids[k] = m.Renumbered[id]
+		}
+	}
+	if len(c.legacy) > 0 {
+		legacy := make(map[int]int, len(c.legacy))
+		for id, v := range c.legacy {
+			legacy[m.Renumbered[id]] = v
+		}
+		c.legacy = legacy
+	}
+	c.diskIndex = int64(len(m.Renumbered))
+	return nil
+}
+
+// temporary returns the names the files are moved to by the first pass
+// of Compact
+func temporary(names []string) []string {
+	temp := make([]string, len(names))
+	for i, name := range names {
+		temp[i] = name + compactSuffix
+	}
+	return temp
+}
+
+// move renames the files from to the names to, renaming them back on
+// an error
+func move(from, to []string) error {
+	for i := range from {
+		if err := os.Rename(from[i], to[i]); err != nil {
+			for k := range i {
+				os.Rename(to[k], from[k])
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// settle is move skipping the missing files
+func settle(from, to []string) error {
+	for i := range from {
+		if err := os.Rename(from[i], to[i]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *config[T]) PauseCompaction() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+func (c *config[T]) ResumeCompaction() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+}
+
+// compactor checks the thresholds of WithAutoCompaction every interval
+func (c *config[T]) compactor() {
+	ticker := time.NewTicker(c.autoCompaction.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		select {
+		case <-c.done:
+			// Cleanup got the lock first
+			c.mu.Unlock()
+			return
+		default:
+		}
+		if !c.paused && c.needsCompaction() {
+			// ErrLocked: a reader is attached, next time maybe
+			if err := c.compact(); err != nil && !errors.Is(err, ErrLocked) {
+				log.Printf("error compacting %s: %s", c.RootPath, err.Error())
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *config[T]) needsCompaction() bool {
+	if c.garbage == 0 {
+		return false
+	}
+	a := c.autoCompaction
+	if a.GarbageRatio > 0 && float64(c.garbage) >= a.GarbageRatio*float64(c.garbage+len(c.diskSlice)) {
+		return true
+	}
+	return a.MaxFiles > 0 && c.diskIndex > int64(a.MaxFiles)
+}
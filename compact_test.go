@@ -0,0 +1,229 @@
+package slice_on_disk
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+func TestCompact(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	s.Delete(20, 30)
+	s.Reverse()
+	os.WriteFile(s.Dir()+"/999.1.tmp", []byte("leftover"), 0644)
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := s.(*config[int])
+	entries, _ := os.ReadDir(s.Dir())
+	var ids []int
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), storage.LockSuffix) {
+			continue
+		}
+		id, err := strconv.Atoi(e.Name())
+		if err != nil {
+			t.Errorf("unexpected file %s", e.Name())
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if len(ids) != 60 || ids[0] != 0 || ids[59] != 59 || c.diskIndex != 60 {
+		t.Errorf("files %v, diskIndex %d, want 0..59, 60", ids, c.diskIndex)
+	}
+
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(seq(0, 20), seq(50, 100)...)
+	for i := range x {
+		if x[len(x)-1-i] != want[i] {
+			t.Fatalf("element %d: %d, want %d", len(x)-1-i, x[len(x)-1-i], want[i])
+		}
+	}
+	s.Append(100)
+	if x, _ := s.Get(70); x != 100 {
+		t.Errorf("Get(70) = %d, want 100", x)
+	}
+}
+
+func TestAutoCompaction(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithAutoCompaction(AutoCompaction{
+		Interval:     time.Millisecond,
+		GarbageRatio: 0.5,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	c, _ := s.(*config[int])
+	compacted := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.garbage == 0 && c.diskIndex == int64(len(c.diskSlice))
+	}
+
+	for i := 0; i < 100; i++ {
+		s.Append(i)
+	}
+	s.PauseCompaction()
+	s.Delete(10, 60)
+	time.Sleep(20 * time.Millisecond)
+	if compacted() {
+		t.Errorf("compacted while paused")
+	}
+
+	s.ResumeCompaction()
+	for i := 0; i < 100 && !compacted(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if !compacted() {
+		t.Errorf("not compacted: garbage=%d, diskIndex=%d", c.garbage, c.diskIndex)
+	}
+	x, _ := s.Slice()
+	want := append(seq(0, 10), seq(70, 100)...)
+	for i := range want {
+		if x[i] != want[i] {
+			t.Fatalf("element %d: %d, want %d", i, x[i], want[i])
+		}
+	}
+}
+
+// compactState is a Slicer WithManifest after some churn, with the
+// renumbering Compact would apply
+func compactState(t *testing.T) (*config[int], []int, map[int]int) {
+	t.Helper()
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(seq(0, 40)...)
+	s.Delete(10, 10)
+	s.Reverse()
+	c := s.(*config[int])
+	c.Wait()
+	want, _ := s.Slice()
+	renumbered := make(map[int]int)
+	for i, id := range c.fileIDs() {
+		renumbered[id] = i
+	}
+	return c, want, renumbered
+}
+
+func TestCompactCrash(t *testing.T) {
+	// the head is not in the manifest
+	reopen := func(t *testing.T, c *config[int], want []int, renumbered bool) {
+		t.Helper()
+		want = want[5:]
+		c.Disown()
+		o, err := Open(make([]int, 0, 5), c.Dir(), WithStealLock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer o.Cleanup()
+		if x, err := o.Slice(); err != nil || !slices.Equal(x, want) {
+			t.Errorf("Slice() after Open = %v, %v, want %v", x, err, want)
+		}
+		if err := o.Validate(); err != nil {
+			t.Error(err)
+		}
+		if n := o.(*config[int]).diskIndex; renumbered && n != int64(len(want)) {
+			t.Errorf("diskIndex %d, want %d", n, len(want))
+		}
+	}
+
+	t.Run("first pass", func(t *testing.T) {
+		c, want, renumbered := compactState(t)
+		if err := c.renumbering(renumbered, false); err != nil {
+			t.Fatal(err)
+		}
+		// half the files moved to their temporary name
+		for _, id := range c.diskSlice[:len(c.diskSlice)/2] {
+			os.Rename(c.Path(id), c.Path(id)+compactSuffix)
+		}
+		reopen(t, c, want, true)
+	})
+
+	t.Run("second pass", func(t *testing.T) {
+		c, want, renumbered := compactState(t)
+		if err := c.renumbering(renumbered, true); err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range c.diskSlice {
+			os.Rename(c.Path(id), c.Path(id)+compactSuffix)
+		}
+		// half the files got their new name
+		for _, id := range c.diskSlice[:len(c.diskSlice)/2] {
+			os.Rename(c.Path(id)+compactSuffix, c.Path(renumbered[id]))
+		}
+		reopen(t, c, want, true)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		c, want, _ := compactState(t)
+		// a rename in the middle of the first pass fails
+		blocker := c.Path(c.diskSlice[10]) + compactSuffix
+		os.MkdirAll(filepath.Join(blocker, "x"), 0755)
+		if err := c.Compact(); err == nil {
+			t.Fatal("Compact() succeeded")
+		}
+		os.RemoveAll(blocker)
+		if x, err := c.Slice(); err != nil || !slices.Equal(x, want) {
+			t.Errorf("Slice() = %v, %v, want %v", x, err, want)
+		}
+		if err := c.Validate(); err != nil {
+			t.Error(err)
+		}
+		reopen(t, c, want, false)
+	})
+}
+
+func TestCompactWAL(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithWAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 38)...)
+	// a checkpoint copying the head, then the files it lists to keep
+	s.Reverse()
+	s.Reverse()
+	s.Delete(0, 30)
+	s.Put(0, 32)
+	s.Append(32)
+	want, _ := s.Slice()
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	c := s.(*config[int])
+	c.Wait()
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	if x, err := s.Slice(); err != nil || !slices.Equal(x, want) {
+		t.Errorf("Slice() after Compact = %v, %v, want %v", x, err, want)
+	}
+
+	// a crash right after
+	c.Disown()
+	o, err := Open(make([]int, 0, 5), s.Dir(), WithWAL(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	if x, err := o.Slice(); err != nil || !slices.Equal(x, want) {
+		t.Errorf("Slice() after Open = %v, %v, want %v", x, err, want)
+	}
+}
@@ -0,0 +1,91 @@
+package slice_on_disk
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (c *config[T]) AppendSlicer(other Slicer[T]) error {
+	// a snapshot of other, so the two Slicers are never locked together
+	// and other may be c itself
+	snap, err := other.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Cleanup()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+
+	src, ok := snap.(*config[T])
+	if !ok {
+		// the files of other implementations can't be adopted
+		err := snap.ForEach(func(_ int, v T) error {
+			_, err := c.append(context.Background(), []T{v})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		c.wake()
+		return c.persist()
+	}
+
+	if _, err := c.append(context.Background(), src.slice); err != nil {
+		return err
+	}
+	// the head takes the front of the tail while it has room
+	ids := src.diskSlice
+	n := min(cap(c.slice)-len(c.slice), len(ids))
+	for _, id := range ids[:n] {
+		t, err := src.read(id)
+		if err != nil {
+			return fmt.Errorf(GetError, err)
+		}
+		if _, err := c.append(context.Background(), []T{t}); err != nil {
+			return err
+		}
+	}
+
+	blocks := make(map[int]int)
+	now := time.Now()
+	imported := 0
+	defer func() {
+		c.hooks.spilled(imported)
+		c.hooks.appended(imported)
+	}()
+	for _, old := range ids[n:] {
+		if err := c.reserve(); err != nil {
+			return err
+		}
+		id, err := c.newID()
+		if err != nil {
+			return err
+		}
+		if err := c.Import(src.Storage, old, id, blocks); err != nil {
+			c.Release(id)
+			return err
+		}
+		c.diskSlice = append(c.diskSlice, id)
+		if c.ttl > 0 {
+			c.born = append(c.born, now)
+		}
+		imported++
+	}
+	c.wake()
+	if over := c.len() - c.maxLen; c.maxLen > 0 && over > 0 {
+		if err := c.del(0, over); err != nil {
+			return err
+		}
+	}
+	return c.persist()
+}
@@ -0,0 +1,68 @@
+package slice_on_disk
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAppendSlicer(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	other := intSlicer()
+	defer other.Cleanup()
+
+	if err := s.AppendSlicer(other); err != nil {
+		t.Fatal(err)
+	}
+	// itself
+	if err := s.AppendSlicer(s); err != nil {
+		t.Fatal(err)
+	}
+	want := append(seq(0, 100), seq(0, 100)...)
+	want = append(want, want...)
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(x, want) {
+		t.Errorf("Slice() = %v, want %v", x, want)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	// independent of other
+	other.Put(50, -1)
+	if x, _ := s.Get(150); x != 50 {
+		t.Errorf("Get(150) = %d after other.Put", x)
+	}
+
+	// the packed and chunked files are adopted too, into an empty head
+	big := strings.Repeat("x", 5000)
+	src, err := New(make([]string, 0, 1), os.TempDir(), WithPacking(100), WithChunking(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Cleanup()
+	src.Append("a", "b", big, "c", big)
+	dst, err := New(make([]string, 0, 2), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Cleanup()
+	if err := dst.AppendSlicer(src); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dst.Slice(); err != nil || !slices.Equal(got, []string{"a", "b", big, "c", big}) {
+		t.Errorf("Slice() = %d elements, %v", len(got), err)
+	}
+	o, err := Open(make([]string, 0, 2), dst.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	if err := o.Verify(); err != nil || o.Len() != 3 {
+		t.Errorf("Open(): %d elements, %v", o.Len(), err)
+	}
+}
@@ -0,0 +1,132 @@
+package slice_on_disk
+
+import "context"
+
+// Cursor iterates over the elements of a Slicer while other goroutines
+// change it. It follows its next element rather than an index: the
+// deletions before it, e.g. a consumer acking the prefix it processed
+// with Delete(0, n), and the removed elements restored before it move it
+// with that element. The calls reordering the elements, like Swap or
+// Rotate, leave it at its index. The elements appended meanwhile are
+// reached too, Next may be called again after it returned false.
+// A Cursor is not safe for concurrent use by several goroutines
+type Cursor[T any] struct {
+	c *config[T]
+	// the index of the next element, guarded by c.mu
+	pos   int
+	index int
+	value T
+	err   error
+}
+
+func (c *config[T]) Cursor() *Cursor[T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := &Cursor[T]{c: c, index: -1}
+	c.follow(k)
+	return k
+}
+
+// follow makes the changes of the Slicer move k
+func (c *config[T]) follow(k *Cursor[T]) {
+	if c.cursors == nil {
+		c.cursors = make(map[*Cursor[T]]struct{})
+	}
+	c.cursors[k] = struct{}{}
+}
+
+// Next moves to the next element, returning false at the end or on an
+// error, see Err
+func (k *Cursor[T]) Next() bool {
+	c := k.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k.err != nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		k.err = ErrClosed
+		return false
+	default:
+	}
+	if k.err = c.expire(); k.err != nil {
+		return false
+	}
+	if k.pos >= c.len() {
+		return false
+	}
+	k.value, k.err = c.getContext(context.Background(), k.pos)
+	if k.err != nil {
+		return false
+	}
+	k.index = k.pos
+	k.pos++
+	return true
+}
+
+// Value returns the element of the last Next
+func (k *Cursor[T]) Value() T {
+	return k.value
+}
+
+// Index returns the index the element of the last Next had then, -1
+// before the first one
+func (k *Cursor[T]) Index() int {
+	return k.index
+}
+
+// Err returns the error that stopped Next, nil at the end
+func (k *Cursor[T]) Err() error {
+	return k.err
+}
+
+// Close releases the cursor, Next returns false from then on and Err
+// ErrClosed
+func (k *Cursor[T]) Close() {
+	c := k.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.cursors, k)
+	if k.err == nil {
+		k.err = ErrClosed
+	}
+}
+
+// deleted moves the cursors past the n elements deleted from start
+func (c *config[T]) deleted(start, n int) {
+	for k := range c.cursors {
+		switch {
+		case k.pos >= start+n:
+			k.pos -= n
+		case k.pos > start:
+			k.pos = start
+		}
+	}
+}
+
+// replanned moves the cursors to the new position of their next element
+// in the plan of rebuild, or past the elements that were before it if it
+// is gone
+func (c *config[T]) replanned(plan []int) {
+	for k := range c.cursors {
+		pos := -1
+		last := -1
+		for i, p := range plan {
+			if p >= k.pos {
+				pos = i
+				break
+			}
+			if p >= 0 {
+				last = i
+			}
+		}
+		if pos < 0 {
+			pos = last + 1
+		}
+		k.pos = pos
+	}
+}
@@ -0,0 +1,92 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	k := s.Cursor()
+	defer k.Close()
+
+	var got []int
+	for i := 0; i < 20 && k.Next(); i++ {
+		got = append(got, k.Value())
+	}
+	// the acks of the processed prefix, and a deletion ahead
+	s.Delete(0, 15)
+	s.Delete(10, 5)
+	s.Append(100)
+	for k.Next() {
+		got = append(got, k.Value())
+	}
+	if err := k.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(seq(0, 25), seq(30, 100)...), 100)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Next() yielded %v, want %v", got, want)
+	}
+	if k.Index() != 80 {
+		t.Errorf("Index() = %d, want 80", k.Index())
+	}
+
+	// a Batch rebuilds the Slicer, the next element is found there
+	k = s.Cursor()
+	k.Next()
+	k.Next()
+	k.Next()
+	b := s.Batch()
+	b.Delete(0, 2)
+	b.Append(-1)
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if !k.Next() || k.Value() != 18 || k.Index() != 1 {
+		t.Errorf("Next() = %d at %d, want 18 at 1", k.Value(), k.Index())
+	}
+	k.Close()
+	if k.Next() || !errors.Is(k.Err(), ErrClosed) {
+		t.Errorf("Next() after Close() = %v, want ErrClosed", k.Err())
+	}
+}
+
+func TestCursorConcurrent(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 1000)...)
+
+	// the consumer acks what it processed while iterating
+	k := s.Cursor()
+	defer k.Close()
+	acks := make(chan int, 1000)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range acks {
+			s.Delete(0, 1)
+		}
+	}()
+	n := 0
+	for k.Next() {
+		if k.Value() != n {
+			t.Fatalf("Next() = %d, want %d", k.Value(), n)
+		}
+		n++
+		acks <- n
+	}
+	close(acks)
+	wg.Wait()
+	if n != 1000 || s.Len() != 0 {
+		t.Errorf("%d elements iterated, %d left, want 1000 and 0", n, s.Len())
+	}
+}
@@ -0,0 +1,101 @@
+package slice_on_disk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// ErrDiskFull is wrapped by the errors of Append when the volume
+// of the disk files is full, see WithDiskFullPolicy
+var ErrDiskFull = errors.New("disk full")
+
+// how often Append retries a write to a full volume with QuotaBlock
+const diskFullPoll = 100 * time.Millisecond
+
+// spill stores e past the head, making room according to the quota
+// and the disk full policies, and tells whether it went to the disk:
+// the policies that wait release the lock, so by then the head
+// may have room
+func (c *config[T]) spill(ctx context.Context, e T) (bool, error) {
+	for {
+		if err := c.reserve(); err != nil {
+			return false, err
+		}
+		if len(c.slice) < cap(c.slice) {
+			c.slice = append(c.slice, e)
+			return false, nil
+		}
+		id, err := c.newID()
+		if err != nil {
+			return false, err
+		}
+		err = c.writeNew(ctx, id, e)
+		if err == nil {
+			c.diskSlice = append(c.diskSlice, id)
+			return true, nil
+		}
+		if errors.Is(err, ErrTimeout) {
+			// id is released once the late write is done
+			return false, err
+		}
+		c.Release(id)
+		if !storage.DiskFull(err) {
+			return false, err
+		}
+		if err := c.makeRoom(fmt.Errorf("%w: %w", ErrDiskFull, err)); err != nil {
+			return false, err
+		}
+	}
+}
+
+// makeRoom acts on a write that failed with err, a full volume,
+// according to the disk full policy
+func (c *config[T]) makeRoom(err error) error {
+	switch c.diskFullPolicy {
+	case QuotaEvictOldest:
+		if len(c.diskSlice) == 0 {
+			return err
+		}
+		// the evicted files must go
+		c.forget()
+		if err := c.del(0, 1); err != nil {
+			return err
+		}
+		c.settle()
+		return nil
+	case QuotaBlock:
+		c.mu.Unlock()
+		select {
+		case <-time.After(diskFullPoll):
+		case <-c.done:
+		}
+		c.mu.Lock()
+		select {
+		case <-c.done:
+			return err
+		default:
+			return nil
+		}
+	}
+	return err
+}
+
+// unappend takes back the last n elements of an append that failed
+// on a full volume, so none of its elements is appended
+func (c *config[T]) unappend(n int) {
+	// the evictions may have taken some already
+	n = min(n, c.len())
+	if c.ttl > 0 {
+		c.born = c.born[:len(c.born)-n]
+	}
+	d := min(n, len(c.diskSlice))
+	c.remove(c.diskSlice[len(c.diskSlice)-d:]...)
+	c.diskSlice = c.diskSlice[:len(c.diskSlice)-d]
+	h := len(c.slice) - (n - d)
+	clear(c.slice[h:])
+	c.slice = c.slice[:h]
+}
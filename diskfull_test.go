@@ -0,0 +1,94 @@
+package slice_on_disk
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// the count down of the encodings of a full to the one that fails
+// like on a full volume, 0 for none
+var fullWrites atomic.Int32
+
+type full struct{ V int }
+
+func (f full) GobEncode() ([]byte, error) {
+	if fullWrites.Add(-1) == 0 {
+		return nil, &os.PathError{Op: "write", Path: "full", Err: syscall.ENOSPC}
+	}
+	return binary.AppendVarint(nil, int64(f.V)), nil
+}
+
+func (f *full) GobDecode(b []byte) error {
+	v, _ := binary.Varint(b)
+	f.V = int(v)
+	return nil
+}
+
+func fullSlicer(t *testing.T, policy QuotaPolicy) Slicer[full] {
+	t.Helper()
+	fullWrites.Store(0)
+	s, err := New(make([]full, 0, 2), os.TempDir(), WithDiskFullPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		s.Append(full{i})
+	}
+	return s
+}
+
+func TestDiskFull(t *testing.T) {
+	t.Run("fail", func(t *testing.T) {
+		s := fullSlicer(t, QuotaFail)
+		defer s.Cleanup()
+		// the second element fails: the first one is taken back
+		fullWrites.Store(2)
+		err := s.Append(full{4}, full{5}, full{6})
+		if !errors.Is(err, ErrDiskFull) || !errors.Is(err, syscall.ENOSPC) {
+			t.Errorf("Append() = %v, want ErrDiskFull", err)
+		}
+		if s.Len() != 4 {
+			t.Errorf("Len() = %d, want 4", s.Len())
+		}
+		if err := s.Validate(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		s := fullSlicer(t, QuotaEvictOldest)
+		defer s.Cleanup()
+		fullWrites.Store(1)
+		if err := s.Append(full{4}, full{5}); err != nil {
+			t.Fatal(err)
+		}
+		if s.Len() != 5 {
+			t.Errorf("Len() = %d, want 5", s.Len())
+		}
+		if x, _ := s.Get(0); x.V != 1 {
+			t.Errorf("Get(0) = %d, want 1", x.V)
+		}
+		// nothing to evict
+		s.Truncate(2)
+		fullWrites.Store(1)
+		if err := s.Append(full{6}); !errors.Is(err, ErrDiskFull) {
+			t.Errorf("Append() = %v, want ErrDiskFull", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		s := fullSlicer(t, QuotaBlock)
+		defer s.Cleanup()
+		fullWrites.Store(1)
+		if err := s.Append(full{4}); err != nil {
+			t.Fatal(err)
+		}
+		if x, _ := s.Get(4); s.Len() != 5 || x.V != 4 {
+			t.Errorf("Len() = %d, Get(4) = %d, want 5 and 4", s.Len(), x.V)
+		}
+	})
+}
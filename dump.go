@@ -0,0 +1,63 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// the length of the previews of Dump
+const previewLen = 60
+
+func (c *config[T]) Dump(w io.Writer, limit int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	n := c.len()
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	entries := c.Entries(c.diskSlice)
+	chunks := c.ChunkCounts(c.diskSlice)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "index\tlocation\tfile\tsize\tvalue")
+	for i := 0; i < n; i++ {
+		if i < len(c.slice) {
+			fmt.Fprintf(tw, "%d\tmem\t-\t-\t%s\n", i, preview(c.slice[i]))
+			continue
+		}
+		id := c.diskSlice[i-len(c.slice)]
+		file := filepath.Base(c.Path(id))
+		if e, ok := entries[id]; ok {
+			file = fmt.Sprintf("%s@%d", filepath.Base(c.BlockPath(e.Block)), e.Offset)
+		} else if k, ok := chunks[id]; ok {
+			file = fmt.Sprintf("%s (%d chunks)", filepath.Base(c.ChunkPath(id, 0)), k)
+		}
+		size, _ := c.Describe(id)
+		value := ""
+		if t, err := c.readAt(i, id); err != nil {
+			value = "error: " + err.Error()
+		} else {
+			value = preview(t)
+		}
+		fmt.Fprintf(tw, "%d\tdisk\t%s\t%d\t%s\n", i, file, size, value)
+	}
+	if n < c.len() {
+		fmt.Fprintf(tw, "...\t%d more\n", c.len()-n)
+	}
+	return tw.Flush()
+}
+
+// preview formats t with %v, cut to previewLen runes
+func preview[T any](t T) string {
+	r := []rune(fmt.Sprintf("%v", t))
+	if len(r) > previewLen {
+		return string(r[:previewLen-3]) + "..."
+	}
+	return string(r)
+}
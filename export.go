@@ -0,0 +1,197 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// each calls fn for every element in order, head first, then the disk tail
+func (c *config[T]) each(fn func(t T) error) error {
+	for _, t := range c.slice {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return c.readSeq(c.diskSlice, fn)
+}
+
+func (c *config[T]) ExportJSONL(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	e := json.NewEncoder(w)
+	return c.each(func(t T) error {
+		return e.Encode(t)
+	})
+}
+
+func (c *config[T]) ImportJSONL(r io.Reader) error {
+	d := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var t T
+		err := d.Decode(&t)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err = c.Append(t); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *config[T]) ExportCSV(w io.Writer, header []string, row func(t T) []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	err := c.each(func(t T) error {
+		return cw.Write(row(t))
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (c *config[T]) ImportCSV(r io.Reader, header bool, parse func(record []string) (T, error)) error {
+	cr := csv.NewReader(r)
+	if header {
+		if _, err := cr.Read(); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t, err := parse(record)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err = c.Append(t); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *config[T]) CopyTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return 0, err
+	}
+	// the queued writes are on the disk
+	if err := c.sync(); err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, t := range c.slice {
+		n, err := storage.WriteRecord(w, t)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, id := range c.diskSlice {
+		var n int64
+		var err error
+		if _, old := c.legacy[id]; old {
+			// the payload of another schema version, migrated on the way
+			var t T
+			if t, err = c.read(id); err == nil {
+				n, err = storage.WriteRecord(w, t)
+			}
+		} else {
+			n, err = c.CopyRecord(w, id)
+		}
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (c *config[T]) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	// the elements are encoded in turn, never all decoded at once
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	err := c.each(func(t T) error {
+		b, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+func (c *config[T]) UnmarshalJSON(data []byte) error {
+	// by convention, null leaves the value as it is
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	if !json.Valid(data) {
+		return errors.New("invalid JSON")
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := d.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("a JSON array expected, got %v", tok)
+	}
+	// staged, the data is in memory anyway: an invalid element leaves
+	// the Slicer as it is
+	b := c.Batch()
+	b.Delete(0, c.Len())
+	for n := 0; d.More(); n++ {
+		var t T
+		if err := d.Decode(&t); err != nil {
+			b.Rollback()
+			return fmt.Errorf("element %d: %w", n, err)
+		}
+		b.Append(t)
+	}
+	return b.Commit()
+}
@@ -0,0 +1,165 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestJSONL(t *testing.T) {
+	s, _ := New(make([]point, 0, 2), os.TempDir())
+	defer s.Cleanup()
+	for i := 0; i < 5; i++ {
+		s.Append(point{i, i * i})
+	}
+
+	var buf bytes.Buffer
+	if err := s.ExportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[3] != `{"X":3,"Y":9}` {
+		t.Errorf("ExportJSONL() = %q", buf.String())
+	}
+
+	r, _ := New(make([]point, 0, 2), os.TempDir())
+	defer r.Cleanup()
+	if err := r.ImportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", r.Len())
+	}
+	if p, _ := r.Get(4); p != (point{4, 16}) {
+		t.Errorf("Get(4) = %v, want {4 16}", p)
+	}
+
+	if err := r.ImportJSONL(strings.NewReader("{\"X\":1}\n{oops}\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ImportJSONL() = %v, want an error on line 2", err)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	s, _ := New(make([]point, 0, 2), os.TempDir())
+	defer s.Cleanup()
+	for i := 0; i < 5; i++ {
+		s.Append(point{i, -i})
+	}
+
+	var buf bytes.Buffer
+	err := s.ExportCSV(&buf, []string{"x", "y"}, func(p point) []string {
+		return []string{strconv.Itoa(p.X), strconv.Itoa(p.Y)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "x,y\n0,0\n1,-1\n") {
+		t.Errorf("ExportCSV() = %q", buf.String())
+	}
+
+	r, _ := New(make([]point, 0, 2), os.TempDir())
+	defer r.Cleanup()
+	err = r.ImportCSV(&buf, true, func(record []string) (point, error) {
+		x, err := strconv.Atoi(record[0])
+		if err != nil {
+			return point{}, err
+		}
+		y, err := strconv.Atoi(record[1])
+		return point{x, y}, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := r.Get(3); r.Len() != 5 || p != (point{3, -3}) {
+		t.Errorf("Len() = %d, Get(3) = %v, want 5, {3 -3}", r.Len(), p)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	values := []string{"a", strings.Repeat("b", 5000), "c", strings.Repeat("d", 300), "e"}
+	for name, opt := range map[string]Option{
+		"files":    WithDurability(DurabilityNone),
+		"packed":   WithPacking(100),
+		"chunked":  WithChunking(1024),
+		"async":    WithAsyncWrites(2),
+		"appended": WithAppendOnly(),
+	} {
+		s, err := New(make([]string, 0, 2), os.TempDir(), opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Append(values...)
+		var buf bytes.Buffer
+		n, err := s.CopyTo(&buf)
+		s.Cleanup()
+		if err != nil || n != int64(buf.Len()) {
+			t.Fatalf("%s: CopyTo() = %d, %v, %d bytes written", name, n, err, buf.Len())
+		}
+
+		r, err := NewFromReader(make([]string, 0, 2), os.TempDir(), &buf, FormatRecords)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		x, err := r.Slice()
+		r.Cleanup()
+		if err != nil || !slices.Equal(x, values) {
+			t.Errorf("%s: the copy holds %d elements, %v", name, len(x), err)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	type debug struct {
+		Name  string
+		Items Slicer[point]
+	}
+	s, err := New(make([]point, 0, 2), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(point{1, 2}, point{3, 4}, point{5, 6})
+	b, err := json.Marshal(debug{"backlog", s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"backlog","Items":[{"X":1,"Y":2},{"X":3,"Y":4},{"X":5,"Y":6}]}`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+
+	o, err := New(make([]point, 0, 1), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	o.Append(point{7, 8})
+	// the Slicer in the field is filled
+	d := debug{Items: o}
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := o.Slice(); d.Name != "backlog" || !slices.Equal(x, []point{{1, 2}, {3, 4}, {5, 6}}) {
+		t.Errorf("Unmarshal() = %s, %v", d.Name, x)
+	}
+	if err := o.UnmarshalJSON([]byte(`{"X":1}`)); err == nil {
+		t.Error("UnmarshalJSON() of an object succeeded")
+	}
+	if err := o.UnmarshalJSON([]byte(`null`)); err != nil || o.Len() != 3 {
+		t.Errorf("UnmarshalJSON(null) = %v, %d elements left", err, o.Len())
+	}
+	// an invalid element changes nothing
+	if err := o.UnmarshalJSON([]byte(`[{"X":9,"Y":9},"x"]`)); err == nil {
+		t.Error("UnmarshalJSON() of a string element succeeded")
+	}
+	if x, _ := o.Slice(); !slices.Equal(x, []point{{1, 2}, {3, 4}, {5, 6}}) {
+		t.Errorf("Slice() after a failed UnmarshalJSON = %v", x)
+	}
+}
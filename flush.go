@@ -0,0 +1,91 @@
+package slice_on_disk
+
+import (
+	"errors"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// ErrNoManifest is returned by Flush without WithManifest
+var ErrNoManifest = errors.New("slicer has no manifest, see WithManifest")
+
+func (c *config[T]) Flush() error {
+	c.mu.Lock()
+	err := c.flush()
+	c.mu.Unlock()
+	if err == nil && !c.serveAfterFlush {
+		c.Cleanup()
+	}
+	return err
+}
+
+// flush writes a checkpoint, then freezes the Slicer so the manifest
+// stays exact
+func (c *config[T]) flush() error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if !c.manifest {
+		return ErrNoManifest
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	if err := c.checkpoint(); err != nil {
+		return err
+	}
+	c.readOnly = true
+	// the reads must not expire the elements the manifest lists
+	c.ttl = 0
+	c.Keep()
+	return nil
+}
+
+// checkpoint writes copies of the head to new files and records them in
+// the manifest with the disk tail, then empties the log of WithWAL.
+// The copies of the previous checkpoint and the files it listed that
+// were removed since go once it is replaced
+func (c *config[T]) checkpoint() error {
+	// the manifest lists the disk tail as written
+	if err := c.sync(); err != nil {
+		return err
+	}
+	ids := make([]int, 0, len(c.slice))
+	for _, t := range c.slice {
+		id, err := c.newID()
+		if err == nil {
+			err = storage.Write(c.Storage, id, t)
+		}
+		if err != nil {
+			c.Remove(ids...)
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := c.Storage.Flush(); err != nil {
+		c.Remove(ids...)
+		return err
+	}
+	old := c.flushed
+	c.flushed = ids
+	if err := c.save(); err != nil {
+		c.flushed = old
+		c.Remove(ids...)
+		return err
+	}
+	c.Remove(old...)
+	if !c.wal {
+		return nil
+	}
+	c.Remove(c.retained...)
+	c.retained = nil
+	c.durable = make(map[int]bool, len(ids)+len(c.diskSlice))
+	for _, id := range append(ids, c.diskSlice...) {
+		c.durable[id] = true
+	}
+	c.walRecords, c.unlogged = 0, false
+	if c.walFile != nil {
+		return c.walFile.Reset()
+	}
+	return nil
+}
@@ -1,3 +1,3 @@
 module github.com/yurizf/slice-on-disk
 
-go 1.21
+go 1.23
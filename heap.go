@@ -0,0 +1,39 @@
+package slice_on_disk
+
+// Heap adapts a Slicer to heap.Interface, so the functions of
+// container/heap keep a heap of elements in the head and the disk tail
+// alike. Like with Sorter, the first error of the disk operations is
+// kept and reported by Err; after an error the heap order is undefined.
+type Heap[T any] struct {
+	*Sorter[T]
+}
+
+// HeapAdapter returns a heap.Interface over s ordered by less, the least
+// element first, e.g. heap.Init(HeapAdapter(s, less)) then heap.Push and
+// heap.Pop. It makes every element of s part of the heap, so s is best
+// not changed in the meantime but through it
+func HeapAdapter[T any](s Slicer[T], less func(a, b T) bool) *Heap[T] {
+	return &Heap[T]{SortAdapter(s, less)}
+}
+
+// Push appends x, which must be a T
+func (h *Heap[T]) Push(x any) {
+	if err := h.s.Append(x.(T)); err != nil {
+		h.fail(err)
+	}
+}
+
+// Pop removes the last element and returns it, the zero T on an error
+func (h *Heap[T]) Pop() any {
+	last := h.s.Len() - 1
+	t, err := h.s.Get(last)
+	if err == nil {
+		err = h.s.Truncate(last)
+	}
+	if err != nil {
+		h.fail(err)
+		var zero T
+		return zero
+	}
+	return t
+}
@@ -0,0 +1,35 @@
+package slice_on_disk
+
+import (
+	"container/heap"
+	"math/rand"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestHeap(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	values := rand.Perm(200)
+	s.Append(values[:100]...)
+	h := HeapAdapter(s, func(a, b int) bool { return a < b })
+	heap.Init(h)
+	for _, v := range values[100:] {
+		heap.Push(h, v)
+	}
+	// the top 5
+	var got []int
+	for i := 0; i < 5; i++ {
+		got = append(got, heap.Pop(h).(int))
+	}
+	if err := h.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, seq(0, 5)) || s.Len() != 195 {
+		t.Errorf("heap.Pop() = %v, %d left, want 0..4 and 195", got, s.Len())
+	}
+}
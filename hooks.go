@@ -0,0 +1,52 @@
+package slice_on_disk
+
+import "sync/atomic"
+
+// Hooks are called on the changes of a Slicer, see WithHooks,
+// e.g. to monitor the backlog without polling. They are called
+// with the Slicer locked: they must not call the Slicer and should
+// return quickly. The nil ones are skipped
+type Hooks struct {
+	// n elements were appended
+	OnAppend func(n int)
+	// n elements were removed: deleted, popped, truncated, evicted or expired
+	OnDelete func(n int)
+	// n elements went to the disk tail, the head being full
+	OnSpill func(n int)
+	// the totals published WithExpvar
+	counts *counts
+}
+
+// counts are the totals of the hooks, see WithExpvar
+type counts struct {
+	appended atomic.Int64
+	deleted  atomic.Int64
+	spilled  atomic.Int64
+}
+
+func (h Hooks) appended(n int) {
+	if n > 0 && h.counts != nil {
+		h.counts.appended.Add(int64(n))
+	}
+	if n > 0 && h.OnAppend != nil {
+		h.OnAppend(n)
+	}
+}
+
+func (h Hooks) deleted(n int) {
+	if n > 0 && h.counts != nil {
+		h.counts.deleted.Add(int64(n))
+	}
+	if n > 0 && h.OnDelete != nil {
+		h.OnDelete(n)
+	}
+}
+
+func (h Hooks) spilled(n int) {
+	if n > 0 && h.counts != nil {
+		h.counts.spilled.Add(int64(n))
+	}
+	if n > 0 && h.OnSpill != nil {
+		h.OnSpill(n)
+	}
+}
@@ -0,0 +1,70 @@
+package slice_on_disk
+
+import (
+	"os"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var appended, deleted, spilled int
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithMaxLen(50), WithHooks(Hooks{
+		OnAppend: func(n int) { appended += n },
+		OnDelete: func(n int) { deleted += n },
+		OnSpill:  func(n int) { spilled += n },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	for i := 0; i < 60; i++ {
+		s.Append(i)
+	}
+	s.Delete(5, 10)
+	s.DeleteRanges([2]int{0, 2}, [2]int{20, 3})
+	s.SetMemoryCapacity(5)
+
+	// 10 evicted by the bound
+	if appended != 60 || deleted != 25 {
+		t.Errorf("appended %d, deleted %d, want 60 and 25", appended, deleted)
+	}
+	if backlog := appended - deleted; backlog != s.Len() {
+		t.Errorf("backlog %d, Len() = %d", backlog, s.Len())
+	}
+	// the Appends past the head, then the end of the shrunk head
+	if spilled != 50+5 {
+		t.Errorf("spilled %d, want 55", spilled)
+	}
+}
+
+func TestHooksCopies(t *testing.T) {
+	calls := 0
+	count := func(n int) { calls++ }
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithHooks(Hooks{OnAppend: count, OnDelete: count, OnSpill: count}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 50)...)
+
+	// the copies don't report to the hooks of s
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+	a, b, err := s.Split(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Cleanup()
+	defer b.Cleanup()
+	calls = 0
+	for _, c := range []Slicer[int]{cl, a, b} {
+		c.Append(1, 2, 3)
+		c.Delete(0, 5)
+	}
+	if calls != 0 {
+		t.Errorf("%d calls of the hooks of s", calls)
+	}
+}
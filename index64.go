@@ -0,0 +1,57 @@
+package slice_on_disk
+
+import "math"
+
+// toInt converts the int64 index i, IndexOutOfBounds if it doesn't fit
+// an int: a Slicer can't hold more elements than the int indices reach
+func toInt(i int64) (int, error) {
+	if i < 0 || i > math.MaxInt {
+		return 0, IndexOutOfBounds
+	}
+	return int(i), nil
+}
+
+func (c *config[T]) Len64() int64 {
+	return int64(c.Len())
+}
+
+func (c *config[T]) Get64(index int64) (T, error) {
+	i, err := toInt(index)
+	if err != nil {
+		var t T
+		return t, err
+	}
+	return c.Get(i)
+}
+
+func (c *config[T]) Put64(index int64, element T) error {
+	i, err := toInt(index)
+	if err != nil {
+		return err
+	}
+	return c.Put(i, element)
+}
+
+func (c *config[T]) Slice64(ind ...int64) ([]T, error) {
+	is := make([]int, len(ind))
+	for k, index := range ind {
+		i, err := toInt(index)
+		if err != nil {
+			return nil, err
+		}
+		is[k] = i
+	}
+	return c.Slice(is...)
+}
+
+func (c *config[T]) Delete64(start, count int64) error {
+	s, err := toInt(start)
+	if err != nil {
+		return err
+	}
+	n, err := toInt(count)
+	if err != nil {
+		return err
+	}
+	return c.Delete(s, n)
+}
@@ -0,0 +1,48 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestIndex64(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if s.Len64() != 100 {
+		t.Errorf("Len64() = %d, want 100", s.Len64())
+	}
+	if x, err := s.Get64(50); err != nil || x != 50 {
+		t.Errorf("Get64(50) = %d, %v", x, err)
+	}
+	if _, err := s.Get64(-1); err != IndexOutOfBounds {
+		t.Errorf("Get64(-1) = %v, want IndexOutOfBounds", err)
+	}
+	if err := s.Put64(60, -60); err != nil {
+		t.Fatal(err)
+	}
+	if x, err := s.Slice64(59, 61); err != nil || len(x) != 2 || x[1] != -60 {
+		t.Errorf("Slice64(59, 61) = %v, %v", x, err)
+	}
+	// start+count overflows
+	if err := s.Delete64(5, math.MaxInt); err == nil {
+		t.Errorf("Delete64(5, MaxInt) succeeded")
+	}
+	if err := s.Delete64(90, 10); err != nil || s.Len64() != 90 {
+		t.Errorf("Delete64(90, 10) = %v, Len64() = %d", err, s.Len64())
+	}
+
+	// the ids run out, Compact renumbers them
+	c, _ := s.(*config[int])
+	c.diskIndex = math.MaxInt
+	if err := s.Append(100); !errors.Is(err, ErrNoIDs) {
+		t.Errorf("Append() = %v, want ErrNoIDs", err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(100); err != nil || s.Len64() != 91 {
+		t.Errorf("Append() = %v, Len64() = %d", err, s.Len64())
+	}
+}
@@ -0,0 +1,165 @@
+package slice_on_disk
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// ingestBatch is the number of elements the constructors append per call
+const ingestBatch = 256
+
+// Format is the encoding of the stream of NewFromReader
+type Format int
+
+const (
+	// values written in turn by a gob.Encoder
+	FormatGob Format = iota
+	// JSON values, one per line as ExportJSONL writes them
+	FormatJSONL
+	// the framed elements CopyTo writes
+	FormatRecords
+)
+
+// NewFromSeq is New filling the Slicer with the elements of seq, appended
+// in batches as they come: each batch is a single Append call, so one
+// lock, one manifest update and one log record of WithWAL. The elements
+// past the capacity of slice spill to the disk as with Append. If one
+// can't be appended, the Slicer is cleaned up and the error is an
+// *AppendError telling which
+func NewFromSeq[T any](slice []T, rootPath string, seq iter.Seq[T], opts ...Option) (Slicer[T], error) {
+	s, err := New(slice, rootPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	in := ingester[T]{s: s}
+	for t := range seq {
+		if err = in.add(t); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = in.flush()
+	}
+	if err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewFromChan is NewFromSeq with the elements received from ch until it
+// is closed. If ctx is done first the Slicer is cleaned up and ctx.Err()
+// returned
+func NewFromChan[T any](ctx context.Context, slice []T, rootPath string, ch <-chan T, opts ...Option) (Slicer[T], error) {
+	s, err := New(slice, rootPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	in := ingester[T]{s: s}
+	for err == nil {
+		select {
+		case t, ok := <-ch:
+			if !ok {
+				if err = in.flush(); err == nil {
+					return s, nil
+				}
+				continue
+			}
+			err = in.add(t)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	s.Cleanup()
+	return nil, err
+}
+
+// NewFromReader is NewFromSeq with the elements decoded from r in format
+// until its end, e.g. a stream written by a gob.Encoder, ExportJSONL or
+// CopyTo
+func NewFromReader[T any](slice []T, rootPath string, r io.Reader, format Format, opts ...Option) (Slicer[T], error) {
+	var decode func(any) error
+	var unit string
+	switch format {
+	case FormatGob:
+		decode, unit = gob.NewDecoder(r).Decode, "element"
+	case FormatJSONL:
+		decode, unit = json.NewDecoder(r).Decode, "line"
+	case FormatRecords:
+		decode = func(v any) (err error) {
+			*v.(*T), err = storage.ReadRecord[T](r)
+			return err
+		}
+		unit = "element"
+	default:
+		return nil, fmt.Errorf("unknown format %d", format)
+	}
+
+	s, err := New(slice, rootPath, opts...)
+	if err != nil {
+		return nil, err
+	}
+	in := ingester[T]{s: s}
+	for n := 1; ; n++ {
+		var t T
+		if err = decode(&t); errors.Is(err, io.EOF) {
+			err = in.flush()
+			break
+		}
+		if err != nil {
+			err = fmt.Errorf("%s %d: %w", unit, n, err)
+			break
+		}
+		if err = in.add(t); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	return s, nil
+}
+
+// ingester appends the elements of the constructors in batches
+type ingester[T any] struct {
+	s     Slicer[T]
+	batch []T
+	// the elements appended before the batch
+	n int
+}
+
+func (in *ingester[T]) add(t T) error {
+	if in.batch == nil {
+		in.batch = make([]T, 0, ingestBatch)
+	}
+	in.batch = append(in.batch, t)
+	if len(in.batch) < ingestBatch {
+		return nil
+	}
+	return in.flush()
+}
+
+// flush appends the batch, which Append doesn't keep, so it is reused
+func (in *ingester[T]) flush() error {
+	if len(in.batch) == 0 {
+		return nil
+	}
+	err := in.s.Append(in.batch...)
+	var ae *AppendError
+	if errors.As(err, &ae) {
+		// the position in the whole input
+		err = &AppendError{Index: in.n + ae.Index, Err: ae.Err}
+	}
+	in.n += len(in.batch)
+	clear(in.batch)
+	in.batch = in.batch[:0]
+	return err
+}
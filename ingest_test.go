@@ -0,0 +1,95 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewFromSeq(t *testing.T) {
+	s, err := NewFromSeq(make([]int, 0, 10), os.TempDir(), slices.Values(seq(0, 1000)), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	if x, err := s.Slice(); err != nil || !slices.Equal(x, seq(0, 1000)) {
+		t.Errorf("Slice() = %v, %v, want 0..999", x, err)
+	}
+	if s.DiskLen() != 990 {
+		t.Errorf("DiskLen() = %d, want 990", s.DiskLen())
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	// the position of the failed element is the one in the sequence
+	values := make([]any, 600)
+	values[300] = make(chan int)
+	_, err = NewFromSeq(make([]any, 0, 1), os.TempDir(), slices.Values(values))
+	var ae *AppendError
+	if !errors.As(err, &ae) || ae.Index != 300 {
+		t.Errorf("NewFromSeq() = %v, want an AppendError at element 300", err)
+	}
+}
+
+func TestNewFromChan(t *testing.T) {
+	ch := make(chan string)
+	go func() {
+		for _, s := range []string{"a", "b", "c"} {
+			ch <- s
+		}
+		close(ch)
+	}()
+	s, err := NewFromChan(context.Background(), make([]string, 0, 1), os.TempDir(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	if x, _ := s.Slice(); strings.Join(x, "") != "abc" {
+		t.Errorf("Slice() = %v, want a b c", x)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewFromChan(ctx, make([]string, 0, 1), os.TempDir(), make(chan string)); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewFromChan() = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewFromReader(t *testing.T) {
+	var buf bytes.Buffer
+	e := gob.NewEncoder(&buf)
+	for _, p := range []personV1{{"Ada"}, {"Alan"}, {"Grace"}} {
+		e.Encode(p)
+	}
+	s, err := NewFromReader(make([]personV1, 0, 1), os.TempDir(), &buf, FormatGob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	if x, _ := s.Get(2); x.Name != "Grace" || s.Len() != 3 {
+		t.Errorf("Get(2) = %v of %d, want Grace of 3", x, s.Len())
+	}
+
+	var out bytes.Buffer
+	if err := s.ExportJSONL(&out); err != nil {
+		t.Fatal(err)
+	}
+	j, err := NewFromReader(make([]personV1, 0, 1), os.TempDir(), &out, FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Cleanup()
+	if x, _ := j.Get(1); x.Name != "Alan" || j.Len() != 3 {
+		t.Errorf("Get(1) = %v of %d, want Alan of 3", x, j.Len())
+	}
+
+	if _, err := NewFromReader(make([]personV1, 0, 1), os.TempDir(), strings.NewReader("{\"Name\":\"x\"}\n{"), FormatJSONL); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("NewFromReader() of a truncated stream = %v, want an error on line 2", err)
+	}
+}
@@ -0,0 +1,85 @@
+package slice_on_disk
+
+import (
+	"fmt"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// DirInfo describes the disk tail of a Slicer directory, see Inspect
+type DirInfo struct {
+	Path string
+	// the number of elements and the size of their files
+	Len   int
+	Bytes int64
+	// the elements in block files and the ones split into chunks
+	Packed  int
+	Chunked int
+	// the layout of WithSharding and WithRootPaths
+	Shards int
+	Roots  []string
+	// the format of the manifest and the codec of the elements,
+	// see RawElement. Codec is "" for a manifest of Version 0
+	Version int
+	Codec   string
+}
+
+// Inspect describes the disk tail of the directory of a Slicer created
+// WithManifest without decoding any element, attached like OpenReadOnly,
+// e.g. for tools like cmd/sodctl
+func Inspect(path string) (DirInfo, error) {
+	c, m, err := inspect(path)
+	if err != nil {
+		return DirInfo{}, err
+	}
+	defer c.Cleanup()
+	return DirInfo{
+		Path:    path,
+		Len:     len(c.diskSlice),
+		Bytes:   c.Used(),
+		Packed:  len(c.Entries(c.diskSlice)),
+		Chunked: len(c.ChunkCounts(c.diskSlice)),
+		Shards:  c.Shards(),
+		Roots:   c.RootDirs(),
+		Version: m.Version,
+		Codec:   m.Codec,
+	}, nil
+}
+
+// RawElement returns the verified payload of the element at index in the
+// disk tail of the directory of a Slicer created WithManifest, and how it
+// is encoded: "gob", "raw" for a []byte or "binary" for the fixed size
+// values and the strings, in little endian. It dumps the elements of
+// a type the caller doesn't have
+func RawElement(path string, index int) ([]byte, string, error) {
+	c, _, err := inspect(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer c.Cleanup()
+	if index < 0 || index >= len(c.diskSlice) {
+		return nil, "", IndexOutOfBounds
+	}
+	b, codec, err := storage.Payload(c.Storage, c.diskSlice[index])
+	if err != nil {
+		return nil, "", fmt.Errorf(GetError, err)
+	}
+	return b, codec, nil
+}
+
+// VerifyDir checks the checksums of the elements of the disk tail of the
+// directory of a Slicer created WithManifest, like Verify, without
+// decoding them, and returns their number
+func VerifyDir(path string) (int, error) {
+	c, _, err := inspect(path)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Cleanup()
+	return len(c.diskSlice), c.Verify()
+}
+
+// inspect attaches to path without a head, so no element is decoded
+func inspect(path string) (*config[struct{}], manifest, error) {
+	return attach[struct{}](nil, path, true)
+}
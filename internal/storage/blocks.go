@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// BlockPrefix starts the names of the block files, see Pack
+const BlockPrefix = "block-"
+
+// a block file is closed for appends once it grows past blockSize
+const blockSize = 1 << 20
+
+// Entry locates a packed element in its block file
+type Entry struct {
+	Block  int
+	Offset int64
+	Length int64
+}
+
+// blocks is the index of the packed elements.
+// The block files are append only: a new version of an element
+// is appended, a deleted element just leaves the index.
+// A block file is removed once none of its elements is left
+type blocks struct {
+	threshold int
+	index     map[int]Entry
+	live      map[int]int
+	// the block appended to, -1 before the first append
+	current int
+	offset  int64
+	next    int
+	// every element is appended, see Log
+	log bool
+	// the current block, kept open with log
+	file *os.File
+}
+
+// Pack makes the elements encoded to less than threshold bytes
+// share block files instead of having a file each.
+// It must be called before any file is written
+func (s *Storage) Pack(threshold int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks.threshold = threshold
+	s.blocks.current = -1
+}
+
+// Log makes the storage a log: every element is appended to the current
+// block file whatever its size, and the block file is kept open between
+// the appends instead of being opened for each. The elements larger than
+// the chunk size, if any, are still chunked.
+// It must be called before any file is written
+func (s *Storage) Log() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocks.log = true
+	s.blocks.current = -1
+}
+
+// BlockPath returns the name of the block file b
+func (s *Storage) BlockPath(b int) string {
+	return filepath.Join(s.RootPath, fmt.Sprintf("%s%d", BlockPrefix, b))
+}
+
+// Blocks returns the names of the block files in use
+func (s *Storage) Blocks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for b := range s.blocks.live {
+		names = append(names, s.BlockPath(b))
+	}
+	if _, ok := s.blocks.live[s.blocks.current]; !ok && s.blocks.current >= 0 {
+		names = append(names, s.BlockPath(s.blocks.current))
+	}
+	return names
+}
+
+// Entries returns the locations of the packed elements among ids
+func (s *Storage) Entries(ids []int) map[int]Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[int]Entry)
+	for _, id := range ids {
+		if e, ok := s.blocks.index[id]; ok {
+			entries[id] = e
+		}
+	}
+	return entries
+}
+
+// Adopt adds the packed elements of existing block files, e.g. after
+// the block files were linked or the directory was reopened.
+// The new elements go to new block files
+func (s *Storage) Adopt(entries map[int]Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, e := range entries {
+		s.blocks.add(id, e)
+		s.blocks.next = max(s.blocks.next, e.Block+1)
+	}
+	s.blocks.closeLog()
+	s.blocks.current = -1
+}
+
+// Renumber changes the ids of the packed, chunked and placed elements,
+// ids maps old to new. The ones missing in ids are dropped.
+// Renaming the files is up to the caller
+func (s *Storage) Renumber(ids map[int]int) {
+	s.renumberRoots(ids)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.blocks.index
+	s.blocks.index, s.blocks.live = nil, nil
+	for old, e := range index {
+		if id, ok := ids[old]; ok {
+			s.blocks.add(id, e)
+		}
+	}
+	chunks := s.chunks
+	s.chunks = nil
+	for old, n := range chunks {
+		if id, ok := ids[old]; ok {
+			if s.chunks == nil {
+				s.chunks = make(map[int]int)
+			}
+			s.chunks[id] = n
+		}
+	}
+}
+
+// Packed tells if the element id is in a block file
+func (s *Storage) Packed(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.blocks.index[id]
+	return ok
+}
+
+// Exists tells if the files of the element id are there
+func (s *Storage) Exists(id int) error {
+	s.mu.Lock()
+	e, packed := s.blocks.index[id]
+	s.mu.Unlock()
+	if packed {
+		_, err := os.Stat(s.BlockPath(e.Block))
+		return err
+	}
+	for _, name := range s.Names(id) {
+		if _, err := os.Stat(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *blocks) add(id int, e Entry) {
+	if b.index == nil {
+		b.index = make(map[int]Entry)
+		b.live = make(map[int]int)
+	}
+	b.index[id] = e
+	b.live[e.Block]++
+}
+
+// drop removes the element id from the index and returns
+// the block file that is no longer needed, if any
+func (b *blocks) drop(id int) (int, bool) {
+	e, ok := b.index[id]
+	if !ok {
+		return 0, false
+	}
+	delete(b.index, id)
+	b.live[e.Block]--
+	if b.live[e.Block] > 0 {
+		return 0, false
+	}
+	delete(b.live, e.Block)
+	return e.Block, e.Block != b.current
+}
+
+// pack appends the sealed element e to the current block file
+func (s *Storage) pack(id int, e sealed) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.blocks.current < 0 || s.blocks.offset >= blockSize {
+		// the previous block may be empty already
+		if _, ok := s.blocks.live[s.blocks.current]; !ok && s.blocks.current >= 0 {
+			os.Remove(s.BlockPath(s.blocks.current))
+		}
+		s.blocks.closeLog()
+		s.blocks.current = s.blocks.next
+		s.blocks.next++
+		s.blocks.offset = 0
+	}
+
+	fname := s.BlockPath(s.blocks.current)
+	f := s.blocks.file
+	if f == nil {
+		var err error
+		if f, err = os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, s.fileMode); err != nil {
+			return err
+		}
+	}
+	err := s.appendEntry(f, e)
+	if err != nil || !s.blocks.log {
+		s.blocks.file = nil
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	} else {
+		s.blocks.file = f
+	}
+	if err != nil {
+		return err
+	}
+	if s.durability == DurabilityBatch {
+		s.markDirty(fname)
+	}
+
+	// the previous version of the element, in its block
+	if old, ok := s.blocks.drop(id); ok {
+		os.Remove(s.BlockPath(old))
+	}
+	s.blocks.add(id, Entry{Block: s.blocks.current, Offset: s.blocks.offset, Length: int64(e.len())})
+	s.blocks.offset += int64(e.len())
+	return nil
+}
+
+// appendEntry writes the sealed element e at the end of the current
+// block file f, s.mu must be held
+func (s *Storage) appendEntry(f *os.File, e sealed) error {
+	_, err := e.writeTo(f)
+	if err == nil && s.durability == DurabilityAlways {
+		err = f.Sync()
+	}
+	if err != nil {
+		// the entry, maybe partial, is never referenced: skip it
+		s.blocks.offset = blockSize
+	}
+	return err
+}
+
+// closeLog closes the current block file kept open by Log
+func (b *blocks) closeLog() {
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+}
+
+// unpack returns the verified payload of the element id
+// from its block file, packed is false if it isn't in one
+func (s *Storage) unpack(id int) (payload []byte, c codec, packed bool, err error) {
+	s.mu.Lock()
+	e, ok := s.blocks.index[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, codecGob, false, nil
+	}
+
+	f, err := os.Open(s.BlockPath(e.Block))
+	if err != nil {
+		return nil, codecGob, true, err
+	}
+	defer f.Close()
+	b := make([]byte, e.Length)
+	if _, err := f.ReadAt(b, e.Offset); err != nil {
+		return nil, codecGob, true, fmt.Errorf("%w: element %d in %s: %s", ErrCorrupted, id, f.Name(), err.Error())
+	}
+	payload, c, err = verify(fmt.Sprintf("element %d in %s", id, f.Name()), b)
+	return payload, c, true, err
+}
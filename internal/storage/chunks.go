@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Chunk makes the elements encoded to more than size bytes
+// go to several chunk files of size bytes, written and read
+// as a stream. It must be called before any file is written
+func (s *Storage) Chunk(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunkSize = size
+}
+
+// ChunkPath returns the name of the chunk k of the element id
+func (s *Storage) ChunkPath(id, k int) string {
+	return fmt.Sprintf("%s.%d", s.Path(id), k)
+}
+
+// Names returns the names of the files of the element id:
+// its chunks, its own file, or none when it is packed
+func (s *Storage) Names(id int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.names(id)
+}
+
+func (s *Storage) names(id int) []string {
+	if _, ok := s.blocks.index[id]; ok {
+		return nil
+	}
+	n, ok := s.chunks[id]
+	if !ok {
+		return []string{s.Path(id)}
+	}
+	names := make([]string, n)
+	for k := range names {
+		names[k] = s.ChunkPath(id, k)
+	}
+	return names
+}
+
+// ChunkCounts returns the number of chunks of the chunked elements among ids
+func (s *Storage) ChunkCounts(ids []int) map[int]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[int]int)
+	for _, id := range ids {
+		if n, ok := s.chunks[id]; ok {
+			counts[id] = n
+		}
+	}
+	return counts
+}
+
+// AdoptChunks adds the chunked elements of existing chunk files
+func (s *Storage) AdoptChunks(counts map[int]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, n := range counts {
+		if s.chunks == nil {
+			s.chunks = make(map[int]int)
+		}
+		s.chunks[id] = n
+	}
+}
+
+// chunker receives the encoding of an element. It is kept in memory
+// while it fits a chunk, beyond that it goes to the chunk files
+type chunker struct {
+	s    *Storage
+	id   int
+	size int
+	// the current chunk after the room for its header
+	buf []byte
+	// chunks written and their size
+	n       int
+	written int
+	codec   codec
+}
+
+func (w *chunker) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		room := headerSize + w.size - len(w.buf)
+		if room == 0 {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		k := min(room, len(p))
+		w.buf = append(w.buf, p[:k]...)
+		p = p[k:]
+	}
+	return written, nil
+}
+
+func (w *chunker) flush() error {
+	seal(w.buf, w.codec)
+	if err := write(w.s, w.s.ChunkPath(w.id, w.n), sealed{b: w.buf}); err != nil {
+		return err
+	}
+	w.n++
+	w.written += len(w.buf)
+	w.buf = w.buf[:headerSize]
+	return nil
+}
+
+// abort removes the chunks written so far
+func (w *chunker) abort() {
+	for k := 0; k < w.n; k++ {
+		os.Remove(w.s.ChunkPath(w.id, k))
+	}
+}
+
+// unchunk decodes the chunked element id, chunked is false
+// if it isn't chunked
+func unchunk[T any](s *Storage, id int) (t T, chunked bool, err error) {
+	s.mu.Lock()
+	n, ok := s.chunks[id]
+	s.mu.Unlock()
+	if !ok {
+		return t, false, nil
+	}
+	r := &unchunker{s: s, id: id, n: n}
+	defer r.Close()
+	if err := r.fill(); err != nil {
+		return t, true, fmt.Errorf(GetError, err)
+	}
+	if r.codec != codecGob {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return t, true, fmt.Errorf(GetError, err)
+		}
+		t, err = decode[T](b, r.codec)
+		return t, true, err
+	}
+	if err := gob.NewDecoder(r).Decode(&t); err != nil {
+		return t, true, fmt.Errorf(GetError, err)
+	}
+	return t, true, nil
+}
+
+// unchunker reads the chunks of an element in turn
+type unchunker struct {
+	s     *Storage
+	id    int
+	n     int
+	next  int
+	chunk bytes.Reader
+	buf   *bytes.Buffer
+	codec codec
+}
+
+func (r *unchunker) Read(p []byte) (int, error) {
+	if err := r.fill(); err != nil {
+		return 0, err
+	}
+	return r.chunk.Read(p)
+}
+
+func (r *unchunker) Close() error {
+	r.release()
+	return nil
+}
+
+// release recycles the buffer of the current chunk
+func (r *unchunker) release() {
+	if r.buf != nil {
+		r.chunk.Reset(nil)
+		putBuffer(r.buf)
+		r.buf = nil
+	}
+}
+
+// fill loads the next chunk once the current one is consumed
+func (r *unchunker) fill() error {
+	for r.chunk.Len() == 0 {
+		if r.next == r.n {
+			return io.EOF
+		}
+		r.release()
+		payload, c, buf, err := load(r.s.ChunkPath(r.id, r.next))
+		if err != nil {
+			return err
+		}
+		r.chunk.Reset(payload)
+		r.buf = buf
+		r.codec = c
+		r.next++
+	}
+	return nil
+}
+
+// supersede removes the previous version of the element id
+// after it was written again with packed, chunks or its own file.
+// s.mu must be held
+func (s *Storage) supersede(id int, packed bool, chunks int) {
+	_, wasPacked := s.blocks.index[id]
+	wasChunks, wasChunked := s.chunks[id]
+	_, written := s.sizes[id]
+
+	if wasPacked && !packed {
+		if b, ok := s.blocks.drop(id); ok {
+			os.Remove(s.BlockPath(b))
+		}
+	}
+	if wasChunked {
+		for k := chunks; k < wasChunks; k++ {
+			os.Remove(s.ChunkPath(id, k))
+		}
+	}
+	if chunks > 0 {
+		if s.chunks == nil {
+			s.chunks = make(map[int]int)
+		}
+		s.chunks[id] = chunks
+	} else {
+		delete(s.chunks, id)
+	}
+	if written && !wasPacked && !wasChunked && (packed || chunks > 0) {
+		os.Remove(s.Path(id))
+	}
+}
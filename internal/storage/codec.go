@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// every file starts with the length of the encoded element
+// and its CRC32 checksum
+const headerSize = 12
+
+// codec tells how a payload is encoded.
+// It is kept in the top bits of the length in the header
+type codec uint64
+
+const (
+	codecGob codec = 0
+	// a []byte stored as is
+	codecRaw codec = 1 << 63
+	// a string, an int or a fixed size value in little endian,
+	// see encoding/binary
+	codecBinary codec = 1 << 62
+
+	codecMask = uint64(codecRaw | codecBinary)
+)
+
+// ErrNotRaw is returned by OpenReader for an element not stored raw
+var ErrNotRaw = errors.New("element is not stored as raw bytes")
+
+// buffers recycles the buffers elements are encoded to and read into,
+// as most elements are small and short-lived.
+// The gob encoders and decoders themselves can't be reused: every file
+// is a stream of its own, which must carry the type information
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooled is the capacity above which a buffer is left to the GC,
+// so that a single huge element doesn't stay pinned in the pool
+const maxPooled = 64 << 10
+
+func getBuffer() *bytes.Buffer {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer recycles buf, nothing may refer to its bytes anymore
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooled {
+		buffers.Put(buf)
+	}
+}
+
+// sealed is an encoded element: its header and payload, or only its
+// header if the payload is a []byte written as is, without a copy
+type sealed struct {
+	b   []byte
+	raw []byte
+}
+
+func (e sealed) len() int {
+	return len(e.b) + len(e.raw)
+}
+
+// writeTo writes e to w, the raw payload with a write of its own
+func (e sealed) writeTo(w io.Writer) (int64, error) {
+	n, err := w.Write(e.b)
+	if err == nil && len(e.raw) > 0 {
+		var k int
+		k, err = w.Write(e.raw)
+		n += k
+	}
+	return int64(n), err
+}
+
+// encode returns the sealed encoding of t and the buffer holding it,
+// to be released with putBuffer once written
+func encode[T any](t T) (sealed, *bytes.Buffer, error) {
+	buf := getBuffer()
+	var header [headerSize]byte
+	buf.Write(header[:])
+
+	payload, c, ok := encodeFast(buf, t)
+	if !ok {
+		putBuffer(buf)
+		return encodeGob(t)
+	}
+	if c == codecRaw {
+		sealPayload(buf.Bytes(), payload, c)
+		return sealed{b: buf.Bytes(), raw: payload}, buf, nil
+	}
+	seal(buf.Bytes(), c)
+	return sealed{b: buf.Bytes()}, buf, nil
+}
+
+func encodeGob[T any](t T) (sealed, *bytes.Buffer, error) {
+	buf := getBuffer()
+	var header [headerSize]byte
+	buf.Write(header[:])
+	// through a pointer, an interface value is sent with its dynamic type
+	if err := gob.NewEncoder(buf).Encode(&t); err != nil {
+		putBuffer(buf)
+		return sealed{}, nil, err
+	}
+	seal(buf.Bytes(), codecGob)
+	return sealed{b: buf.Bytes()}, buf, nil
+}
+
+// encodeFast encodes the types that don't need gob by appending them
+// to buf. An unchanged []byte is returned as is instead
+func encodeFast[T any](buf *bytes.Buffer, t T) ([]byte, codec, bool) {
+	if isInterface[T]() {
+		return nil, codecGob, false
+	}
+	n := buf.Len()
+	switch v := any(t).(type) {
+	case []byte:
+		return v, codecRaw, true
+	case string:
+		buf.WriteString(v)
+		return buf.Bytes()[n:], codecBinary, true
+	case int:
+		return appendUint64(buf, uint64(v)), codecBinary, true
+	case uint:
+		return appendUint64(buf, uint64(v)), codecBinary, true
+	}
+	if !fixedSize(reflect.TypeOf(t)) {
+		return nil, codecGob, false
+	}
+	if err := binary.Write(buf, binary.LittleEndian, t); err != nil {
+		buf.Truncate(n)
+		return nil, codecGob, false
+	}
+	return buf.Bytes()[n:], codecBinary, true
+}
+
+// CodecOf returns the name of the codec the elements of type T are
+// encoded with, like Payload does for a stored one
+func CodecOf[T any]() string {
+	var t T
+	switch any(t).(type) {
+	case []byte:
+		return codecRaw.String()
+	case string, int, uint:
+		return codecBinary.String()
+	}
+	if fixedSize(reflect.TypeOf((*T)(nil)).Elem()) {
+		return codecBinary.String()
+	}
+	return codecGob.String()
+}
+
+func appendUint64(buf *bytes.Buffer, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	n := buf.Len()
+	buf.Write(b[:])
+	return buf.Bytes()[n:]
+}
+
+func decode[T any](payload []byte, c codec) (T, error) {
+	var retVal T
+	switch c {
+	case codecRaw:
+		p, ok := any(&retVal).(*[]byte)
+		if !ok {
+			return retVal, fmt.Errorf(GetError, fmt.Errorf("raw bytes for a %T", retVal))
+		}
+		// the payload is not shared with anything
+		*p = payload
+		return retVal, nil
+
+	case codecBinary:
+		var err error
+		switch p := any(&retVal).(type) {
+		case *string:
+			*p = string(payload)
+		case *int:
+			if len(payload) != 8 {
+				err = fmt.Errorf("%d bytes for an int", len(payload))
+			} else {
+				*p = int(binary.LittleEndian.Uint64(payload))
+			}
+		case *uint:
+			if len(payload) != 8 {
+				err = fmt.Errorf("%d bytes for a uint", len(payload))
+			} else {
+				*p = uint(binary.LittleEndian.Uint64(payload))
+			}
+		default:
+			err = binary.Read(bytes.NewReader(payload), binary.LittleEndian, &retVal)
+		}
+		if err != nil {
+			return retVal, fmt.Errorf(GetError, err)
+		}
+		return retVal, nil
+	}
+
+	decoder := gob.NewDecoder(bytes.NewReader(payload))
+	if err := decoder.Decode(&retVal); err != nil {
+		return retVal, fmt.Errorf(GetError, err)
+	}
+	return retVal, nil
+}
+
+// isInterface tells whether T is an interface type, whose elements are
+// decoded by gob whatever their dynamic type
+func isInterface[T any]() bool {
+	return reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Interface
+}
+
+// fixedSizes caches fixedSize by type
+var fixedSizes sync.Map
+
+// fixedSize tells if encoding/binary can encode the values of t:
+// numbers, bools and arrays and structs of them. Structs with
+// unexported fields are left to gob, which ignores those fields
+func fixedSize(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if ok, found := fixedSizes.Load(t); found {
+		return ok.(bool)
+	}
+
+	ok := false
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		ok = true
+	case reflect.Array:
+		ok = fixedSize(t.Elem())
+	case reflect.Struct:
+		ok = true
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); !f.IsExported() || !fixedSize(f.Type) {
+				ok = false
+				break
+			}
+		}
+	}
+	fixedSizes.Store(t, ok)
+	return ok
+}
+
+// seal fills the header of b, whose payload follows the header
+func seal(b []byte, c codec) {
+	sealPayload(b[:headerSize], b[headerSize:], c)
+}
+
+// sealPayload fills the header of a payload kept apart from it
+func sealPayload(header, payload []byte, c codec) {
+	binary.LittleEndian.PutUint64(header[0:8], uint64(len(payload))|uint64(c))
+	binary.LittleEndian.PutUint32(header[8:12], crc32.ChecksumIEEE(payload))
+}
+
+// load returns the verified payload of the file fname in a buffer from
+// the pool. The buffer may be released with putBuffer unless the payload
+// is handed out
+func load(fname string) ([]byte, codec, *bytes.Buffer, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, codecGob, nil, err
+	}
+	defer f.Close()
+	buf := getBuffer()
+	if fi, err := f.Stat(); err == nil {
+		buf.Grow(int(fi.Size()))
+	}
+	if _, err := buf.ReadFrom(f); err != nil {
+		putBuffer(buf)
+		return nil, codecGob, nil, err
+	}
+	payload, c, err := verify(fname, buf.Bytes())
+	if err != nil {
+		putBuffer(buf)
+		return nil, codecGob, nil, err
+	}
+	return payload, c, buf, nil
+}
+
+// verify returns the payload of the sealed b read from name
+// and its codec
+func verify(name string, b []byte) ([]byte, codec, error) {
+	if len(b) < headerSize {
+		return nil, codecGob, fmt.Errorf("%w: %s is %d bytes long", ErrCorrupted, name, len(b))
+	}
+	payload := b[headerSize:]
+	n := binary.LittleEndian.Uint64(b[0:8])
+	if n&^codecMask != uint64(len(payload)) {
+		return nil, codecGob, fmt.Errorf("%w: %s has %d bytes, want %d", ErrCorrupted, name, len(payload), n&^codecMask)
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(b[8:12]) {
+		return nil, codecGob, fmt.Errorf("%w: %s checksum mismatch", ErrCorrupted, name)
+	}
+	return payload, codec(n & codecMask), nil
+}
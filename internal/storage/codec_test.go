@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+type point struct {
+	X, Y float64
+	Tag  [4]byte
+}
+
+func TestCodecs(t *testing.T) {
+	roundTrip(t, 42, codecBinary)
+	roundTrip(t, -7, codecBinary)
+	roundTrip(t, uint(math.MaxUint64), codecBinary)
+	roundTrip(t, math.Pi, codecBinary)
+	roundTrip(t, "hello", codecBinary)
+	roundTrip(t, point{1, 2, [4]byte{'a'}}, codecBinary)
+	// unexported fields and pointers are left to gob
+	roundTrip(t, struct{ A *int }{nil}, codecGob)
+	roundTrip(t, struct {
+		A int32
+		b int32
+	}{1, 0}, codecGob)
+	// an interface element keeps its dynamic type
+	roundTrip[any](t, "hello", codecGob)
+	roundTrip[any](t, 42, codecGob)
+
+	// a []byte is written after its header, not copied behind it
+	v := []byte("payload")
+	e, _, err := encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.b) != headerSize || &e.raw[0] != &v[0] {
+		t.Errorf("encode() = %d bytes with the header, want %d and the payload apart", len(e.b), headerSize)
+	}
+	var w bytes.Buffer
+	if n, err := e.writeTo(&w); err != nil || n != int64(e.len()) {
+		t.Fatalf("writeTo() = %d, %v, want %d", n, err, e.len())
+	}
+	if payload, c, err := verify("test", w.Bytes()); err != nil || c != codecRaw || string(payload) != "payload" {
+		t.Errorf("verify() = %q, %x, %v", payload, c, err)
+	}
+}
+
+func roundTrip[T comparable](t *testing.T, v T, want codec) {
+	t.Helper()
+	e, _, err := encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, c, err := verify("test", e.b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := CodecOf[T](); got != want.String() {
+		t.Errorf("CodecOf[%T]() = %s, want %s", v, got, want)
+	}
+	if c != want {
+		t.Errorf("%T encoded with codec %x, want %x", v, c, want)
+	}
+	x, err := decode[T](payload, c)
+	if err != nil || x != v {
+		t.Errorf("decode() = %v, %v, want %v", x, err, v)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	b.Run("int/gob", func(b *testing.B) { benchmarkEncode(b, 12345, encodeGob[int]) })
+	b.Run("int/binary", func(b *testing.B) { benchmarkEncode(b, 12345, encode[int]) })
+	b.Run("float64/gob", func(b *testing.B) { benchmarkEncode(b, math.Pi, encodeGob[float64]) })
+	b.Run("float64/binary", func(b *testing.B) { benchmarkEncode(b, math.Pi, encode[float64]) })
+	b.Run("struct/gob", func(b *testing.B) { benchmarkEncode(b, point{1, 2, [4]byte{}}, encodeGob[point]) })
+	b.Run("struct/binary", func(b *testing.B) { benchmarkEncode(b, point{1, 2, [4]byte{}}, encode[point]) })
+}
+
+func benchmarkEncode[T any](b *testing.B, v T, enc func(T) (sealed, *bytes.Buffer, error)) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, buf, err := enc(v)
+		if err != nil {
+			b.Fatal(err)
+		}
+		putBuffer(buf)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	b.Run("int/gob", func(b *testing.B) { benchmarkDecode(b, 12345, encodeGob[int]) })
+	b.Run("int/binary", func(b *testing.B) { benchmarkDecode(b, 12345, encode[int]) })
+	b.Run("float64/gob", func(b *testing.B) { benchmarkDecode(b, math.Pi, encodeGob[float64]) })
+	b.Run("float64/binary", func(b *testing.B) { benchmarkDecode(b, math.Pi, encode[float64]) })
+	b.Run("struct/gob", func(b *testing.B) { benchmarkDecode(b, point{1, 2, [4]byte{}}, encodeGob[point]) })
+	b.Run("struct/binary", func(b *testing.B) { benchmarkDecode(b, point{1, 2, [4]byte{}}, encode[point]) })
+}
+
+func benchmarkDecode[T any](b *testing.B, v T, enc func(T) (sealed, *bytes.Buffer, error)) {
+	e, _, err := enc(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	payload, c, err := verify("bench", e.b)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decode[T](payload, c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
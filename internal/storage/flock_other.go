@@ -0,0 +1,9 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+func flock(f *os.File, exclusive, wait bool) error {
+	return nil
+}
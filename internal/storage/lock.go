@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrLocked is returned when another process holds a lock
+// on the directory
+var ErrLocked = errors.New("directory is locked by another process")
+
+// LockSuffix ends the names of the lock files, which Files skips
+const LockSuffix = ".lock"
+
+// OwnerLock is held exclusively by the process owning the directory,
+// see Own
+const OwnerLock = "owner" + LockSuffix
+
+// AttachLock is shared by the processes attached with Attach
+// and taken exclusively to renumber the files
+const AttachLock = "attach" + LockSuffix
+
+// Flock locks the file name of the root path, creating it, for reading
+// or exclusively. Unless wait, ErrLocked is returned right away if another
+// process holds a conflicting lock. Closing the file releases the lock.
+// Where flock is not available, nothing is locked
+func (s *Storage) Flock(name string, exclusive, wait bool) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(s.RootPath, name), os.O_RDWR|os.O_CREATE, s.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, exclusive, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Attach makes s a reader of the directory of another process:
+// the files are never removed, not even by Cleanup, and a shared lock on
+// AttachLock is held until Cleanup. Waits for the exclusive lock of
+// a renumbering to be released
+func (s *Storage) Attach() error {
+	f, err := s.Flock(AttachLock, false, true)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.attached = f
+	s.mu.Unlock()
+	return nil
+}
+
+// Own locks the directory for this process until Cleanup or Disown,
+// failing with ErrLocked if another process owns it. A steal takes the
+// lock over, e.g. from a hung process or on a file system where the locks
+// of a dead process linger: the old lock file is replaced by a new one
+func (s *Storage) Own(steal bool) error {
+	s.mu.Lock()
+	owned := s.owned != nil
+	s.mu.Unlock()
+	if owned {
+		return nil
+	}
+	if steal {
+		if err := os.Remove(filepath.Join(s.RootPath, OwnerLock)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	f, err := s.Flock(OwnerLock, true, false)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.owned = f
+	s.mu.Unlock()
+	return nil
+}
+
+// Owned tells if a process holds the lock of Own on the directory dir.
+// Where flock is not available, it is never held
+func Owned(dir string) bool {
+	f, err := os.Open(filepath.Join(dir, OwnerLock))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return flock(f, false, false) == ErrLocked
+}
+
+// Disown releases the lock taken by Own
+func (s *Storage) Disown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owned != nil {
+		s.owned.Close()
+		s.owned = nil
+	}
+}
+
+func isLock(name string) bool {
+	return strings.HasSuffix(name, LockSuffix)
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"log"
+	"os"
+)
+
+// Mirror makes m a replica of s: the elements written to s, imported or
+// deleted are written to m, imported or deleted too, each in a file of
+// its own, and Read falls back to m when the file in s is missing or
+// corrupted, then writes it to s again. Cleanup removes both
+func (s *Storage) Mirror(m *Storage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mirror = m
+}
+
+// Replica returns the storage set by Mirror, or nil
+func (s *Storage) Replica() *Storage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mirror
+}
+
+// Replicate copies the elements ids of s to its replica, e.g. the ones
+// adopted from a directory. Nothing is linked: a file shared with s
+// would be damaged with it
+func (s *Storage) Replicate(ids ...int) error {
+	m := s.Replica()
+	if m == nil {
+		return nil
+	}
+	for _, id := range ids {
+		if err := m.copyFrom(s, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Storage) copyFrom(s *Storage, id int) error {
+	if e, ok := s.Entries([]int{id})[id]; ok {
+		f, err := os.Open(s.BlockPath(e.Block))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		b := make([]byte, e.Length)
+		if _, err := f.ReadAt(b, e.Offset); err != nil {
+			return err
+		}
+		return m.store(id, sealed{b: b}, false)
+	}
+
+	if n, ok := s.ChunkCounts([]int{id})[id]; ok {
+		m.AdoptChunks(map[int]int{id: n})
+	} else {
+		// the previous version may have been chunked
+		m.mu.Lock()
+		delete(m.chunks, id)
+		m.mu.Unlock()
+	}
+	var size int64
+	dst := m.Names(id)
+	for k, name := range s.Names(id) {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := write(m, dst[k], sealed{b: b}); err != nil {
+			return err
+		}
+		size += int64(len(b))
+	}
+	m.track(id, size)
+	return nil
+}
+
+// repair reads the element id from the replica of s after err,
+// the failure to read it from s, and writes it to s again
+func repair[T any](s *Storage, id int, err error) (T, error) {
+	m := s.Replica()
+	if m == nil {
+		var zero T
+		return zero, err
+	}
+	t, merr := read[T](m, id)
+	if merr != nil {
+		return t, err
+	}
+	if s.Packed(id) {
+		// the current block may be the lost one, a new block is safer
+		s.mu.Lock()
+		s.blocks.closeLog()
+		s.blocks.offset = blockSize
+		s.mu.Unlock()
+	}
+	if werr := put(s, id, t); werr != nil {
+		log.Printf("error repairing element %d of %s: %s", id, s.RootPath, werr.Error())
+	}
+	return t, nil
+}
+
+// Move renames the files of the elements of the replica after a
+// renumbering of the elements of s, ids maps old to new. Unlike the files
+// of s, the missing ones are skipped: a replica is a best effort
+func (m *Storage) Move(ids map[int]int) {
+	// two passes, so a new number never hits a file that isn't renamed yet
+	names := make(map[int][]string, len(ids))
+	for old := range ids {
+		names[old] = m.Names(old)
+		for _, name := range names[old] {
+			os.Rename(name, name+".move")
+		}
+	}
+	m.mu.Lock()
+	sizes := m.sizes
+	m.sizes = make(map[int]int64, len(ids))
+	for old, id := range ids {
+		m.sizes[id] = sizes[old]
+	}
+	m.mu.Unlock()
+	m.Renumber(ids)
+	for old, id := range ids {
+		for k, name := range m.Names(id) {
+			if k < len(names[old]) {
+				os.Rename(names[old][k]+".move", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnencodable is returned by Probe for a value that can't be stored
+var ErrUnencodable = errors.New("element type can't be stored")
+
+var (
+	gobEncoder      = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	binaryMarshaler = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// Probe checks that t survives being stored with gob: it is encoded and
+// decoded like an element and must come back equal, see reflect.DeepEqual, so
+// its state may not be in unexported fields. The channel and func
+// fields, which gob skips, are reported too, unless the type encodes
+// itself. The errors wrap ErrUnencodable
+func Probe[T any](t T) error {
+	// the other codecs store any value
+	if CodecOf[T]() != codecGob.String() {
+		return nil
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if path := unstored(typ, typ.String(), map[reflect.Type]bool{}); path != "" {
+		return fmt.Errorf("%w: %s is never stored", ErrUnencodable, path)
+	}
+	// a gob payload follows its header
+	e, buf, err := encode(t)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnencodable, err)
+	}
+	defer putBuffer(buf)
+	payload, c, err := verify("probe", e.b)
+	if err == nil {
+		var back T
+		if back, err = decode[T](payload, c); err == nil && !reflect.DeepEqual(back, t) {
+			err = fmt.Errorf("%v comes back as %v", t, back)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUnencodable, typ, err)
+	}
+	return nil
+}
+
+// unstored returns the path of a value of t gob doesn't store, "" if none
+func unstored(t reflect.Type, path string, seen map[reflect.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+	for _, i := range []reflect.Type{gobEncoder, binaryMarshaler} {
+		if t.Implements(i) || reflect.PointerTo(t).Implements(i) {
+			return ""
+		}
+	}
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return path
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return unstored(t.Elem(), path, seen)
+	case reflect.Map:
+		if p := unstored(t.Key(), path, seen); p != "" {
+			return p
+		}
+		return unstored(t.Elem(), path, seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if p := unstored(f.Type, path+"."+f.Name, seen); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
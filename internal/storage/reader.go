@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// OpenReader streams the element id, which must be a []byte stored raw,
+// without loading it in memory. The checksum is verified at the end
+// of the stream: the last Read fails with ErrCorrupted on a mismatch
+func OpenReader(s *Storage, id int) (io.ReadCloser, error) {
+	s.mu.Lock()
+	e, packed := s.blocks.index[id]
+	_, chunked := s.chunks[id]
+	n := s.chunks[id]
+	s.mu.Unlock()
+
+	if chunked {
+		r := &unchunker{s: s, id: id, n: n}
+		if err := r.fill(); err != nil {
+			return nil, err
+		}
+		if r.codec != codecRaw {
+			return nil, ErrNotRaw
+		}
+		return r, nil
+	}
+
+	fname, offset := s.Path(id), int64(0)
+	if packed {
+		fname, offset = s.BlockPath(e.Block), e.Offset
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s: %s", ErrCorrupted, fname, err.Error())
+	}
+	length := binary.LittleEndian.Uint64(header[0:8])
+	if length&codecMask != uint64(codecRaw) {
+		f.Close()
+		return nil, ErrNotRaw
+	}
+	return &checkedReader{
+		name: fname,
+		f:    f,
+		r:    io.NewSectionReader(f, offset+headerSize, int64(length&^codecMask)),
+		left: int64(length &^ codecMask),
+		want: binary.LittleEndian.Uint32(header[8:12]),
+		crc:  crc32.NewIEEE(),
+	}, nil
+}
+
+// checkedReader verifies the checksum of a payload as it is read
+type checkedReader struct {
+	name string
+	f    *os.File
+	r    io.Reader
+	left int64
+	want uint32
+	crc  hash.Hash32
+}
+
+func (r *checkedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+	r.left -= int64(n)
+	if err == io.EOF {
+		if r.left > 0 {
+			return n, fmt.Errorf("%w: %s is truncated", ErrCorrupted, r.name)
+		}
+		if r.crc.Sum32() != r.want {
+			return n, fmt.Errorf("%w: %s checksum mismatch", ErrCorrupted, r.name)
+		}
+	}
+	return n, err
+}
+
+func (r *checkedReader) Close() error {
+	return r.f.Close()
+}
+
+// Payload returns the verified payload of the element id and its codec:
+// "gob", "raw" for a []byte or "binary", see encodeFast
+func Payload(s *Storage, id int) ([]byte, string, error) {
+	s.mu.Lock()
+	n, chunked := s.chunks[id]
+	s.mu.Unlock()
+
+	var b []byte
+	var c codec
+	var err error
+	if chunked {
+		r := &unchunker{s: s, id: id, n: n}
+		defer r.Close()
+		if err = r.fill(); err == nil {
+			c = r.codec
+			b, err = io.ReadAll(r)
+		}
+	} else if p, pc, packed, e := s.unpack(id); packed {
+		b, c, err = p, pc, e
+	} else {
+		var buf *bytes.Buffer
+		if b, c, buf, err = load(s.Path(id)); err == nil {
+			// handed out
+			b = bytes.Clone(b)
+			putBuffer(buf)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return b, c.String(), nil
+}
+
+func (c codec) String() string {
+	switch c {
+	case codecRaw:
+		return "raw"
+	case codecBinary:
+		return "binary"
+	}
+	return "gob"
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CopyRecord writes the element id to w as it is stored, without decoding
+// it: its header, with the length and the checksum of the payload, then
+// the payload, see ReadRecord. A packed element is copied out of its
+// block file, a chunked one gets the header of its whole payload
+func (s *Storage) CopyRecord(w io.Writer, id int) (int64, error) {
+	s.mu.Lock()
+	e, packed := s.blocks.index[id]
+	n, chunked := s.chunks[id]
+	s.mu.Unlock()
+
+	if chunked {
+		r := &unchunker{s: s, id: id, n: n}
+		defer r.Close()
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+		buf := getBuffer()
+		defer putBuffer(buf)
+		var header [headerSize]byte
+		buf.Write(header[:])
+		if _, err := buf.ReadFrom(r); err != nil {
+			return 0, err
+		}
+		seal(buf.Bytes(), r.codec)
+		return buf.WriteTo(w)
+	}
+
+	fname, offset := s.Path(id), int64(0)
+	if packed {
+		fname, offset = s.BlockPath(e.Block), e.Offset
+	}
+	f, err := os.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	if _, err := f.ReadAt(header, offset); err != nil {
+		return 0, fmt.Errorf("%w: %s: %s", ErrCorrupted, fname, err.Error())
+	}
+	size := headerSize + int64(binary.LittleEndian.Uint64(header[0:8])&^codecMask)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	// a file read with a limit, which a socket or a file copies in the kernel
+	return io.Copy(w, io.LimitReader(f, size))
+}
+
+// WriteRecord encodes t and writes it to w like CopyRecord
+func WriteRecord[T any](w io.Writer, t T) (int64, error) {
+	e, buf, err := encode(t)
+	if err != nil {
+		return 0, err
+	}
+	defer putBuffer(buf)
+	return e.writeTo(w)
+}
+
+// ReadRecord reads and decodes the next record written by CopyRecord or
+// WriteRecord from r, returning io.EOF at the end of r
+func ReadRecord[T any](r io.Reader) (T, error) {
+	var t T
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated record", ErrCorrupted)
+		}
+		return t, err
+	}
+	n := binary.LittleEndian.Uint64(header[0:8]) &^ codecMask
+	// the payload is handed out as a []byte
+	b := make([]byte, headerSize+n)
+	copy(b, header)
+	if _, err := io.ReadFull(r, b[headerSize:]); err != nil {
+		return t, fmt.Errorf("%w: truncated record: %s", ErrCorrupted, err.Error())
+	}
+	payload, c, err := verify("record", b)
+	if err != nil {
+		return t, err
+	}
+	return decode[T](payload, c)
+}
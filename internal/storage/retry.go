@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// SetRetry makes the writes, the reads and the removals of the element
+// files retry the transient failures, see Transient: up to attempts
+// tries in all, waiting backoff before the second one and twice as
+// long before each of the next ones. attempts <= 1 disables the retries
+func (s *Storage) SetRetry(attempts int, backoff time.Duration) {
+	s.attempts, s.backoff = attempts, backoff
+}
+
+// Transient tells whether err may go away on its own, e.g. a timeout
+// or a stale handle of a network volume, rather than a missing or
+// corrupted file, a full disk or an element that can't be encoded
+func Transient(err error) bool {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.EIO, syscall.ESTALE, syscall.EBUSY:
+			return true
+		}
+		return errno.Temporary()
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// DiskFull tells whether err is due to a full volume
+func DiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// retry runs fn until it succeeds, fails for good or runs out of attempts
+func (s *Storage) retry(fn func() error) error {
+	err := fn()
+	wait := s.backoff
+	for k := 1; k < s.attempts && err != nil && Transient(err); k++ {
+		time.Sleep(wait)
+		wait *= 2
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Spread tells which root path gets the file of a new element, see Roots
+type Spread int
+
+const (
+	// the root paths take turns
+	SpreadRoundRobin Spread = iota
+	// the root path with the most free space, taking turns on a tie
+	SpreadFreeSpace
+)
+
+// how long the free space of the root paths is trusted
+const freeSpaceTTL = time.Second
+
+// roots are the directories besides the root path holding element files
+type roots struct {
+	dirs   []string
+	spread Spread
+	// the index in dirs+1 of the directory of the placed elements,
+	// the root path is 0
+	placed map[int]int
+	next   int
+	free   []int64
+	freeAt time.Time
+}
+
+// Roots spreads the files of the elements over randomly named subdirs
+// of the paths and the root path, according to spread. The block files
+// stay in the root path. It must be called before Shard and before any
+// file is written
+func (s *Storage) Roots(paths []string, spread Spread) error {
+	dirs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err == nil && !stat.IsDir() {
+			err = fmt.Errorf("%s is not a directory", path)
+		}
+		var dir string
+		if err == nil {
+			dir, err = os.MkdirTemp(path, Prefix)
+		}
+		if err == nil {
+			if err = os.Chmod(dir, s.dirMode); err != nil {
+				os.RemoveAll(dir)
+			}
+		}
+		if err != nil {
+			for _, dir := range dirs {
+				os.RemoveAll(dir)
+			}
+			return err
+		}
+		dirs = append(dirs, dir)
+	}
+	s.AdoptRoots(dirs, spread, nil)
+	return nil
+}
+
+// AdoptRoots takes over the dirs created by Roots with the placement
+// of their elements, as returned by RootDirs and Placed
+func (s *Storage) AdoptRoots(dirs []string, spread Spread, placed map[int]int) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	s.roots = roots{dirs: dirs, spread: spread, placed: placed}
+	if s.roots.placed == nil {
+		s.roots.placed = make(map[int]int)
+	}
+}
+
+// RootDirs returns the dirs created by Roots
+func (s *Storage) RootDirs() []string {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	return s.roots.dirs
+}
+
+// Placed returns the directories of the elements among ids that are
+// not in the root path, as indices in RootDirs plus one
+func (s *Storage) Placed(ids []int) map[int]int {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	placed := make(map[int]int)
+	for _, id := range ids {
+		if r, ok := s.roots.placed[id]; ok && r > 0 {
+			placed[id] = r
+		}
+	}
+	return placed
+}
+
+// dirs returns the root path followed by the dirs of Roots
+func (s *Storage) dirs() []string {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	return append([]string{s.RootPath}, s.roots.dirs...)
+}
+
+// dir returns the directory of the files of the element id
+func (s *Storage) dir(id int) string {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if r := s.roots.placed[id]; r > 0 {
+		return s.roots.dirs[r-1]
+	}
+	return s.RootPath
+}
+
+// place picks the directory of the new element id, if it has none yet
+func (s *Storage) place(id int) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if len(s.roots.dirs) == 0 {
+		return
+	}
+	if _, ok := s.roots.placed[id]; ok {
+		return
+	}
+	n := len(s.roots.dirs) + 1
+	r := s.roots.next % n
+	s.roots.next++
+	if s.roots.spread == SpreadFreeSpace {
+		if time.Since(s.roots.freeAt) > freeSpaceTTL {
+			s.roots.free = make([]int64, n)
+			for i := range s.roots.free {
+				dir := s.RootPath
+				if i > 0 {
+					dir = s.roots.dirs[i-1]
+				}
+				s.roots.free[i] = freeSpace(dir)
+			}
+			s.roots.freeAt = time.Now()
+		}
+		for i := range s.roots.free {
+			if s.roots.free[i] > s.roots.free[r] {
+				r = i
+			}
+		}
+	}
+	s.roots.placed[id] = r
+}
+
+// unplace forgets the directory of the removed element id
+func (s *Storage) unplace(id int) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	delete(s.roots.placed, id)
+}
+
+// renumberRoots changes the ids of the placed elements, see Renumber
+func (s *Storage) renumberRoots(ids map[int]int) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if len(s.roots.dirs) == 0 {
+		return
+	}
+	placed := make(map[int]int, len(ids))
+	for old, id := range ids {
+		if r, ok := s.roots.placed[old]; ok {
+			placed[id] = r
+		}
+	}
+	s.roots.placed = placed
+}
+
+// RootUsage is the usage of a directory holding element files
+type RootUsage struct {
+	Path string
+	// the number of elements and the size of their files
+	Files int
+	Bytes int64
+	// the space left on its volume, 0 if unknown
+	Free int64
+}
+
+// Usage returns the usage of the root path followed by the dirs of Roots
+func (s *Storage) Usage() []RootUsage {
+	dirs := s.dirs()
+	usage := make([]RootUsage, len(dirs))
+	for i, dir := range dirs {
+		usage[i] = RootUsage{Path: dir, Free: freeSpace(dir)}
+	}
+	s.mu.Lock()
+	sizes := make(map[int]int64, len(s.sizes))
+	for id, size := range s.sizes {
+		sizes[id] = size
+	}
+	s.mu.Unlock()
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	for id, size := range sizes {
+		r := s.roots.placed[id]
+		usage[r].Files++
+		usage[r].Bytes += size
+	}
+	return usage
+}
+
+// fileDirs lists the root path, the dirs of Roots and their shards
+func (s *Storage) fileDirs() []string {
+	var dirs []string
+	for _, root := range s.dirs() {
+		dirs = append(dirs, root)
+		for i := 0; i < s.shards; i++ {
+			dirs = append(dirs, filepath.Join(root, shardName(i)))
+		}
+	}
+	return dirs
+}
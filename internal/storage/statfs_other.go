@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd || dragonfly)
+
+package storage
+
+func freeSpace(dir string) int64 {
+	return 0
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package storage
+
+import "syscall"
+
+// freeSpace returns the bytes available on the volume of dir, 0 if unknown
+func freeSpace(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
@@ -0,0 +1,985 @@
+// Package storage is the disk part shared by the disk backed containers:
+// a randomly named subdir of the root path holding one gob file per element
+// and a go routine removing the files that are no longer needed
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const GetError = "could not retrive element: %w"
+const CLEANUP = -999999
+
+// Prefix of the randomly named subdirs created by New
+const Prefix = "diskslice"
+
+// TmpSuffix ends the names of the files that are being written
+const TmpSuffix = ".tmp"
+
+// ErrCorrupted is returned when a stored element fails its checksum
+// or length verification: bit rot or a partial write
+var ErrCorrupted = errors.New("element is corrupted")
+
+// Durability tells when the written files are synced to the stable storage
+type Durability int
+
+const (
+	// the files are left to the OS, a crash may lose recent writes
+	DurabilityNone Durability = iota
+	// the files written since the last Flush are synced by Flush
+	DurabilityBatch
+	// every file and its directory are synced before Save returns
+	DurabilityAlways
+)
+
+type Storage struct {
+	RootPath string
+	// number of subdirs the files are spread over, 0 keeps them in RootPath
+	shards   int
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	// when the written files reach the stable storage
+	durability Durability
+	mu         sync.Mutex
+	// files written since the last Flush, with DurabilityBatch
+	dirty map[string]bool
+	// sizes of the element files and their sum
+	sizes map[int]int64
+	used  int64
+	// ids whose files were removed, see ReuseIDs
+	reuse bool
+	free  []int
+	// the packed elements, see Pack
+	blocks blocks
+	// the number of chunks of the chunked elements, see Chunk
+	chunkSize int
+	chunks    map[int]int
+	// the batches of removals waiting for the cleaners, see Remove.
+	// The queue is unbounded, so the removals never block
+	qmu    sync.Mutex
+	qcond  *sync.Cond
+	queue  [][]int
+	closed bool
+	// the running cleaners, see Cleaners, and closed once they are
+	// done after Cleanup and the directory is removed
+	cleaners sync.WaitGroup
+	workers  int
+	stopped  chan struct{}
+	// removals not done yet
+	wg      sync.WaitGroup
+	pending atomic.Int64
+	// the failed disk operations, see Errors
+	errs atomic.Int64
+	// the tries of the transient failures, see SetRetry
+	attempts int
+	backoff  time.Duration
+	// the bounds of the disk operations, see SetLimits
+	limits throttle
+	// the longest a disk operation may take, see SetTimeout
+	timeout time.Duration
+	// the shared lock of a directory of another process, see Attach
+	attached *os.File
+	// the lock of the owned directory, see Own
+	owned *os.File
+	// the directory outlives Cleanup, see Keep
+	kept bool
+	// the replica, see Mirror
+	mirror *Storage
+	// wraps the removals of the cleaner, see TraceRemovals
+	traceRemoval func(id int) func(err error)
+	// the other directories of the element files, see Roots.
+	// Path takes rmu rather than mu, which may be held
+	rmu   sync.Mutex
+	roots roots
+}
+
+// New verifies that rootPath is a writable directory,
+// creates a randomly named subdir in it and starts the cleaner
+func New(rootPath string) (*Storage, error) {
+	stat, err := os.Stat(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", rootPath)
+	}
+
+	// verify permissions
+	rnd := rand.Intn(100)
+	testFname := filepath.Join(rootPath, fmt.Sprintf("probe-%d", rnd))
+	if err = os.WriteFile(testFname, []byte("Hello"), 0600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(testFname)
+
+	rootPath, err = os.MkdirTemp(rootPath, Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(rootPath)
+}
+
+// Open adopts an existing directory and starts the cleaner
+func Open(rootPath string) (*Storage, error) {
+	stat, err := os.Stat(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", rootPath)
+	}
+
+	s := &Storage{
+		RootPath: rootPath,
+		fileMode: 0600,
+		dirMode:  0700,
+		workers:  1,
+		stopped:  make(chan struct{}),
+	}
+	s.qcond = sync.NewCond(&s.qmu)
+
+	s.cleaners.Add(1)
+	go s.clean()
+	go func() {
+		// the cleaners are done once the queue is drained after Cleanup
+		s.cleaners.Wait()
+		s.mu.Lock()
+		s.blocks.closeLog()
+		keep := s.attached != nil || s.kept
+		s.mu.Unlock()
+		if !keep {
+			for _, dir := range s.dirs() {
+				os.RemoveAll(dir)
+			}
+		}
+		close(s.stopped)
+	}()
+
+	return s, nil
+}
+
+// Shard spreads the files over n subdirs of the root path and of the
+// dirs of Roots, creating the subdirs. It must be called before any file
+// is written
+func (s *Storage) Shard(n int) error {
+	for _, root := range s.dirs() {
+		for i := 0; i < n; i++ {
+			dir := filepath.Join(root, shardName(i))
+			if err := os.MkdirAll(dir, s.dirMode); err != nil {
+				return err
+			}
+			if err := os.Chmod(dir, s.dirMode); err != nil {
+				return err
+			}
+		}
+	}
+	s.shards = max(n, 0)
+	return nil
+}
+
+// SetModes sets the permissions of the files written from now on
+// and of the directories, 0 keeps the default: 0600 and 0700
+func (s *Storage) SetModes(file, dir os.FileMode) error {
+	if file != 0 {
+		s.fileMode = file
+	}
+	if dir == 0 {
+		return nil
+	}
+	s.dirMode = dir
+	for _, d := range s.fileDirs() {
+		if err := os.Chmod(d, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetDurability sets when the files written from now on are synced
+func (s *Storage) SetDurability(d Durability) {
+	s.durability = d
+}
+
+// Flush syncs the files written since the last Flush and their directories,
+// with DurabilityBatch, in the replica too
+func (s *Storage) Flush() error {
+	s.mu.Lock()
+	dirty := s.dirty
+	s.dirty = nil
+	m := s.mirror
+	s.mu.Unlock()
+
+	var errs []error
+	if m != nil {
+		errs = append(errs, m.Flush())
+	}
+	dirs := make(map[string]bool)
+	for fname := range dirty {
+		// removed since then
+		if err := syncFile(fname); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		dirs[filepath.Dir(fname)] = true
+	}
+	for dir := range dirs {
+		if err := syncFile(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func syncFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// Modes returns the permissions of the files and of the directories
+func (s *Storage) Modes() (file, dir os.FileMode) {
+	return s.fileMode, s.dirMode
+}
+
+// Shards returns the number of subdirs set by Shard
+func (s *Storage) Shards() int {
+	return s.shards
+}
+
+func shardName(i int) string {
+	return fmt.Sprintf("%02d", i)
+}
+
+// Path returns the name of the file holding the element id
+func (s *Storage) Path(id int) string {
+	dir := s.dir(id)
+	if s.shards > 0 {
+		return filepath.Join(dir, shardName(id%s.shards), fmt.Sprintf("%d", id))
+	}
+	return filepath.Join(dir, fmt.Sprintf("%d", id))
+}
+
+// Files lists the paths of the files in the root path, the dirs of Roots
+// and their subdirs, but the lock files
+func (s *Storage) Files() ([]string, error) {
+	var files []string
+	for _, dir := range s.fileDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && !isLock(e.Name()) {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+// Remove schedules the removal of the files by the cleaner.
+// The ids are handed over as one batch, so removing many files
+// doesn't block on the cleaner. ids is not retained
+func (s *Storage) Remove(ids ...int) {
+	if len(ids) == 0 {
+		return
+	}
+	s.mu.Lock()
+	attached := s.attached != nil
+	s.mu.Unlock()
+	if attached {
+		return
+	}
+	s.enqueue(slices.Clone(ids))
+}
+
+// cleanBatch bounds the removals a cleaner takes at once,
+// so the cleaners share the large batches
+const cleanBatch = 256
+
+// Cleaners runs n cleaners, removing the files in parallel:
+// on some file systems a removal takes a while. There is one
+// by default. Only more cleaners can be started
+func (s *Storage) Cleaners(n int) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	if s.closed {
+		return
+	}
+	for ; s.workers < n; s.workers++ {
+		s.cleaners.Add(1)
+		go s.clean()
+	}
+}
+
+// clean removes the files handed over by Remove until Cleanup
+func (s *Storage) clean() {
+	defer s.cleaners.Done()
+	for ids := s.next(); ids != nil; ids = s.next() {
+		for _, id := range ids {
+			s.mu.Lock()
+			trace := s.traceRemoval
+			s.mu.Unlock()
+			var end func(error)
+			if trace != nil {
+				end = trace(id)
+			}
+			fpath := s.Path(id)
+			err := s.Delete(id)
+			if end != nil {
+				end(err)
+			}
+			if err != nil {
+				s.errs.Add(1)
+				log.Printf("error removing file %s: %s", fpath, err.Error())
+			}
+			s.pending.Add(-1)
+			s.wg.Done()
+		}
+	}
+}
+
+// enqueue hands a batch of removals over to the cleaners
+func (s *Storage) enqueue(ids []int) {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	if s.closed {
+		// the whole directory goes
+		return
+	}
+	s.wg.Add(len(ids))
+	s.pending.Add(int64(len(ids)))
+	s.queue = append(s.queue, ids)
+	s.qcond.Signal()
+}
+
+// next waits for the next removals, up to cleanBatch of them,
+// nil once the queue is drained after Cleanup
+func (s *Storage) next() []int {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	for len(s.queue) == 0 && !s.closed {
+		s.qcond.Wait()
+	}
+	if len(s.queue) == 0 {
+		return nil
+	}
+	ids := s.queue[0]
+	if len(ids) > cleanBatch {
+		s.queue[0] = ids[cleanBatch:]
+		ids = ids[:cleanBatch]
+	} else {
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		if len(s.queue) == 0 {
+			s.queue = nil
+		}
+	}
+	if len(s.queue) > 0 {
+		// for another cleaner
+		s.qcond.Signal()
+	}
+	return ids
+}
+
+// Delete removes the file of the element id right away,
+// and the one of the replica
+func (s *Storage) Delete(id int) error {
+	s.wait(1, 0)
+	return s.bounded(context.Background(), func() error { return s.delete(id) }, nil)
+}
+
+func (s *Storage) delete(id int) error {
+	s.mu.Lock()
+	var err error
+	var names []string
+	if _, packed := s.blocks.index[id]; packed {
+		if b, ok := s.blocks.drop(id); ok {
+			err = s.retry(func() error { return os.Remove(s.BlockPath(b)) })
+		}
+	} else {
+		names = s.names(id)
+		delete(s.chunks, id)
+	}
+	s.used -= s.sizes[id]
+	delete(s.sizes, id)
+	reuse, m := s.reuse, s.mirror
+	s.mu.Unlock()
+
+	// without the lock, so the cleaners remove files in parallel
+	for _, name := range names {
+		if e := s.retry(func() error { return os.Remove(name) }); e != nil && err == nil {
+			err = e
+		}
+	}
+	if reuse && (err == nil || os.IsNotExist(err)) {
+		s.mu.Lock()
+		s.free = append(s.free, id)
+		s.mu.Unlock()
+	}
+	s.unplace(id)
+	if m != nil {
+		if e := m.Delete(id); e != nil && !os.IsNotExist(e) {
+			log.Printf("error removing the replica of %d: %s", id, e.Error())
+		}
+	}
+	return err
+}
+
+// ReuseIDs makes the ids of the removed files available to FreeID.
+// An id becomes free once its file is actually removed, so a new file
+// with that id is never hit by a pending removal
+func (s *Storage) ReuseIDs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reuse = true
+}
+
+// FreeID returns an id whose file was removed, the most recent one
+func (s *Storage) FreeID() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.free) == 0 {
+		return 0, false
+	}
+	id := s.free[len(s.free)-1]
+	s.free = s.free[:len(s.free)-1]
+	return id, true
+}
+
+// Release gives back an id that was not written after all
+func (s *Storage) Release(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reuse {
+		s.free = append(s.free, id)
+	}
+}
+
+// Used returns the size of the element files
+func (s *Storage) Used() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// Recount sets the element files to ids, taking their sizes from the disk,
+// after the files were created or renamed behind the back of Write.
+// The free ids are forgotten
+func (s *Storage) Recount(ids []int) error {
+	sizes := make(map[int]int64, len(ids))
+	var used int64
+	entries := s.Entries(ids)
+	for _, id := range ids {
+		if e, ok := entries[id]; ok {
+			sizes[id] = e.Length
+			used += e.Length
+			continue
+		}
+		for _, name := range s.Names(id) {
+			info, err := os.Stat(name)
+			if err != nil {
+				return err
+			}
+			sizes[id] += info.Size()
+			used += info.Size()
+		}
+	}
+	s.mu.Lock()
+	s.sizes, s.used = sizes, used
+	s.free = nil
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Storage) track(id int, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sizes == nil {
+		s.sizes = make(map[int]int64)
+	}
+	s.used += size - s.sizes[id]
+	s.sizes[id] = size
+}
+
+// Wait waits until the cleaner has removed the files scheduled so far
+func (s *Storage) Wait() {
+	s.wg.Wait()
+}
+
+// TraceRemovals sets the function called before the cleaner removes
+// the files of an element, it returns the one called after
+func (s *Storage) TraceRemovals(start func(id int) func(err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traceRemoval = start
+}
+
+// Describe returns the size of the element id and where it lives:
+// "block", "chunks" or "file"
+func (s *Storage) Describe(id int) (int64, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backend := "file"
+	if _, ok := s.blocks.index[id]; ok {
+		backend = "block"
+	} else if _, ok := s.chunks[id]; ok {
+		backend = "chunks"
+	}
+	return s.sizes[id], backend
+}
+
+// written returns the size of the files of ids
+func (s *Storage) written(ids ...int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, id := range ids {
+		n += s.sizes[id]
+	}
+	return n
+}
+
+// Pending returns the number of removals the cleaner hasn't done yet
+func (s *Storage) Pending() int {
+	return int(s.pending.Load())
+}
+
+// Errors returns the number of failed writes, reads and removals
+// of element files, the repaired reads aside
+func (s *Storage) Errors() int64 {
+	return s.errs.Load()
+}
+
+// Cleanup removes the root directory and the replica, releasing its lock,
+// and stops the cleaner. An attached directory is only unlocked, see Attach
+func (s *Storage) Cleanup() {
+	s.mu.Lock()
+	if s.attached != nil {
+		s.attached.Close()
+	}
+	if s.owned != nil {
+		s.owned.Close()
+	}
+	m := s.mirror
+	s.mu.Unlock()
+	if m != nil {
+		m.Cleanup()
+	}
+	s.qmu.Lock()
+	s.closed = true
+	s.qcond.Broadcast()
+	s.qmu.Unlock()
+}
+
+// Keep makes Cleanup leave the directory on the disk and only unlock it,
+// e.g. for Open to adopt it. The replica is removed as usual
+func (s *Storage) Keep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kept = true
+}
+
+// Drain waits until the cleaners stopped by Cleanup have done the
+// queued removals and the directory, and the one of the replica,
+// is removed
+func (s *Storage) Drain() {
+	<-s.stopped
+	if m := s.Replica(); m != nil {
+		m.Drain()
+	}
+}
+
+// Write stores t as the element id, and in the replica
+func Write[T any](s *Storage, id int, t T) error {
+	return WriteContext(context.Background(), s, id, t)
+}
+
+// WriteContext is Write bounded by ctx and the timeout of SetTimeout.
+// A write that is late goes on in the background
+func WriteContext[T any](ctx context.Context, s *Storage, id int, t T) error {
+	s.wait(1, 0)
+	err := s.bounded(ctx, func() error { return putMirrored(s, id, t) }, nil)
+	s.wait(0, s.written(id))
+	return err
+}
+
+// Spill is WriteContext for a new element id: the files of a late
+// write are removed once written, then id is released
+func Spill[T any](ctx context.Context, s *Storage, id int, t T) error {
+	s.wait(1, 0)
+	err := s.bounded(ctx, func() error { return putMirrored(s, id, t) }, func(err error) {
+		if err == nil {
+			s.Remove(id)
+		} else {
+			s.Release(id)
+		}
+	})
+	s.wait(0, s.written(id))
+	return err
+}
+
+// putMirrored writes the element id in s and in the replica
+func putMirrored[T any](s *Storage, id int, t T) error {
+	if err := s.retry(func() error { return put(s, id, t) }); err != nil {
+		s.errs.Add(1)
+		return err
+	}
+	if m := s.Replica(); m != nil {
+		return put(m, id, t)
+	}
+	return nil
+}
+
+func put[T any](s *Storage, id int, t T) error {
+	s.mu.Lock()
+	threshold, chunkSize, log := s.blocks.threshold, s.chunkSize, s.blocks.log
+	s.mu.Unlock()
+
+	var e sealed
+	var buf *bytes.Buffer
+	var err error
+	chunks := 0
+	size := 0
+	if chunkSize > 0 {
+		s.place(id)
+		w := &chunker{s: s, id: id, size: chunkSize, buf: make([]byte, headerSize, 512)}
+		scratch := getBuffer()
+		if payload, c, ok := encodeFast(scratch, t); ok {
+			w.codec = c
+			_, err = w.Write(payload)
+		} else {
+			err = gob.NewEncoder(w).Encode(&t)
+		}
+		if err == nil && w.n > 0 && len(w.buf) > headerSize {
+			err = w.flush()
+		}
+		putBuffer(scratch)
+		if err != nil {
+			w.abort()
+			return err
+		}
+		// fits a chunk
+		if w.n == 0 {
+			e = sealed{b: w.buf}
+			seal(e.b, w.codec)
+		}
+		chunks, size = w.n, w.written
+	} else if e, buf, err = encode(t); err != nil {
+		return err
+	} else {
+		defer putBuffer(buf)
+	}
+
+	if chunks > 0 {
+		s.mu.Lock()
+		s.supersede(id, false, chunks)
+		s.mu.Unlock()
+		s.track(id, int64(size))
+		return nil
+	}
+	return s.store(id, e, log || threshold > 0 && e.len() < threshold)
+}
+
+// WriteAll stores t as every element of ids, and in the replica.
+// t is encoded once, unless the elements are chunked
+func WriteAll[T any](s *Storage, ids []int, t T) error {
+	s.wait(len(ids), 0)
+	err := s.bounded(context.Background(), func() error {
+		if err := s.retry(func() error { return putAll(s, ids, t) }); err != nil {
+			s.errs.Add(1)
+			return err
+		}
+		if m := s.Replica(); m != nil {
+			return putAll(m, ids, t)
+		}
+		return nil
+	}, nil)
+	s.wait(0, s.written(ids...))
+	return err
+}
+
+func putAll[T any](s *Storage, ids []int, t T) error {
+	s.mu.Lock()
+	threshold, chunkSize, log := s.blocks.threshold, s.chunkSize, s.blocks.log
+	s.mu.Unlock()
+
+	if chunkSize > 0 {
+		for _, id := range ids {
+			if err := put(s, id, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	e, buf, err := encode(t)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	for _, id := range ids {
+		if err := s.store(id, e, log || threshold > 0 && e.len() < threshold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store writes the sealed element e to its own file or to a block file
+// if packed, replacing the previous version of the element id
+func (s *Storage) store(id int, e sealed, packed bool) error {
+	var err error
+	if packed {
+		err = s.pack(id, e)
+	} else {
+		s.place(id)
+		err = write(s, s.Path(id), e)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.supersede(id, packed, 0)
+	s.mu.Unlock()
+	s.track(id, int64(e.len()))
+	return nil
+}
+
+// LinkOrCopy hard links src to dst, or copies it if it can't
+// be linked, e.g. across file systems. The copy gets mode
+func LinkOrCopy(src, dst string, mode os.FileMode) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Import adds the element old of src to s as the element id, sharing
+// its files via hard links when possible. blocks maps the block files
+// of src to the ones of s: a block file is linked once for all the
+// elements imported with the same map. The replica gets a copy
+func (s *Storage) Import(src *Storage, old, id int, blocks map[int]int) error {
+	if err := s.importFrom(src, old, id, blocks); err != nil {
+		return err
+	}
+	return s.Replicate(id)
+}
+
+func (s *Storage) importFrom(src *Storage, old, id int, blocks map[int]int) error {
+	if e, ok := src.Entries([]int{old})[old]; ok {
+		b, linked := blocks[e.Block]
+		if !linked {
+			s.mu.Lock()
+			b = s.blocks.next
+			s.blocks.next++
+			s.mu.Unlock()
+			if err := LinkOrCopy(src.BlockPath(e.Block), s.BlockPath(b), s.fileMode); err != nil {
+				return err
+			}
+			blocks[e.Block] = b
+		}
+		s.mu.Lock()
+		s.blocks.add(id, Entry{Block: b, Offset: e.Offset, Length: e.Length})
+		s.mu.Unlock()
+		s.track(id, e.Length)
+		return nil
+	}
+
+	if n, ok := src.ChunkCounts([]int{old})[old]; ok {
+		s.AdoptChunks(map[int]int{id: n})
+	}
+	s.place(id)
+	var size int64
+	dst := s.Names(id)
+	for k, name := range src.Names(old) {
+		err := LinkOrCopy(name, dst[k], s.fileMode)
+		if err == nil {
+			var info os.FileInfo
+			if info, err = os.Stat(dst[k]); err == nil {
+				size += info.Size()
+			}
+		}
+		if err != nil {
+			for _, name := range dst[:k] {
+				os.Remove(name)
+			}
+			s.mu.Lock()
+			delete(s.chunks, id)
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.track(id, size)
+	return nil
+}
+
+// Read retrieves the element id. If its file is missing or corrupted,
+// the element is read from the replica and written again
+func Read[T any](s *Storage, id int) (T, error) {
+	return ReadContext[T](context.Background(), s, id)
+}
+
+// ReadContext is Read bounded by ctx and the timeout of SetTimeout
+func ReadContext[T any](ctx context.Context, s *Storage, id int) (T, error) {
+	s.wait(1, 0)
+	var t T
+	err := s.bounded(ctx, func() error {
+		err := s.retry(func() (err error) {
+			t, err = read[T](s, id)
+			return err
+		})
+		if err != nil {
+			if t, err = repair[T](s, id, err); err != nil {
+				s.errs.Add(1)
+			}
+		}
+		return err
+	}, nil)
+	if errors.Is(err, ErrTimeout) {
+		// still being read
+		var zero T
+		return zero, err
+	}
+	return t, err
+}
+
+func read[T any](s *Storage, id int) (T, error) {
+	if t, chunked, err := unchunk[T](s, id); chunked {
+		return t, err
+	}
+	payload, c, packed, err := s.unpack(id)
+	if !packed {
+		return Load[T](s.Path(id))
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf(GetError, err)
+	}
+	return decode[T](payload, c)
+}
+
+// Save stores t in the file fname, which belongs to s: it gets
+// the permissions and the durability of s.
+// The value is encoded to a temporary file which is then renamed,
+// so a crash can't leave a truncated file behind
+func Save[T any](s *Storage, fname string, t T) error {
+	e, buf, err := encode(t)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	return write(s, fname, e)
+}
+
+// write stores e in the file fname through a temporary file
+func write(s *Storage, fname string, e sealed) error {
+	f, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*"+TmpSuffix)
+	if err != nil {
+		return err
+	}
+	if err = f.Chmod(s.fileMode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err = e.writeTo(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if s.durability == DurabilityAlways {
+		if err = f.Sync(); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err = os.Rename(f.Name(), fname); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	switch s.durability {
+	case DurabilityAlways:
+		// the rename itself
+		return syncFile(filepath.Dir(fname))
+	case DurabilityBatch:
+		s.mu.Lock()
+		s.markDirty(fname)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// markDirty records fname for Flush, s.mu must be held
+func (s *Storage) markDirty(fname string) {
+	if s.dirty == nil {
+		s.dirty = make(map[string]bool)
+	}
+	s.dirty[fname] = true
+}
+
+// Load retrieves the value stored in the file fname
+func Load[T any](fname string) (T, error) {
+	payload, c, buf, err := load(fname)
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf(GetError, err)
+	}
+	// a raw payload becomes the element itself
+	if c != codecRaw {
+		defer putBuffer(buf)
+	}
+	return decode[T](payload, c)
+}
+
+// Check reads the element id and verifies its checksum without decoding it
+func Check(s *Storage, id int) error {
+	_, _, packed, err := s.unpack(id)
+	if packed {
+		return err
+	}
+	for _, name := range s.Names(id) {
+		_, _, buf, err := load(name)
+		if err != nil {
+			return err
+		}
+		putBuffer(buf)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	if err := Write(s, 1, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if x, err := Read[string](s, 1); err != nil || x != "hello" {
+		t.Errorf("Read() = %q, %v, want hello", x, err)
+	}
+
+	// gob can't encode channels: the failed write leaves nothing behind
+	if err := Write(s, 2, struct{ C chan int }{make(chan int)}); err == nil {
+		t.Errorf("Write() of a channel succeeded")
+	}
+	entries, err := os.ReadDir(s.RootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "1" {
+		for _, e := range entries {
+			t.Errorf("unexpected file %s", e.Name())
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	// more than the cleaner buffers, in one batch
+	ids := make([]int, 5000)
+	for i := range ids {
+		ids[i] = i
+		if err := Write(s, i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Remove(ids...)
+	ids[0] = -1 // not retained
+	s.Wait()
+
+	entries, err := os.ReadDir(s.RootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d files left", len(entries))
+	}
+}
+
+func TestCleaners(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Cleaners(4)
+
+	ids := make([]int, 3000)
+	for i := range ids {
+		ids[i] = i
+		if err := Write(s, i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Remove(ids[:1000]...)
+	s.Remove(ids[1000:]...)
+	s.Wait()
+	if entries, _ := os.ReadDir(s.RootPath); len(entries) != 0 {
+		t.Errorf("%d files left", len(entries))
+	}
+
+	s.Cleanup()
+	s.Drain()
+	if _, err := os.Stat(s.RootPath); !os.IsNotExist(err) {
+		t.Errorf("Stat() = %v after Drain, want the directory removed", err)
+	}
+}
+
+func TestRemoveAfterCleanup(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		Write(s, i, i)
+	}
+	s.Cleanup()
+
+	// neither blocks on the stopped cleaner
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5000; i++ {
+			s.Remove(i % 10)
+		}
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Remove blocked after Cleanup")
+	}
+}
+
+func TestDurability(t *testing.T) {
+	for _, d := range []Durability{DurabilityNone, DurabilityBatch, DurabilityAlways} {
+		s, err := New(os.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetDurability(d)
+		for i := 0; i < 10; i++ {
+			if err := Write(s, i, i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if d == DurabilityBatch && len(s.dirty) != 10 {
+			t.Errorf("%d dirty files, want 10", len(s.dirty))
+		}
+		// a removed file is not an error
+		os.Remove(s.Path(3))
+		if err := s.Flush(); err != nil {
+			t.Errorf("durability %d: Flush() = %v", d, err)
+		}
+		if len(s.dirty) != 0 {
+			t.Errorf("%d dirty files after Flush", len(s.dirty))
+		}
+		if x, err := Read[int](s, 5); err != nil || x != 5 {
+			t.Errorf("Read() = %d, %v, want 5", x, err)
+		}
+		s.Cleanup()
+	}
+}
+
+func TestBufferReuse(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	if err := Write(s, 1, []byte("raw")); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := Read[[]byte](s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the buffers recycled by the later reads and writes
+	// must not be the one handed out
+	for i := 2; i < 100; i++ {
+		if err := Write(s, i, "overwritten"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read[string](s, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(raw) != "raw" {
+		t.Errorf("Read() = %q, want raw", raw)
+	}
+}
+
+func BenchmarkWriteRead(b *testing.B) {
+	s, err := New(b.TempDir())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Cleanup()
+	type element struct {
+		Name string
+		Tags []string
+	}
+	v := element{"name", []string{"a", "b"}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := Write(s, i%100, v); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Read[element](s, i%100); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	s, err := New(os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.SetRetry(3, time.Millisecond)
+
+	eio := &os.PathError{Op: "read", Path: "x", Err: syscall.EIO}
+	for _, c := range []struct {
+		name  string
+		fails int
+		err   error
+		calls int
+		ok    bool
+	}{
+		{"recovers", 2, eio, 3, true},
+		{"gives up", 5, eio, 3, false},
+		{"missing", 5, &os.PathError{Op: "open", Path: "x", Err: syscall.ENOENT}, 1, false},
+		{"full", 5, &os.PathError{Op: "write", Path: "x", Err: syscall.ENOSPC}, 1, false},
+	} {
+		calls := 0
+		err := s.retry(func() error {
+			calls++
+			if calls <= c.fails {
+				return c.err
+			}
+			return nil
+		})
+		if calls != c.calls || (err == nil) != c.ok {
+			t.Errorf("%s: %d calls, %v, want %d calls", c.name, calls, err, c.calls)
+		}
+	}
+}
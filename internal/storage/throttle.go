@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottleState tells how much the limits of SetLimits slowed
+// the disk operations down
+type ThrottleState struct {
+	// the operations waiting now
+	Waiting int
+	// the total time waited so far
+	Waited time.Duration
+}
+
+// limiter is a token bucket holding up to one second of its rate
+type limiter struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take takes n tokens and returns how long to wait for them.
+// The bucket may go in debt, the next takes wait for it
+func (l *limiter) take(n float64, now time.Time) time.Duration {
+	if l.rate <= 0 || n == 0 {
+		return 0
+	}
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.rate)
+	l.last = now
+	l.tokens -= n
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// throttle holds the limits of the disk operations
+type throttle struct {
+	mu      sync.Mutex
+	bytes   limiter
+	ops     limiter
+	waiting int
+	waited  time.Duration
+}
+
+// SetLimits bounds the bytes written per second and the writes, reads
+// and removals of element files per second, 0 for no bound. A write
+// waits for the bytes it wrote once done, so a large element delays
+// the next operations rather than itself
+func (s *Storage) SetLimits(bytesPerSec int64, iops int) {
+	now := time.Now()
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	s.limits.bytes = limiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: now}
+	s.limits.ops = limiter{rate: float64(iops), tokens: float64(iops), last: now}
+}
+
+// Throttle returns how much the limits slowed the disk operations down
+func (s *Storage) Throttle() ThrottleState {
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	return ThrottleState{Waiting: s.limits.waiting, Waited: s.limits.waited}
+}
+
+// wait waits for ops operations and n written bytes, see SetLimits
+func (s *Storage) wait(ops int, n int64) {
+	t := &s.limits
+	t.mu.Lock()
+	now := time.Now()
+	d := max(t.ops.take(float64(ops), now), t.bytes.take(float64(n), now))
+	if d <= 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.waiting++
+	t.waited += d
+	t.mu.Unlock()
+
+	time.Sleep(d)
+	t.mu.Lock()
+	t.waiting--
+	t.mu.Unlock()
+}
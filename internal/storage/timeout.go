@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is returned by the disk operations that take longer than
+// the timeout of SetTimeout or the deadline of their context
+var ErrTimeout = errors.New("disk operation timed out")
+
+// SetTimeout bounds the writes, the reads and the removals of the element
+// files to d, e.g. on a hung network volume, 0 for no bound. The late
+// operations go on in the background, the callers get ErrTimeout
+func (s *Storage) SetTimeout(d time.Duration) {
+	s.timeout = d
+}
+
+// bounded runs op until the timeout of s or ctx expires, then returns
+// ErrTimeout, wrapping the error of ctx if it expired first. A syscall
+// can't be interrupted: op goes on in the background and late, if any,
+// gets its error once it is done
+func (s *Storage) bounded(ctx context.Context, op func() error, late func(error)) error {
+	if s.timeout <= 0 && ctx.Done() == nil {
+		return op()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+	var expired <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		err = fmt.Errorf("%w after %v", ErrTimeout, s.timeout)
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+	if late != nil {
+		go func() {
+			late(<-done)
+		}()
+	}
+	return err
+}
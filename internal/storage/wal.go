@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// WAL is an append only log of records, each one framed like an element
+// file: its length and CRC32 checksum, then the encoded record. It is
+// never read while open, Records reads it back after a crash
+type WAL struct {
+	f    *os.File
+	sync bool
+}
+
+// OpenWAL opens the log in fname, which belongs to s, for appending,
+// creating it. The records get the permissions and the durability of s
+func (s *Storage) OpenWAL(fname string) (*WAL, error) {
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, s.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL{f: f, sync: s.durability == DurabilityAlways}, nil
+}
+
+// AppendRecord writes t at the end of the log
+func AppendRecord[T any](w *WAL, t T) error {
+	e, buf, err := encode(t)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(buf)
+	if _, err := e.writeTo(w.f); err != nil {
+		return err
+	}
+	if w.sync {
+		return w.f.Sync()
+	}
+	return nil
+}
+
+// Reset empties the log, once its records are checkpointed
+func (w *WAL) Reset() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	if w.sync {
+		return w.f.Sync()
+	}
+	return nil
+}
+
+// Close closes the log, leaving its file
+func (w *WAL) Close() error {
+	return w.f.Close()
+}
+
+// Records returns the records of the log in fname in order, up to the
+// first torn or corrupted one, e.g. the last one of a crash. A missing
+// log has no records
+func Records[T any](fname string) ([]T, error) {
+	b, err := os.ReadFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []T
+	for len(b) >= headerSize {
+		n := binary.LittleEndian.Uint64(b[0:8]) &^ codecMask
+		if n > uint64(len(b)-headerSize) {
+			break
+		}
+		payload, c, err := verify(fname, b[:headerSize+n])
+		if err != nil {
+			break
+		}
+		t, err := decode[T](payload, c)
+		if err != nil {
+			return records, err
+		}
+		records = append(records, t)
+		b = b[headerSize+n:]
+	}
+	return records, nil
+}
@@ -0,0 +1,158 @@
+package slice_on_disk
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+func (c *config[T]) Backward() (iter.Seq2[int, T], func() error) {
+	var failed error
+	return func(yield func(int, T) bool) {
+		failed = nil
+		c.mu.Lock()
+		// at the last element yielded, so the deletions move it
+		k := &Cursor[T]{c: c, pos: c.len()}
+		c.follow(k)
+		c.mu.Unlock()
+		defer k.Close()
+
+		for {
+			c.mu.Lock()
+			err := c.expire()
+			i := min(k.pos, c.len()) - 1
+			var t T
+			if err == nil && i >= 0 {
+				t, err = c.getContext(context.Background(), i)
+				k.pos = i
+			}
+			c.mu.Unlock()
+			if err != nil {
+				failed = fmt.Errorf("element %d: %w", i, err)
+				return
+			}
+			if i < 0 || !yield(i, t) {
+				return
+			}
+		}
+	}, func() error { return failed }
+}
+
+func (c *config[T]) Chunks(n int) (iter.Seq[[]T], func() error) {
+	if n < 1 {
+		panic("Chunks: n must be at least 1")
+	}
+	var failed error
+	return func(yield func([]T) bool) {
+		failed = nil
+		c.mu.Lock()
+		k := &Cursor[T]{c: c}
+		c.follow(k)
+		c.mu.Unlock()
+		defer k.Close()
+
+		for {
+			c.mu.Lock()
+			err := c.expire()
+			start := min(k.pos, c.len())
+			var page []T
+			if err == nil && start < c.len() {
+				page = make([]T, min(n, c.len()-start))
+				err = c.copyOut(page, start)
+				k.pos = start + len(page)
+			}
+			c.mu.Unlock()
+			if err != nil {
+				failed = fmt.Errorf("elements %d to %d: %w", start, start+len(page), err)
+				return
+			}
+			if len(page) == 0 || !yield(page) {
+				return
+			}
+		}
+	}, func() error { return failed }
+}
+
+// Iterator iterates over the elements of a Slicer by index from a
+// position that can be saved and sought back, e.g. to resume after a
+// restart on the Slicer adopted with Open. Unlike a Cursor, it holds a
+// plain index: a deletion before it shifts the elements under it. It is
+// not safe for concurrent use by several goroutines
+type Iterator[T any] struct {
+	c     *config[T]
+	pos   int
+	index int
+	value T
+	err   error
+}
+
+func (c *config[T]) Iterator() *Iterator[T] {
+	return &Iterator[T]{c: c, index: -1}
+}
+
+// Next moves to the next element, returning false at the end or on an
+// error, see Err. Next may be called again after the end is reached, for
+// the elements appended since
+func (it *Iterator[T]) Next() bool {
+	c := it.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if it.err != nil {
+		return false
+	}
+	if it.err = c.expire(); it.err != nil {
+		return false
+	}
+	if it.pos >= c.len() {
+		return false
+	}
+	it.value, it.err = c.getContext(context.Background(), it.pos)
+	if it.err != nil {
+		return false
+	}
+	it.index = it.pos
+	it.pos++
+	return true
+}
+
+// Value returns the element of the last Next
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Index returns the index of the element of the last Next, -1 before the
+// first one or after Seek
+func (it *Iterator[T]) Index() int {
+	return it.index
+}
+
+// Position returns the index of the element the next Next reads, the one
+// to save to Seek back to
+func (it *Iterator[T]) Position() int {
+	return it.pos
+}
+
+// Seek makes the next Next read the element i, clearing the error of the
+// last one. Seeking to Len is allowed, Next returns false until an append
+func (it *Iterator[T]) Seek(i int) error {
+	c := it.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	if i < 0 || i > c.len() {
+		return IndexOutOfBounds
+	}
+	it.pos, it.index, it.err = i, -1, nil
+	var zero T
+	it.value = zero
+	return nil
+}
+
+// Err returns the error that stopped Next, nil at the end
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
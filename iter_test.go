@@ -0,0 +1,144 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestBackward(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	var got []int
+	all, failed := s.Backward()
+	for i, v := range all {
+		if i != v {
+			t.Fatalf("element %d = %d", i, v)
+		}
+		got = append(got, v)
+	}
+	want := seq(0, 100)
+	slices.Reverse(want)
+	if !slices.Equal(got, want) || failed() != nil {
+		t.Errorf("Backward() yielded %v, %v, want 99..0", got, failed())
+	}
+
+	// the front is drained meanwhile, every element is still visited once
+	got = got[:0]
+	all, _ = s.Backward()
+	for _, v := range all {
+		got = append(got, v)
+		if v%2 == 0 {
+			s.Delete(0, 1)
+		}
+		if v == 50 {
+			break
+		}
+	}
+	if !slices.Equal(got, want[:50]) {
+		t.Errorf("Backward() yielded %v, want 99..50", got)
+	}
+	if s.Len() != 75 {
+		t.Errorf("Len() = %d, want 75", s.Len())
+	}
+
+	// a lost file stops the loop with its error
+	c := s.(*config[int])
+	os.Remove(c.Path(c.diskSlice[50]))
+	got = got[:0]
+	all, failed = s.Backward()
+	for _, v := range all {
+		got = append(got, v)
+	}
+	if len(got) != 14 || failed() == nil {
+		t.Errorf("Backward() yielded %v, %v, want 14 elements and an error", got, failed())
+	}
+}
+
+func TestChunks(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithParallelReads(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 100)...)
+	var got []int
+	pages := 0
+	pagesOf, failed := s.Chunks(30)
+	for page := range pagesOf {
+		if len(page) != 30 && len(got) < 90 {
+			t.Fatalf("page %d of %d elements", pages, len(page))
+		}
+		got = append(got, page...)
+		pages++
+		// the processed pages are acked, the next one is still found
+		s.Delete(0, len(page))
+	}
+	if pages != 4 || !slices.Equal(got, seq(0, 100)) || s.Len() != 0 || failed() != nil {
+		t.Errorf("Chunks(30) yielded %d pages, %v, %d left, %v", pages, got, s.Len(), failed())
+	}
+
+	s.Append(seq(0, 100)...)
+	c := s.(*config[int])
+	os.Remove(c.Path(c.diskSlice[50]))
+	pages = 0
+	pagesOf, failed = s.Chunks(30)
+	for range pagesOf {
+		pages++
+	}
+	if pages != 2 || failed() == nil {
+		t.Errorf("Chunks(30) yielded %d pages, %v, want 2 and an error", pages, failed())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Chunks(0) didn't panic")
+		}
+	}()
+	s.Chunks(0)
+}
+
+func TestIterator(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(seq(0, 50)...)
+	it := s.Iterator()
+	for it.Next() && it.Value() < 29 {
+	}
+	if it.Index() != 29 || it.Position() != 30 {
+		t.Fatalf("Index() = %d, Position() = %d, want 29 and 30", it.Index(), it.Position())
+	}
+	// the position is saved, the process restarts
+	saved := it.Position()
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	it = o.Iterator()
+	if err := it.Seek(saved); err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, seq(30, 50)) {
+		t.Errorf("Next() after Seek(%d) yielded %v, want 30..49", saved, got)
+	}
+	if err := it.Seek(51); !errors.Is(err, IndexOutOfBounds) {
+		t.Errorf("Seek(51) = %v, want IndexOutOfBounds", err)
+	}
+	if err := it.Seek(10); err != nil || !it.Next() || it.Value() != 10 {
+		t.Errorf("Next() after Seek(10) = %d, %v, want 10", it.Value(), err)
+	}
+}
@@ -0,0 +1,240 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// the file in the Slicer directory that logs the changes to the manifest
+// since it was last written in full, see save
+const manifestLogName = "manifest.log"
+
+// manifestLogMin is the number of ids the log of the manifest may list
+// before the manifest is written in full again, whatever its size
+const manifestLogMin = 1024
+
+// manifestChange is a record of the log of the manifest: the disk tail
+// loses Drop ids at At for the Insert ones, the other fields replace the
+// ones of the manifest. The files of the elements of Insert, Head and
+// Written, the ones rewritten in place, come with their current state
+type manifestChange struct {
+	Seq       int64
+	At, Drop  int
+	Insert    []int
+	Written   []int
+	Len       int
+	DiskIndex int64
+	Head      []int
+	WALSeq    int64
+	Schema    int
+	Concrete  []string
+	Shards    int
+	Roots     []string
+	Spread    Spread
+	Legacy    map[int]int
+	Packed    map[int]storage.Entry
+	Chunked   map[int]int
+	Placed    map[int]int
+}
+
+// manifestLog is the log of the manifest of WithManifest, opened by
+// the first save, which writes the manifest in full
+type manifestLog struct {
+	f *storage.WAL
+	// the number of the last record and the ids the records list
+	seq  int64
+	size int
+	// the manifest with the records applied, without the files of the
+	// elements
+	last manifest
+	// the disk elements written since the last record
+	written []int
+	// the next save writes the manifest in full
+	full bool
+}
+
+// save writes the changes to the manifest since the previous save to its
+// log, or the manifest in full once the log lists as many ids. The
+// elements written in the background have their files described later,
+// so their manifest is always written in full
+func (c *config[T]) save() error {
+	l := c.mlog
+	if l == nil || l.f == nil || l.full || c.async != nil {
+		return c.saveAll(c.state())
+	}
+	ch, ok := c.change()
+	if !ok {
+		return nil
+	}
+	size := 1 + len(ch.Insert) + len(ch.Written)
+	if l.size+size > max(len(c.diskSlice), manifestLogMin) {
+		return c.saveAll(c.state())
+	}
+	ch.Seq = l.seq + 1
+	if err := storage.AppendRecord(l.f, ch); err != nil {
+		// a torn record hides the ones after it
+		l.full = true
+		return c.saveAll(c.state())
+	}
+	l.last.apply(ch)
+	l.seq, l.size = ch.Seq, l.size+size
+	l.written = nil
+	return nil
+}
+
+// saveAll writes the manifest m in full and empties its log
+func (c *config[T]) saveAll(m manifest) error {
+	l := c.mlog
+	if l == nil {
+		l = &manifestLog{}
+		c.mlog = l
+	}
+	if l.f == nil {
+		f, err := c.OpenWAL(filepath.Join(c.RootPath, manifestLogName))
+		if err != nil {
+			return fmt.Errorf("could not open the log of the manifest: %w", err)
+		}
+		l.f = f
+	}
+	// the records left by a crash before the log is emptied are older
+	m.LogSeq = l.seq
+	if err := storage.Save(c.Storage, filepath.Join(c.RootPath, manifestName), m); err != nil {
+		return err
+	}
+	l.last = manifest{
+		DiskSlice: slices.Clone(m.DiskSlice),
+		DiskIndex: m.DiskIndex,
+		Head:      slices.Clone(m.Head),
+		WALSeq:    m.WALSeq,
+		Schema:    m.Schema,
+		Concrete:  m.Concrete,
+		Shards:    m.Shards,
+		Roots:     slices.Clone(m.Roots),
+		Spread:    m.Spread,
+	}
+	l.size, l.written = 0, nil
+	// a change to the manifest of a Compact would keep its renumbering
+	l.full = m.Renumbered != nil
+	return l.f.Reset()
+}
+
+// change returns the change to the manifest since the previous save,
+// false if there is none
+func (c *config[T]) change() (manifestChange, bool) {
+	l := c.mlog
+	old, ids := l.last.DiskSlice, c.diskSlice
+	at := 0
+	for at < len(old) && at < len(ids) && old[at] == ids[at] {
+		at++
+	}
+	end := 0
+	for end < len(old)-at && end < len(ids)-at && old[len(old)-1-end] == ids[len(ids)-1-end] {
+		end++
+	}
+	ch := manifestChange{
+		At:        at,
+		Drop:      len(old) - at - end,
+		Insert:    ids[at : len(ids)-end],
+		Written:   l.written,
+		Len:       len(c.flushed) + len(ids),
+		DiskIndex: c.diskIndex,
+		Head:      c.flushed,
+		WALSeq:    c.walSeq,
+		Schema:    c.schema,
+		Concrete:  registered[T](),
+		Shards:    c.Shards(),
+		Roots:     c.RootDirs(),
+		Spread:    c.spread,
+	}
+	if ch.Drop == 0 && len(ch.Insert) == 0 && len(ch.Written) == 0 &&
+		ch.DiskIndex == l.last.DiskIndex && slices.Equal(ch.Head, l.last.Head) &&
+		ch.WALSeq == l.last.WALSeq && ch.Schema == l.last.Schema &&
+		slices.Equal(ch.Concrete, l.last.Concrete) && ch.Shards == l.last.Shards &&
+		slices.Equal(ch.Roots, l.last.Roots) && ch.Spread == l.last.Spread {
+		return ch, false
+	}
+	files := slices.Concat(ch.Insert, ch.Head, ch.Written)
+	ch.Packed, ch.Chunked, ch.Placed = c.Entries(files), c.ChunkCounts(files), c.Placed(files)
+	for _, id := range files {
+		if v, ok := c.legacy[id]; ok {
+			if ch.Legacy == nil {
+				ch.Legacy = make(map[int]int)
+			}
+			ch.Legacy[id] = v
+		}
+	}
+	return ch, true
+}
+
+// apply makes the change ch to m
+func (m *manifest) apply(ch manifestChange) {
+	m.DiskSlice = slices.Replace(m.DiskSlice, ch.At, ch.At+ch.Drop, ch.Insert...)
+	m.Len, m.DiskIndex, m.Head, m.WALSeq = ch.Len, ch.DiskIndex, slices.Clone(ch.Head), ch.WALSeq
+	m.Schema, m.Concrete, m.Shards, m.Roots, m.Spread = ch.Schema, ch.Concrete, ch.Shards, ch.Roots, ch.Spread
+	// the ids of removed files may be reused
+	for _, id := range slices.Concat(ch.Insert, ch.Head, ch.Written) {
+		delete(m.Legacy, id)
+		delete(m.Packed, id)
+		delete(m.Chunked, id)
+		delete(m.Placed, id)
+	}
+	m.Legacy = merged(m.Legacy, ch.Legacy)
+	m.Packed = merged(m.Packed, ch.Packed)
+	m.Chunked = merged(m.Chunked, ch.Chunked)
+	m.Placed = merged(m.Placed, ch.Placed)
+	m.LogSeq = ch.Seq
+}
+
+func merged[V any](m, add map[int]V) map[int]V {
+	if m == nil && len(add) > 0 {
+		m = make(map[int]V, len(add))
+	}
+	maps.Copy(m, add)
+	return m
+}
+
+// loadManifest reads the manifest of the directory path and applies the
+// changes its log records after it. The log ends at the first torn
+// record, e.g. the last one of a crash
+func loadManifest(path string) (manifest, error) {
+	m, err := storage.Load[manifest](filepath.Join(path, manifestName))
+	if err != nil {
+		return m, err
+	}
+	fname := filepath.Join(path, manifestLogName)
+	records, err := storage.Records[manifestChange](fname)
+	if err != nil {
+		log.Printf("error reading %s, applying %d changes: %s", fname, len(records), err.Error())
+	}
+	applied := false
+	for _, r := range records {
+		if r.Seq <= m.LogSeq {
+			continue
+		}
+		// the records that follow a gap are about another manifest
+		if r.Seq != m.LogSeq+1 || r.At+r.Drop > len(m.DiskSlice) {
+			break
+		}
+		m.apply(r)
+		applied = true
+	}
+	if !applied {
+		return m, nil
+	}
+	// the files of the elements removed since the manifest was written
+	listed := make(map[int]bool, len(m.Head)+len(m.DiskSlice))
+	for _, id := range append(slices.Clone(m.Head), m.DiskSlice...) {
+		listed[id] = true
+	}
+	unlisted := func(id int, _ int) bool { return !listed[id] }
+	maps.DeleteFunc(m.Legacy, unlisted)
+	maps.DeleteFunc(m.Chunked, unlisted)
+	maps.DeleteFunc(m.Placed, unlisted)
+	maps.DeleteFunc(m.Packed, func(id int, _ storage.Entry) bool { return !listed[id] })
+	return m, nil
+}
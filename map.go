@@ -0,0 +1,138 @@
+package slice_on_disk
+
+import "github.com/yurizf/slice-on-disk/internal/storage"
+
+// Mapper is an interface to work with an object similar to a map
+// whose hot keys are in memory and the rest is on the disk
+type Mapper[K comparable, V any] interface {
+	// Get: retrieves the value stored for the key. Similar to v, ok := m[key]
+	Get(key K) (V, bool, error)
+	// Put: stores the value for the key. Similar to m[key]=value
+	Put(key K, value V) error
+	// Delete: deletes the key. Similar to delete(m, key)
+	Delete(key K)
+	// Len: returns the number of keys
+	Len() int
+	// Range: calls fn for every key and value until fn returns false.
+	// Similar to for k, v := range m. In memory keys come first.
+	Range(fn func(key K, value V) bool) error
+	// Cleanup: removes the disk files and stops the go routine
+	// that is tasked with disk cleanup
+	Cleanup()
+}
+
+type mapConfig[K comparable, V any] struct {
+	*storage.Storage
+	memory    map[K]V
+	capacity  int
+	disk      map[K]int
+	diskIndex int
+}
+
+// NewMap creates a Mapper object. It accepts 2 parameters:
+// capacity: the maximum number of keys that will live in memory.
+// rootPath:  the path on the disk where the rest of the keys will live.
+// Like with New, a randomly named subdir will be created.
+func NewMap[K comparable, V any](capacity int, rootPath string) (Mapper[K, V], error) {
+	if capacity < 0 {
+		return nil, IndexOutOfBounds
+	}
+	s, err := storage.New(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mapConfig[K, V]{
+		Storage:  s,
+		memory:   make(map[K]V, capacity),
+		capacity: capacity,
+		disk:     make(map[K]int),
+	}, nil
+}
+
+func (m *mapConfig[K, V]) Get(key K) (V, bool, error) {
+	if v, ok := m.memory[key]; ok {
+		return v, true, nil
+	}
+
+	id, ok := m.disk[key]
+	if !ok {
+		var v V
+		return v, false, nil
+	}
+
+	v, err := storage.Read[V](m.Storage, id)
+	if err != nil {
+		return v, false, err
+	}
+
+	// the key is hot: move it to memory if there is room
+	if len(m.memory) < m.capacity {
+		m.memory[key] = v
+		delete(m.disk, key)
+		m.Remove(id)
+	}
+	return v, true, nil
+}
+
+func (m *mapConfig[K, V]) Put(key K, value V) error {
+	if _, ok := m.memory[key]; ok {
+		m.memory[key] = value
+		return nil
+	}
+
+	if id, ok := m.disk[key]; ok {
+		return storage.Write(m.Storage, id, value)
+	}
+
+	if len(m.memory) < m.capacity {
+		m.memory[key] = value
+		return nil
+	}
+
+	if err := storage.Write(m.Storage, m.diskIndex, value); err != nil {
+		return err
+	}
+	m.disk[key] = m.diskIndex
+	m.diskIndex++
+	return nil
+}
+
+func (m *mapConfig[K, V]) Delete(key K) {
+	if _, ok := m.memory[key]; ok {
+		delete(m.memory, key)
+		return
+	}
+
+	if id, ok := m.disk[key]; ok {
+		delete(m.disk, key)
+		m.Remove(id)
+	}
+}
+
+func (m *mapConfig[K, V]) Len() int {
+	return len(m.memory) + len(m.disk)
+}
+
+func (m *mapConfig[K, V]) Range(fn func(key K, value V) bool) error {
+	for k, v := range m.memory {
+		if !fn(k, v) {
+			return nil
+		}
+	}
+
+	for k, id := range m.disk {
+		v, err := storage.Read[V](m.Storage, id)
+		if err != nil {
+			return err
+		}
+		if !fn(k, v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *mapConfig[K, V]) Cleanup() {
+	m.Storage.Cleanup()
+}
@@ -0,0 +1,63 @@
+package slice_on_disk
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	m, err := NewMap[string, int](10, os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Cleanup()
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o"}
+	for i, k := range keys {
+		if err := m.Put(k, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mc, _ := m.(*mapConfig[string, int])
+	if m.Len() != 15 || len(mc.memory) != 10 || len(mc.disk) != 5 {
+		t.Errorf("unexpected len: len=%d, memory=%d, disk=%d", m.Len(), len(mc.memory), len(mc.disk))
+	}
+
+	if err := m.Put("o", 99); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := m.Get("o"); err != nil || !ok || v != 99 {
+		t.Errorf("Get(o) = %d, %v, %v, want 99, true, nil", v, ok, err)
+	}
+
+	m.Delete("a")
+	m.Delete("n")
+	if _, ok, _ := m.Get("a"); ok {
+		t.Errorf("deleted key a is still present")
+	}
+	if m.Len() != 13 {
+		t.Errorf("Len() = %d, want 13", m.Len())
+	}
+
+	// a disk key read while there is room in memory is moved to memory
+	if v, ok, err := m.Get("k"); err != nil || !ok || v != 10 {
+		t.Errorf("Get(k) = %d, %v, %v, want 10, true, nil", v, ok, err)
+	}
+	if _, ok := mc.memory["k"]; !ok {
+		t.Errorf("k was not moved to memory")
+	}
+
+	sum := 0
+	err = m.Range(func(k string, v int) bool {
+		sum += v
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0+...+14 - 0 (a) - 13 (n) - 14 (o) + 99 (o)
+	if sum != 105-13-14+99 {
+		t.Errorf("Range sum = %d, want %d", sum, 105-13-14+99)
+	}
+}
@@ -0,0 +1,128 @@
+package slice_on_disk
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+)
+
+// Merge merges the inputs, each sorted according to cmp, into a new
+// Slicer sorted the same way, e.g. the runs of an external sort.
+// cmp returns a negative number when a < b, 0 when a == b and a positive
+// number when a > b; equal elements keep the order of the inputs.
+// The inputs are read a window at a time and are not changed.
+// The result gets the settings of the first input and a sibling directory,
+// like with MapTo. Cleanup the result when done
+func Merge[T any](cmp func(a, b T) int, inputs ...Slicer[T]) (Slicer[T], error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("nothing to merge")
+	}
+	c, ok := inputs[0].(*config[T])
+	if !ok {
+		return nil, fmt.Errorf("can't merge into a %T", inputs[0])
+	}
+	c.mu.Lock()
+	dst, err := derive[T, T](c)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &mergeHeap[T]{cmp: cmp}
+	for k, s := range inputs {
+		r := &mergeRun[T]{s: s, k: k, end: s.Len()}
+		if err := r.fill(); err != nil {
+			dst.Cleanup()
+			return nil, err
+		}
+		if len(r.buf) > 0 {
+			h.runs = append(h.runs, r)
+		}
+	}
+	heap.Init(h)
+	// appended a window at a time: a call per element would save the
+	// manifest of WithManifest, listing all of them, or log a record of
+	// WithWAL every time
+	out := make([]T, 0, window)
+	for h.Len() > 0 {
+		r := h.runs[0]
+		out = append(out, r.buf[0])
+		if len(out) == cap(out) {
+			if err := dst.Append(out...); err != nil {
+				dst.Cleanup()
+				return nil, err
+			}
+			clear(out)
+			out = out[:0]
+		}
+		r.buf = r.buf[1:]
+		if err := r.fill(); err != nil {
+			dst.Cleanup()
+			return nil, err
+		}
+		if len(r.buf) == 0 {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	if err := dst.Append(out...); err != nil {
+		dst.Cleanup()
+		return nil, err
+	}
+	return dst, nil
+}
+
+// mergeRun is an input of Merge, buf holds the window being merged
+type mergeRun[T any] struct {
+	s Slicer[T]
+	// the number of the input, for the ties
+	k         int
+	buf       []T
+	next, end int
+}
+
+// fill reads the next window once buf is consumed
+func (r *mergeRun[T]) fill() error {
+	if len(r.buf) > 0 || r.next == r.end {
+		return nil
+	}
+	end := min(r.next+window, r.end)
+	buf, err := r.s.Slice(r.next, end)
+	if err != nil {
+		return err
+	}
+	r.buf, r.next = buf, end
+	return nil
+}
+
+// mergeHeap orders the runs by their first element, see container/heap
+type mergeHeap[T any] struct {
+	runs []*mergeRun[T]
+	cmp  func(a, b T) int
+}
+
+func (h *mergeHeap[T]) Len() int {
+	return len(h.runs)
+}
+
+func (h *mergeHeap[T]) Less(i, j int) bool {
+	if c := h.cmp(h.runs[i].buf[0], h.runs[j].buf[0]); c != 0 {
+		return c < 0
+	}
+	return h.runs[i].k < h.runs[j].k
+}
+
+func (h *mergeHeap[T]) Swap(i, j int) {
+	h.runs[i], h.runs[j] = h.runs[j], h.runs[i]
+}
+
+func (h *mergeHeap[T]) Push(x any) {
+	h.runs = append(h.runs, x.(*mergeRun[T]))
+}
+
+func (h *mergeHeap[T]) Pop() any {
+	r := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return r
+}
@@ -0,0 +1,56 @@
+package slice_on_disk
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	type run struct{ Key, Input int }
+	// the result gets the layout of the first input
+	for name, opts := range map[string][]Option{
+		"files":    nil,
+		"manifest": {WithManifest()},
+	} {
+		inputs := make([]Slicer[run], 4)
+		var want []run
+		for k := range inputs {
+			s, err := New(make([]run, 0, 10), os.TempDir(), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Cleanup()
+			// the first input takes two windows, the last one is empty
+			var in []run
+			for i := 0; k < 3 && i < 1200; i += k + 1 {
+				in = append(in, run{i, k})
+			}
+			s.Append(in...)
+			want = append(want, in...)
+			inputs[k] = s
+		}
+		// the ties in the order of the inputs
+		slices.SortStableFunc(want, func(a, b run) int { return cmp.Compare(a.Key, b.Key) })
+
+		m, err := Merge(func(a, b run) int { return cmp.Compare(a.Key, b.Key) }, inputs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Cleanup()
+		x, err := m.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(x, want) {
+			t.Errorf("%s: Merge() = %d elements, want %d", name, len(x), len(want))
+		}
+		if m.MemLen() != 10 {
+			t.Errorf("%s: MemLen() = %d, want the head of the first input", name, m.MemLen())
+		}
+	}
+	if _, err := Merge[int](cmp.Compare[int]); err == nil {
+		t.Errorf("Merge() of nothing succeeded")
+	}
+}
@@ -0,0 +1,499 @@
+package slice_on_disk
+
+import (
+	"os"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// Option configures the Slicer created by New
+type Option func(*options)
+
+type options struct {
+	maxLen int
+	ttl    time.Duration
+	// number of background writers, 0 means synchronous writes
+	asyncWorkers int
+	// number of disk elements read ahead by sequential reads
+	prefetch int
+	// number of workers decoding the disk part of Slice
+	readWorkers int
+	// keep the manifest of the disk tail up to date,
+	// the directory is locked
+	manifest bool
+	// the schema version of the elements written and the
+	// func(old []byte, version int) (T, error) reading the older ones
+	schema  int
+	migrate any
+	// the value the encoding of T is probed with, see WithSample
+	sample any
+	// log the Append, Put and Delete calls, with manifest
+	wal bool
+	// Flush leaves the Slicer serving reads instead of cleaning it up
+	serveAfterFlush bool
+	// Append, Put and Delete fail with ErrReadOnly
+	readOnly       bool
+	autoCompaction AutoCompaction
+	// number of subdirs the disk files are spread over
+	shards int
+	// permissions of the disk files and directories, 0 is the default
+	fileMode   os.FileMode
+	dirMode    os.FileMode
+	durability Durability
+	// the tries of the transient disk failures and the first wait
+	retries int
+	backoff time.Duration
+	// bound of the size of the disk files, 0 means no bound
+	maxDiskBytes int64
+	quotaPolicy  QuotaPolicy
+	// elements encoded to less bytes share block files
+	packing int
+	// elements encoded to more bytes are split into chunk files
+	chunking int
+	// the elements are only appended and removed from the front
+	appendOnly bool
+	hooks      Hooks
+	// Slice counts the negative indices from the end
+	negativeIndices bool
+	// number of calls Undo can revert, 0 means no journal
+	undo int
+	// number of previous versions kept by Put
+	versions int
+	// Open takes the lock of the directory over
+	stealLock bool
+	// the directory mirroring the disk tail
+	replica string
+	// the other root paths of the disk files and how they are picked
+	roots  []string
+	spread Spread
+	// the budget shared with other Slicers, see WithQuotaManager
+	quota        *QuotaManager
+	quotaWeight  int
+	sharedPolicy QuotaPolicy
+	// what Append does when the volume is full
+	diskFullPolicy QuotaPolicy
+	// the number of go routines removing the disk files
+	cleaners int
+	// the bounds of the disk I/O per second, 0 means no bound
+	writeRate int64
+	iops      int
+	// the longest a disk operation may take, 0 means no bound
+	opTimeout time.Duration
+	// the name in the registry, see ListSlicers
+	name string
+	// the name of the expvar variable
+	expvar string
+	// the spans around the disk operations
+	tracer Tracer
+}
+
+func apply(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMaxLen bounds the Slicer to n elements: Append beyond n evicts
+// the oldest elements (deleting their disk files), giving a fixed size
+// rolling window. n <= 0 means no bound, which is the default.
+func WithMaxLen(n int) Option {
+	return func(o *options) {
+		o.maxLen = n
+	}
+}
+
+// WithTTL makes the elements expire d after they were appended.
+// Expiration is lazy: every call of the Slicer first drops the elements
+// older than d, removing their disk files. Put doesn't reset the age, and
+// the calls reordering the elements carry it along with them.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithAsyncWrites makes Append and Put return without waiting for
+// the spilled elements to be encoded and written: they are queued to
+// n background writers instead. Until written, the elements are served
+// from memory, so reads always see the latest Append/Put.
+// Write errors are reported by Sync. When the writers fall behind,
+// Append blocks until they catch up.
+func WithAsyncWrites(n int) Option {
+	return func(o *options) {
+		o.asyncWorkers = n
+	}
+}
+
+// WithPrefetch makes the sequential reads of the disk tail (Slice and
+// the iterators) decode up to n of the following elements in the background
+// while the current one is consumed.
+func WithPrefetch(n int) Option {
+	return func(o *options) {
+		o.prefetch = n
+	}
+}
+
+// WithParallelReads makes Slice, SliceInto and Chunks decode the disk
+// elements with a pool of n workers. The order of the result is preserved.
+func WithParallelReads(n int) Option {
+	return func(o *options) {
+		o.readWorkers = n
+	}
+}
+
+// WithManifest keeps a manifest of the disk tail in the Slicer directory.
+// The calls that spill or delete append their change to its log, and the
+// manifest is rewritten once the log lists as many elements. It lets Open
+// adopt the directory after a crash. Like every Slicer directory, it
+// is locked, so that another process can't Open it meanwhile.
+func WithManifest() Option {
+	return func(o *options) {
+		o.manifest = true
+	}
+}
+
+// WithWAL logs the Append, Put, Delete and Truncate calls and the Batch
+// commits to a write-ahead log in the Slicer directory before applying
+// them. The manifest of WithManifest, implied, becomes a checkpoint of
+// all the elements, the head included, written by the other calls and
+// every 1024 logged ones rather than by every call. After a crash, Open
+// replays the log on the checkpoint: the logged calls survive, the other
+// ones since the checkpoint are lost, except for the disk elements they
+// overwrote in place. The files of the checkpoint are kept until the next one
+func WithWAL() Option {
+	return func(o *options) {
+		o.manifest = true
+		o.wal = true
+	}
+}
+
+// WithServeAfterFlush keeps the Slicer serving reads after Flush,
+// read only, until Cleanup, which then leaves the directory for Open
+func WithServeAfterFlush() Option {
+	return func(o *options) {
+		o.serveAfterFlush = true
+	}
+}
+
+// AutoCompaction sets when the background compaction runs Compact
+type AutoCompaction struct {
+	// how often the thresholds are checked
+	Interval time.Duration
+	// compact when this fraction of the disk files was deleted
+	// since the last compaction
+	GarbageRatio float64
+	// compact when the file numbering went past MaxFiles
+	// and some files were deleted
+	MaxFiles int
+}
+
+// WithAutoCompaction starts a go routine that runs Compact
+// when one of the thresholds is crossed. See PauseCompaction.
+func WithAutoCompaction(a AutoCompaction) Option {
+	return func(o *options) {
+		o.autoCompaction = a
+	}
+}
+
+// WithSharding spreads the disk files over n subdirs of the Slicer
+// directory by the element number, e.g. 05/12345 for n=10, so no single
+// directory gets millions of entries: that slows down every file
+// creation and removal on ext4 and NFS. n <= 0 keeps the files flat,
+// which is the default. Open keeps the layout the directory was created with.
+func WithSharding(n int) Option {
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// Spread tells which root path gets a new disk file, see WithRootPaths
+type Spread = storage.Spread
+
+const (
+	SpreadRoundRobin = storage.SpreadRoundRobin
+	SpreadFreeSpace  = storage.SpreadFreeSpace
+)
+
+// WithRootPaths spreads the disk files over the paths besides the root
+// path of New, e.g. one per physical disk, so the spills are not bound by
+// the bandwidth of one device. Each path gets a subdir of its own. With
+// SpreadRoundRobin the paths take turns, with SpreadFreeSpace a new file
+// goes to the one with the most free space. The block files of WithPacking
+// and the manifest stay in the Slicer directory. Stats reports the usage
+// of every path, Open keeps the files where they are.
+func WithRootPaths(spread Spread, paths ...string) Option {
+	return func(o *options) {
+		o.spread = spread
+		o.roots = paths
+	}
+}
+
+// WithFileMode sets the permissions of the disk files, 0600 by default.
+// The elements may hold sensitive data: keep them private on shared systems.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.fileMode = mode
+	}
+}
+
+// WithDirMode sets the permissions of the Slicer directory
+// and its subdirs, 0700 by default.
+func WithDirMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.dirMode = mode
+	}
+}
+
+// Durability tells when the disk files are synced to the stable storage,
+// see WithDurability
+type Durability = storage.Durability
+
+const (
+	DurabilityNone   = storage.DurabilityNone
+	DurabilityBatch  = storage.DurabilityBatch
+	DurabilityAlways = storage.DurabilityAlways
+)
+
+// WithDurability sets when the disk files are synced to the stable storage:
+// DurabilityNone (the default) leaves it to the OS, DurabilityBatch syncs
+// the files written since the previous Sync on Sync, DurabilityAlways
+// syncs every file and its directory before the write returns.
+// The safer the slower.
+func WithDurability(d Durability) Option {
+	return func(o *options) {
+		o.durability = d
+	}
+}
+
+// WithRetry retries the writes, the reads and the removals of the disk
+// files that fail with a transient error, see IsTransient, e.g. on NFS:
+// up to attempts tries in all, waiting backoff before the second one and
+// twice as long before each of the next ones. The other errors, and
+// the transient ones once the attempts are used up, are returned as usual
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries, o.backoff = attempts, backoff
+	}
+}
+
+// IsTransient tells whether err, returned by a disk operation, may go
+// away on its own like an I/O error or a timeout of a network volume,
+// rather than a missing or corrupted file or a full disk
+func IsTransient(err error) bool {
+	return storage.Transient(err)
+}
+
+// WithMaxDiskBytes bounds the size of the disk files to about n bytes,
+// so a runaway producer can't fill the volume: once it is reached,
+// Append acts according to policy. The sizes are the encoded ones.
+// The check is done before an element is spilled, so the last element
+// may cross the bound. With WithAsyncWrites, the queued writes
+// are not accounted yet.
+func WithMaxDiskBytes(n int64, policy QuotaPolicy) Option {
+	return func(o *options) {
+		o.maxDiskBytes = n
+		o.quotaPolicy = policy
+	}
+}
+
+// WithQuotaManager registers the Slicer with q, which bounds the size of
+// the disk files of all its Slicers: once the Slicer has its share of the
+// budget of q, Append acts according to policy, like with WithMaxDiskBytes,
+// failing with ErrQuotaExceeded. QuotaEvictOldest evicts the oldest elements
+// of this Slicer, QuotaBlock waits until any Slicer of q deletes elements.
+// The share is proportional to weight, 1 at least. Cleanup unregisters the
+// Slicer. The Slicers derived from it, like its snapshots, are registered too.
+func WithQuotaManager(q *QuotaManager, weight int, policy QuotaPolicy) Option {
+	return func(o *options) {
+		o.quota = q
+		o.quotaWeight = weight
+		o.sharedPolicy = policy
+	}
+}
+
+// WithCleanerWorkers removes the files of the deleted elements with n go
+// routines instead of one: on some file systems removing the files of a
+// large Delete one at a time takes minutes. Each takes a batch of the
+// removals at a time. Close waits until they are done
+func WithCleanerWorkers(n int) Option {
+	return func(o *options) {
+		o.cleaners = n
+	}
+}
+
+// WithWriteRateLimit bounds the bytes written to the disk files to about
+// bytesPerSec per second, with bursts of up to one second: spilling
+// a burst of appends doesn't starve the other users of the disk.
+// The writes that would exceed it wait, Stats reports how long
+func WithWriteRateLimit(bytesPerSec int64) Option {
+	return func(o *options) {
+		o.writeRate = bytesPerSec
+	}
+}
+
+// WithIOPSLimit bounds the writes, reads and removals of disk files to
+// about n per second, like WithWriteRateLimit bounds the bytes written
+func WithIOPSLimit(n int) Option {
+	return func(o *options) {
+		o.iops = n
+	}
+}
+
+// WithOpTimeout bounds every write, read and removal of a disk file to d:
+// a hung network volume turns into ErrTimeout from Get and Append rather
+// than hanging the caller. A syscall can't be interrupted, so the late
+// operation goes on in the background: a late Put may still land, while
+// the file of a late Append is removed. See GetContext and AppendContext
+// for the deadlines of one call
+func WithOpTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.opTimeout = d
+	}
+}
+
+// WithDiskFullPolicy sets what Append does when a write fails because
+// the volume of the disk files is full: QuotaFail (the default) fails
+// with an error wrapping ErrDiskFull, QuotaEvictOldest deletes the oldest
+// elements until the write succeeds and QuotaBlock retries the write until
+// there is space again or Cleanup. Append fails atomically: none of its
+// elements is appended. With WithAsyncWrites, the failures are returned
+// by Sync instead
+func WithDiskFullPolicy(policy QuotaPolicy) Option {
+	return func(o *options) {
+		o.diskFullPolicy = policy
+	}
+}
+
+// WithPacking stores the elements encoded to less than threshold bytes
+// in shared block files instead of a file each: a tiny element
+// doesn't waste a whole file system block. The larger elements
+// keep their own file. A block file is removed once all its elements
+// are deleted or replaced.
+func WithPacking(threshold int) Option {
+	return func(o *options) {
+		o.packing = threshold
+	}
+}
+
+// WithChunking splits the elements encoded to more than size bytes,
+// e.g. large blobs, into chunk files of size bytes. They are encoded
+// and decoded as a stream, one chunk in memory at a time, instead of
+// going through a buffer holding the whole encoding.
+func WithChunking(size int) Option {
+	return func(o *options) {
+		o.chunking = size
+	}
+}
+
+// WithAppendOnly is meant for the buffers that are only appended to and
+// drained from the front, e.g. captured logs: the spilled elements are
+// appended to a log of block files written in turn, each kept open while
+// it is written, instead of a file per element. In exchange, the calls
+// rewriting or removing elements elsewhere than at the front fail with
+// ErrAppendOnly: Put, PutBatch, Swap, Reverse, Delete and DeleteRanges
+// unless they start at 0, and Truncate unless it drops everything.
+// A block file is removed once all its elements are drained.
+func WithAppendOnly() Option {
+	return func(o *options) {
+		o.appendOnly = true
+	}
+}
+
+// WithNegativeIndices makes Slice count a negative index from the end,
+// like Python does: Slice(-10) returns the last 10 elements and
+// Slice(0, -1) all but the last one. An index still negative then is
+// out of bounds
+func WithNegativeIndices() Option {
+	return func(o *options) {
+		o.negativeIndices = true
+	}
+}
+
+// WithHooks sets the functions called when elements are appended,
+// removed or spilled to the disk tail, see Hooks.
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = h
+	}
+}
+
+// WithUndo journals the last n Append, Put, Delete, Truncate and
+// BlockingPopFront calls, so that Undo can revert them. The disk files
+// of the deleted or overwritten elements are kept until their call
+// leaves the journal, the head ones in memory. The other calls changing
+// the elements empty the journal.
+func WithUndo(n int) Option {
+	return func(o *options) {
+		o.undo = n
+	}
+}
+
+// WithVersions keeps the n previous versions of the disk elements
+// overwritten by Put, see GetVersion. Every Put of a disk element writes
+// a new file, the oldest version beyond n is deleted. The versions go
+// with the element: when it is deleted or moved to the head. They are
+// not in the manifest.
+func WithVersions(n int) Option {
+	return func(o *options) {
+		o.versions = n
+	}
+}
+
+// WithStealLock makes Open take the directory over even if another
+// process holds its lock, e.g. a hung one, instead of failing with
+// ErrLocked. The lock of a process that died is released anyway.
+// The Slicer of the other process must not be used afterwards: Open
+// removes the files it doesn't list and the ones of the elements it
+// moves to the head, which that Slicer may still read.
+func WithStealLock() Option {
+	return func(o *options) {
+		o.stealLock = true
+	}
+}
+
+// WithReplica mirrors the disk tail to a directory in path, e.g. on
+// another volume: every element spilled to the disk is written there too
+// and every deleted one is removed from there. When the file of an element
+// is missing or corrupted, Get reads the replica and rewrites the file.
+// Open copies the adopted tail to the replica, which doesn't survive
+// the process
+func WithReplica(path string) Option {
+	return func(o *options) {
+		o.replica = path
+	}
+}
+
+// WithName registers the Slicer created by New, Open or OpenReadOnly
+// under name in the package registry until Cleanup, see ListSlicers.
+// The names don't have to be unique. The copies and the Slicers derived
+// from it are not registered
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithExpvar publishes the counters of the Slicer created by New, Open or
+// OpenReadOnly as the expvar variable name, so /debug/vars shows them:
+// its lengths, the totals of appended, deleted and spilled elements, the
+// size of the disk files, the removals the cleaner has yet to do and the
+// failed disk operations. A variable can't be removed: after Cleanup it
+// holds the last values, until another Slicer is created with that name
+func WithExpvar(name string) Option {
+	return func(o *options) {
+		o.expvar = name
+	}
+}
+
+// WithTracer wraps the disk reads, writes and deletes in spans of t, e.g.
+// an adapter of an OpenTelemetry tracer, so the slow Get calls show up in
+// the traces of the service, see Span. The Slicer API takes no context:
+// the adapter picks the parent of the spans
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
@@ -0,0 +1,106 @@
+package slice_on_disk
+
+import (
+	"cmp"
+	"errors"
+)
+
+// ErrEmpty is returned by the aggregates that are undefined
+// for an empty Slicer, like Min and Max
+var ErrEmpty = errors.New("slicer is empty")
+
+// Min returns the minimal element of s in one pass over the elements.
+// Like with slices.Min, a NaN is the minimum of floats
+func Min[T cmp.Ordered](s Slicer[T]) (T, error) {
+	return fold(s, func(acc, v T) T {
+		return min(acc, v)
+	})
+}
+
+// Max returns the maximal element of s in one pass over the elements.
+// Like with slices.Max, a NaN is the maximum of floats
+func Max[T cmp.Ordered](s Slicer[T]) (T, error) {
+	return fold(s, func(acc, v T) T {
+		return max(acc, v)
+	})
+}
+
+// MinFunc returns the first minimal element of s according to cmp,
+// which returns a negative number when a < b, 0 when a == b and
+// a positive number when a > b
+func MinFunc[T any](s Slicer[T], cmp func(a, b T) int) (T, error) {
+	return fold(s, func(acc, v T) T {
+		if cmp(v, acc) < 0 {
+			return v
+		}
+		return acc
+	})
+}
+
+// MaxFunc returns the first maximal element of s according to cmp, see MinFunc
+func MaxFunc[T any](s Slicer[T], cmp func(a, b T) int) (T, error) {
+	return fold(s, func(acc, v T) T {
+		if cmp(v, acc) > 0 {
+			return v
+		}
+		return acc
+	})
+}
+
+// fold is Reduce starting with the first element, ErrEmpty if there is none
+func fold[T any](s Slicer[T], fn func(acc, v T) T) (T, error) {
+	var acc T
+	empty := true
+	err := s.ForEach(func(i int, v T) error {
+		if i == 0 {
+			acc, empty = v, false
+		} else {
+			acc = fn(acc, v)
+		}
+		return nil
+	})
+	if err == nil && empty {
+		err = ErrEmpty
+	}
+	return acc, err
+}
+
+// window is the number of elements of each Slicer read at a time
+// by the functions streaming several Slicers, like Equal
+const window = 1024
+
+// Equal tells if a and b have the same length and equal elements,
+// similar to slices.Equal. Both are read a window at a time
+func Equal[T comparable](a, b Slicer[T]) (bool, error) {
+	return EqualFunc(a, b, func(x, y T) bool {
+		return x == y
+	})
+}
+
+// EqualFunc tells if a and b have the same length and eq is true
+// for the elements at every index, similar to slices.EqualFunc
+func EqualFunc[T, U any](a Slicer[T], b Slicer[U], eq func(x T, y U) bool) (bool, error) {
+	n := a.Len()
+	if b.Len() != n {
+		return false, nil
+	}
+	// a window of a is never read with b locked, or the other way round,
+	// so comparing to each other concurrently can't deadlock
+	for start := 0; start < n; start += window {
+		end := min(start+window, n)
+		x, err := a.Slice(start, end)
+		if err != nil {
+			return false, err
+		}
+		y, err := b.Slice(start, end)
+		if err != nil {
+			return false, err
+		}
+		for i := range x {
+			if !eq(x[i], y[i]) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package slice_on_disk
+
+import (
+	"cmp"
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestMinMax(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	rand.Shuffle(s.Len(), func(i, j int) { s.Swap(i, j) })
+
+	if x, err := Min(s); err != nil || x != 0 {
+		t.Errorf("Min() = %d, %v, want 0", x, err)
+	}
+	if x, err := Max(s); err != nil || x != 99 {
+		t.Errorf("Max() = %d, %v, want 99", x, err)
+	}
+	// by the last digit, the first one wins
+	byDigit := func(a, b int) int { return cmp.Compare(a%10, b%10) }
+	first := func(digit int) int {
+		i, _ := s.IndexFunc(func(v int) bool { return v%10 == digit })
+		x, _ := s.Get(i)
+		return x
+	}
+	if x, err := MinFunc(s, byDigit); err != nil || x != first(0) {
+		t.Errorf("MinFunc() = %d, %v, want %d", x, err, first(0))
+	}
+	if x, err := MaxFunc(s, byDigit); err != nil || x != first(9) {
+		t.Errorf("MaxFunc() = %d, %v, want %d", x, err, first(9))
+	}
+
+	empty, err := New(make([]int, 0, 1), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Cleanup()
+	if _, err := Max(empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Max() = %v, want ErrEmpty", err)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+
+	if ok, err := Equal(s, cl); err != nil || !ok {
+		t.Errorf("Equal() = %v, %v for a clone", ok, err)
+	}
+	cl.Put(95, -1)
+	if ok, err := Equal(s, cl); err != nil || ok {
+		t.Errorf("Equal() = %v, %v after Put", ok, err)
+	}
+	cl.Truncate(50)
+	if ok, err := Equal(s, cl); err != nil || ok {
+		t.Errorf("Equal() = %v, %v after Truncate", ok, err)
+	}
+
+	strs, err := MapTo(s, strconv.Itoa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strs.Cleanup()
+	eq := func(x int, y string) bool { return strconv.Itoa(x) == y }
+	if ok, err := EqualFunc(s, strs, eq); err != nil || !ok {
+		t.Errorf("EqualFunc() = %v, %v", ok, err)
+	}
+}
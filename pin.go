@@ -0,0 +1,54 @@
+package slice_on_disk
+
+func (c *config[T]) Pin(indices ...int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	for _, i := range indices {
+		if i < 0 || i >= c.len() {
+			return IndexOutOfBounds
+		}
+	}
+	for _, i := range indices {
+		// the head is in memory anyway
+		if i < len(c.slice) {
+			continue
+		}
+		id := c.diskSlice[i-len(c.slice)]
+		if _, ok := c.pinned[id]; ok {
+			continue
+		}
+		t, err := c.read(id)
+		if err != nil {
+			return err
+		}
+		if c.pinned == nil {
+			c.pinned = make(map[int]T)
+		}
+		c.pinned[id] = t
+	}
+	return nil
+}
+
+func (c *config[T]) Unpin(indices ...int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	for _, i := range indices {
+		if i < 0 || i >= c.len() {
+			return IndexOutOfBounds
+		}
+	}
+	for _, i := range indices {
+		if i >= len(c.slice) {
+			delete(c.pinned, c.diskSlice[i-len(c.slice)])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package slice_on_disk
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	c := s.(*config[int])
+
+	if err := s.Pin(5, 50); err != nil {
+		t.Fatal(err)
+	}
+	// the pinned element is no longer read from the disk
+	if err := os.Remove(c.Path(c.diskSlice[40])); err != nil {
+		t.Fatal(err)
+	}
+	if x, err := s.Get(50); err != nil || x != 50 {
+		t.Errorf("Get(50) = %d, %v, want 50", x, err)
+	}
+	s.Swap(50, 60)
+	if x, err := s.Get(60); err != nil || x != 50 {
+		t.Errorf("Get(60) = %d, %v, want 50", x, err)
+	}
+	if err := s.Put(60, -50); err != nil {
+		t.Fatal(err)
+	}
+	if x, err := s.Get(60); err != nil || x != -50 {
+		t.Errorf("Get(60) = %d, %v, want -50", x, err)
+	}
+
+	if err := s.Unpin(60); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(100); err != IndexOutOfBounds {
+		t.Errorf("Pin(100) = %v, want IndexOutOfBounds", err)
+	}
+}
@@ -0,0 +1,75 @@
+package slice_on_disk
+
+// Placement tells where an element of a Slicer lives
+type Placement int
+
+const (
+	// in the memory head
+	InMemory Placement = iota
+	// in the disk tail, with a decoded copy in memory, see Pin and Warmup
+	Cached
+	// in the disk tail
+	OnDisk
+)
+
+func (p Placement) String() string {
+	switch p {
+	case InMemory:
+		return "memory"
+	case Cached:
+		return "cached"
+	case OnDisk:
+		return "disk"
+	}
+	return "unknown"
+}
+
+func (c *config[T]) Placement(index int) (Placement, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= c.len() {
+		return 0, IndexOutOfBounds
+	}
+	if index < len(c.slice) {
+		return InMemory, nil
+	}
+	id := c.diskSlice[index-len(c.slice)]
+	if _, ok := c.pinned[id]; ok {
+		return Cached, nil
+	}
+	if _, ok := c.warm[id]; ok {
+		return Cached, nil
+	}
+	return OnDisk, nil
+}
+
+func (c *config[T]) IsOnDisk(index int) (bool, error) {
+	p, err := c.Placement(index)
+	return p != InMemory, err
+}
+
+func (c *config[T]) MemLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	return len(c.slice)
+}
+
+func (c *config[T]) Cap() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cap(c.slice)
+}
+
+func (c *config[T]) DiskLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	return len(c.diskSlice)
+}
@@ -0,0 +1,24 @@
+package slice_on_disk
+
+import "testing"
+
+func TestPlacement(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if s.MemLen() != 10 || s.DiskLen() != 90 {
+		t.Errorf("MemLen %d, DiskLen %d, want 10 and 90", s.MemLen(), s.DiskLen())
+	}
+	s.Pin(20)
+	for i, want := range map[int]Placement{0: InMemory, 9: InMemory, 10: OnDisk, 20: Cached, 99: OnDisk} {
+		if p, err := s.Placement(i); err != nil || p != want {
+			t.Errorf("Placement(%d) = %s, %v, want %s", i, p, err, want)
+		}
+	}
+	if ok, _ := s.IsOnDisk(20); !ok {
+		t.Error("IsOnDisk(20) = false, want true")
+	}
+	if _, err := s.IsOnDisk(100); err != IndexOutOfBounds {
+		t.Errorf("IsOnDisk(100) = %v, want IndexOutOfBounds", err)
+	}
+}
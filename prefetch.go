@@ -0,0 +1,113 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"sync"
+)
+
+// readSeq reads the disk elements ids in order and calls fn for each of them.
+// With WithPrefetch(n), up to n of the following elements are read
+// in the background while fn runs. It stops at the first error.
+func (c *config[T]) readSeq(ids []int, fn func(t T) error) error {
+	if c.prefetch <= 0 {
+		for _, id := range ids {
+			t, err := c.read(id)
+			if err != nil {
+				return fmt.Errorf(GetError, err)
+			}
+			if err = fn(t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	type result struct {
+		t   T
+		err error
+	}
+	// window[i%n] holds the result of ids[i]. The channels are buffered,
+	// so the readers never block even if we stop early, and we wait for
+	// them: a read may fill the cache of Pin, which the caller guards
+	window := make([]chan result, min(c.prefetch, len(ids)))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	fetch := func(i int) {
+		ch := make(chan result, 1)
+		window[i%len(window)] = ch
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			t, err := c.read(id)
+			ch <- result{t, err}
+		}(ids[i])
+	}
+
+	for i := range window {
+		fetch(i)
+	}
+	for i := range ids {
+		r := <-window[i%len(window)]
+		if r.err != nil {
+			return fmt.Errorf(GetError, r.err)
+		}
+		if i+len(window) < len(ids) {
+			fetch(i + len(window))
+		}
+		if err := fn(r.t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readInto reads the disk elements ids into dst, which must be as long.
+// With WithParallelReads(n), n workers decode the elements concurrently,
+// each storing directly to its position, so the order is preserved.
+func (c *config[T]) readInto(dst []T, ids []int) error {
+	if c.readWorkers <= 1 {
+		n := 0
+		return c.readSeq(ids, func(t T) error {
+			dst[n] = t
+			n++
+			return nil
+		})
+	}
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		err  error
+		next = make(chan int)
+		done = make(chan struct{})
+	)
+	for w := 0; w < min(c.readWorkers, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				t, e := c.read(ids[i])
+				if e != nil {
+					once.Do(func() {
+						err = fmt.Errorf(GetError, e)
+						close(done)
+					})
+					return
+				}
+				dst[i] = t
+			}
+		}()
+	}
+
+feed:
+	for i := range ids {
+		select {
+		case next <- i:
+		case <-done:
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return err
+}
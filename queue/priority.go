@@ -0,0 +1,270 @@
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// number of same level runs merged into one run of the next level
+const fanIn = 8
+
+// PriorityQueue is an interface to work with a priority queue
+// that keeps the best elements in memory and spills the rest to the disk.
+// It is safe for concurrent use.
+type PriorityQueue[T any] interface {
+	// Push: adds the elements to the queue
+	Push(elements ...T) error
+	// Pop: removes and returns the smallest element according to less.
+	// Returns Empty if there is none
+	Pop() (T, error)
+	// Peek: returns the smallest element without removing it
+	Peek() (T, error)
+	// Len: returns the number of elements
+	Len() int
+	// Cleanup: removes the disk files and stops the go routine
+	// that is tasked with disk cleanup
+	Cleanup()
+}
+
+// run is a sorted sequence of elements on the disk.
+// Only its current head is kept in memory
+type run[T any] struct {
+	ids   []int
+	pos   int
+	head  T
+	level int
+}
+
+type priorityQueue[T any] struct {
+	*storage.Storage
+	mu        sync.Mutex
+	capacity  int
+	memory    *items[T]
+	runs      []*run[T]
+	diskIndex int
+}
+
+// NewPriorityQueue creates a PriorityQueue object. It accepts 3 parameters:
+// capacity: the maximum number of elements that will live in memory.
+// When it is exceeded, the worse half of them is spilled to the disk
+// as a sorted run.
+// less:  the ordering of the elements. Pop returns the smallest one.
+// rootPath:  the path on the disk where the spilled elements will live.
+func NewPriorityQueue[T any](capacity int, less func(a, b T) bool, rootPath string) (PriorityQueue[T], error) {
+	if capacity < 0 {
+		return nil, errors.New("negative capacity")
+	}
+	s, err := storage.New(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &priorityQueue[T]{
+		Storage:  s,
+		capacity: capacity,
+		memory:   &items[T]{s: make([]T, 0, capacity+1), less: less},
+	}, nil
+}
+
+func (pq *priorityQueue[T]) Push(elements ...T) error {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	for _, e := range elements {
+		heap.Push(pq.memory, e)
+		if pq.memory.Len() > pq.capacity {
+			if err := pq.spill(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pq *priorityQueue[T]) Pop() (T, error) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	var t T
+	r := pq.best()
+	if r == nil {
+		if pq.memory.Len() == 0 {
+			return t, Empty
+		}
+		return heap.Pop(pq.memory).(T), nil
+	}
+
+	t = r.head
+	if err := pq.advance(r); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+func (pq *priorityQueue[T]) Peek() (T, error) {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	var t T
+	r := pq.best()
+	if r == nil {
+		if pq.memory.Len() == 0 {
+			return t, Empty
+		}
+		return pq.memory.s[0], nil
+	}
+	return r.head, nil
+}
+
+func (pq *priorityQueue[T]) Len() int {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	n := pq.memory.Len()
+	for _, r := range pq.runs {
+		n += len(r.ids) - r.pos
+	}
+	return n
+}
+
+func (pq *priorityQueue[T]) Cleanup() {
+	pq.Storage.Cleanup()
+}
+
+// best returns the run whose head is smaller than every other head
+// and the top of the memory heap, nil if the memory wins
+func (pq *priorityQueue[T]) best() *run[T] {
+	var best *run[T]
+	for _, r := range pq.runs {
+		if best == nil || pq.memory.less(r.head, best.head) {
+			best = r
+		}
+	}
+	if best != nil && pq.memory.Len() > 0 && !pq.memory.less(best.head, pq.memory.s[0]) {
+		return nil
+	}
+	return best
+}
+
+// spill writes the worse half of the memory to the disk as a new run
+func (pq *priorityQueue[T]) spill() error {
+	sort.Sort(pq.memory)
+	keep := pq.capacity / 2
+
+	r := &run[T]{ids: make([]int, 0, len(pq.memory.s)-keep)}
+	for _, e := range pq.memory.s[keep:] {
+		if err := storage.Write(pq.Storage, pq.diskIndex, e); err != nil {
+			return err
+		}
+		r.ids = append(r.ids, pq.diskIndex)
+		pq.diskIndex++
+	}
+	r.head = pq.memory.s[keep]
+
+	// a sorted slice is a valid heap
+	var zero T
+	for i := keep; i < len(pq.memory.s); i++ {
+		pq.memory.s[i] = zero
+	}
+	pq.memory.s = pq.memory.s[:keep]
+
+	pq.runs = append(pq.runs, r)
+	return pq.merge()
+}
+
+// merge keeps the number of runs logarithmic: once there are fanIn runs
+// of the same level, they are merged into one run of the next level
+func (pq *priorityQueue[T]) merge() error {
+	for level := 0; ; level++ {
+		var same, rest []*run[T]
+		for _, r := range pq.runs {
+			if r.level == level {
+				same = append(same, r)
+			} else {
+				rest = append(rest, r)
+			}
+		}
+		if len(same) < fanIn {
+			return nil
+		}
+
+		merged := &run[T]{level: level + 1}
+		for len(same) > 0 {
+			b := 0
+			for i := range same {
+				if pq.memory.less(same[i].head, same[b].head) {
+					b = i
+				}
+			}
+			if err := storage.Write(pq.Storage, pq.diskIndex, same[b].head); err != nil {
+				return err
+			}
+			if len(merged.ids) == 0 {
+				merged.head = same[b].head
+			}
+			merged.ids = append(merged.ids, pq.diskIndex)
+			pq.diskIndex++
+
+			if err := pq.next(same[b]); err != nil {
+				return err
+			}
+			if same[b].pos == len(same[b].ids) {
+				same = append(same[:b], same[b+1:]...)
+			}
+		}
+		pq.runs = append(rest, merged)
+	}
+}
+
+// advance drops the head of the run and the run itself once it is exhausted
+func (pq *priorityQueue[T]) advance(r *run[T]) error {
+	if err := pq.next(r); err != nil {
+		return err
+	}
+	if r.pos == len(r.ids) {
+		for i := range pq.runs {
+			if pq.runs[i] == r {
+				pq.runs = append(pq.runs[:i], pq.runs[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// next removes the head of r from the disk and reads the following one
+func (pq *priorityQueue[T]) next(r *run[T]) error {
+	pq.Remove(r.ids[r.pos])
+	r.pos++
+	if r.pos == len(r.ids) {
+		return nil
+	}
+	t, err := storage.Read[T](pq.Storage, r.ids[r.pos])
+	if err != nil {
+		return err
+	}
+	r.head = t
+	return nil
+}
+
+// items implements heap.Interface and sort.Interface
+type items[T any] struct {
+	s    []T
+	less func(a, b T) bool
+}
+
+func (h *items[T]) Len() int           { return len(h.s) }
+func (h *items[T]) Less(i, j int) bool { return h.less(h.s[i], h.s[j]) }
+func (h *items[T]) Swap(i, j int)      { h.s[i], h.s[j] = h.s[j], h.s[i] }
+func (h *items[T]) Push(x any)         { h.s = append(h.s, x.(T)) }
+func (h *items[T]) Pop() any {
+	var zero T
+	t := h.s[len(h.s)-1]
+	h.s[len(h.s)-1] = zero
+	h.s = h.s[:len(h.s)-1]
+	return t
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	pq, err := NewPriorityQueue(16, func(a, b int) bool { return a < b }, os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Cleanup()
+
+	// enough elements to force merges of the spilled runs
+	n := 2000
+	for _, x := range rand.Perm(n) {
+		if err := pq.Push(x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pq.Len() != n {
+		t.Errorf("Len() = %d, want %d", pq.Len(), n)
+	}
+
+	pqc, _ := pq.(*priorityQueue[int])
+	if pqc.memory.Len() > 16 || len(pqc.runs) > fanIn*4 {
+		t.Errorf("unexpected memory or runs: memory=%d, runs=%d", pqc.memory.Len(), len(pqc.runs))
+	}
+
+	for i := 0; i < n; i++ {
+		if x, _ := pq.Peek(); x != i {
+			t.Fatalf("Peek() = %d, want %d", x, i)
+		}
+		x, err := pq.Pop()
+		if err != nil || x != i {
+			t.Fatalf("Pop() = %d, %v, want %d", x, err, i)
+		}
+		// pushing back in the middle of draining keeps the order
+		if i == n/2 {
+			pq.Push(-1)
+			if x, _ := pq.Pop(); x != -1 {
+				t.Fatalf("Pop() = %d, want -1", x)
+			}
+		}
+	}
+
+	if _, err := pq.Pop(); !errors.Is(err, Empty) {
+		t.Errorf("Pop() on empty queue: %v, want Empty", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Package queue implements FIFO queues whose head is in memory
+// and potentially long tail is on the disk
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+var Empty = errors.New("queue is empty")
+
+// Queue is an interface to work with a FIFO queue
+// whose head is in memory and potentially long tail is on the disk.
+// It is safe for concurrent use.
+type Queue[T any] interface {
+	// Enqueue: adds the elements to the back of the queue
+	Enqueue(elements ...T) error
+	// Dequeue: removes and returns the element at the front of the queue.
+	// Returns Empty if there is none
+	Dequeue() (T, error)
+	// DequeueWait: like Dequeue, but blocks until an element is available
+	// or the ctx is done
+	DequeueWait(ctx context.Context) (T, error)
+	// Peek: returns the element at the front of the queue without removing it
+	Peek() (T, error)
+	// Len: returns the number of elements
+	Len() int
+	// Cleanup: removes the disk files and stops the go routine
+	// that is tasked with disk cleanup
+	Cleanup()
+}
+
+type queue[T any] struct {
+	*storage.Storage
+	mu sync.Mutex
+	// memory is a ring buffer holding the front of the queue
+	memory []T
+	start  int
+	n      int
+	// ids of the disk files holding the back of the queue.
+	// Dequeued ids before first are compacted away lazily,
+	// so removing from the front doesn't copy the whole slice every time
+	ids       []int
+	first     int
+	diskIndex int
+	// closed and replaced on Enqueue to wake up DequeueWait callers
+	notify chan struct{}
+}
+
+// New creates a Queue object. It accepts 2 parameters:
+// capacity: the maximum number of elements that will live in memory.
+// rootPath:  the path on the disk where the queue tail will live.
+// a randomly named subdir will be created, so multiple queues
+// with the same rootPath (e.g. system temp directory) won't collide
+func New[T any](capacity int, rootPath string) (Queue[T], error) {
+	if capacity < 0 {
+		return nil, errors.New("negative capacity")
+	}
+	s, err := storage.New(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue[T]{
+		Storage: s,
+		memory:  make([]T, capacity),
+		ids:     make([]int, 0, 4096),
+		notify:  make(chan struct{}),
+	}, nil
+}
+
+func (q *queue[T]) Enqueue(elements ...T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var err error
+	for _, e := range elements {
+		// elements go to memory only while nothing is on the disk,
+		// otherwise the order would be broken
+		if q.first == len(q.ids) && q.n < len(q.memory) {
+			q.memory[(q.start+q.n)%len(q.memory)] = e
+			q.n++
+			continue
+		}
+
+		if err = storage.Write(q.Storage, q.diskIndex, e); err != nil {
+			break
+		}
+		q.ids = append(q.ids, q.diskIndex)
+		q.diskIndex++
+	}
+
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return err
+}
+
+func (q *queue[T]) Dequeue() (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dequeue()
+}
+
+func (q *queue[T]) DequeueWait(ctx context.Context) (T, error) {
+	for {
+		q.mu.Lock()
+		if q.len() > 0 {
+			defer q.mu.Unlock()
+			return q.dequeue()
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			var t T
+			return t, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+func (q *queue[T]) Peek() (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.refill(); err != nil {
+		var t T
+		return t, err
+	}
+	if q.n > 0 {
+		return q.memory[q.start], nil
+	}
+	if q.first < len(q.ids) {
+		return storage.Read[T](q.Storage, q.ids[q.first])
+	}
+	var t T
+	return t, Empty
+}
+
+func (q *queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.len()
+}
+
+func (q *queue[T]) Cleanup() {
+	q.Storage.Cleanup()
+}
+
+func (q *queue[T]) len() int {
+	return q.n + len(q.ids) - q.first
+}
+
+func (q *queue[T]) dequeue() (T, error) {
+	var t T
+	if err := q.refill(); err != nil {
+		return t, err
+	}
+
+	if q.n > 0 {
+		t = q.memory[q.start]
+		var zero T
+		q.memory[q.start] = zero
+		q.start = (q.start + 1) % len(q.memory)
+		q.n--
+		return t, nil
+	}
+
+	// no memory at all: read straight from the disk
+	if q.first < len(q.ids) {
+		t, err := storage.Read[T](q.Storage, q.ids[q.first])
+		if err != nil {
+			return t, err
+		}
+		q.popID()
+		return t, nil
+	}
+
+	return t, Empty
+}
+
+// refill moves the front of the disk tail to the memory once it is drained
+func (q *queue[T]) refill() error {
+	if q.n > 0 {
+		return nil
+	}
+	for q.n < len(q.memory) && q.first < len(q.ids) {
+		t, err := storage.Read[T](q.Storage, q.ids[q.first])
+		if err != nil {
+			return err
+		}
+		q.memory[(q.start+q.n)%len(q.memory)] = t
+		q.n++
+		q.popID()
+	}
+	return nil
+}
+
+func (q *queue[T]) popID() {
+	q.Remove(q.ids[q.first])
+	q.first++
+	if q.first == len(q.ids) {
+		q.ids = q.ids[:0]
+		q.first = 0
+		return
+	}
+	// compact once the dead prefix outweighs the live ids: amortized O(1)
+	if q.first > len(q.ids)/2 {
+		n := copy(q.ids, q.ids[q.first:])
+		q.ids = q.ids[:n]
+		q.first = 0
+	}
+}
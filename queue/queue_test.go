@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	q, err := New[int](10, os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Cleanup()
+
+	for i := 0; i < 100; i++ {
+		if err := q.Enqueue(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.Len() != 100 {
+		t.Errorf("Len() = %d, want 100", q.Len())
+	}
+
+	for i := 0; i < 50; i++ {
+		x, err := q.Dequeue()
+		if err != nil || x != i {
+			t.Fatalf("Dequeue() = %d, %v, want %d", x, err, i)
+		}
+	}
+
+	// interleave to cross the memory/disk boundary a few times
+	q.Enqueue(100, 101, 102)
+	for i := 50; i < 103; i++ {
+		if x, _ := q.Peek(); x != i {
+			t.Fatalf("Peek() = %d, want %d", x, i)
+		}
+		x, err := q.Dequeue()
+		if err != nil || x != i {
+			t.Fatalf("Dequeue() = %d, %v, want %d", x, err, i)
+		}
+	}
+
+	if _, err := q.Dequeue(); !errors.Is(err, Empty) {
+		t.Errorf("Dequeue() on empty queue: %v, want Empty", err)
+	}
+
+	qc, _ := q.(*queue[int])
+	if len(qc.ids) != 0 || qc.first != 0 {
+		t.Errorf("disk ids were not compacted: len=%d, first=%d", len(qc.ids), qc.first)
+	}
+}
+
+func TestDequeueWait(t *testing.T) {
+	q, err := New[string](0, os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.DequeueWait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DequeueWait() = %v, want DeadlineExceeded", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Enqueue("hello")
+	}()
+	x, err := q.DequeueWait(context.Background())
+	if err != nil || x != "hello" {
+		t.Errorf("DequeueWait() = %q, %v, want hello", x, err)
+	}
+}
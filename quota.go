@@ -0,0 +1,212 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// ErrDiskQuotaExceeded is wrapped by the errors of Append when the disk files
+// take WithMaxDiskBytes and the policy is QuotaFail
+var ErrDiskQuotaExceeded = errors.New("disk quota exceeded")
+
+// ErrQuotaExceeded is wrapped by the errors of Append when the Slicer can't have more
+// of the budget of its QuotaManager and the policy is QuotaFail
+var ErrQuotaExceeded = errors.New("shared disk quota exceeded")
+
+// QuotaPolicy tells what Append does when the disk quota is used up,
+// see WithMaxDiskBytes
+type QuotaPolicy int
+
+const (
+	// Append fails with ErrDiskQuotaExceeded
+	QuotaFail QuotaPolicy = iota
+	// Append deletes the oldest elements until there is room
+	QuotaEvictOldest
+	// Append waits until other calls delete enough elements
+	QuotaBlock
+)
+
+// reserve makes room on the disk for one more element
+// according to the quota policy
+func (c *config[T]) reserve() error {
+	if c.maxDiskBytes <= 0 && c.quota == nil {
+		return nil
+	}
+	for {
+		// the removals are accounted once they are done
+		c.settle()
+		policy, err := c.quotaPolicy, ErrDiskQuotaExceeded
+		var freed <-chan struct{}
+		if c.maxDiskBytes <= 0 || c.Used() < c.maxDiskBytes {
+			var ok bool
+			if c.quota == nil {
+				return nil
+			}
+			if ok, freed = c.quota.admits(c.Storage); ok {
+				return nil
+			}
+			policy, err = c.sharedPolicy, ErrQuotaExceeded
+		}
+
+		switch policy {
+		case QuotaEvictOldest:
+			if len(c.diskSlice) == 0 {
+				return err
+			}
+			// the evicted files must go
+			c.forget()
+			if err := c.del(0, 1); err != nil {
+				return err
+			}
+		case QuotaBlock:
+			select {
+			case <-c.done:
+				return err
+			default:
+			}
+			if freed == nil {
+				// releases the lock until a removal or Cleanup
+				c.freed.Wait()
+				continue
+			}
+			// until a removal of any Slicer of the manager
+			c.mu.Unlock()
+			select {
+			case <-freed:
+				c.quota.settle()
+			case <-c.done:
+			}
+			c.mu.Lock()
+		default:
+			return err
+		}
+	}
+}
+
+// room checks that there is room on the disk for one more element,
+// failing with ErrDiskQuotaExceeded or ErrQuotaExceeded whatever the
+// policy: for the calls that can't evict or wait half way, like
+// Batch.Commit
+func (c *config[T]) room() error {
+	if c.maxDiskBytes <= 0 && c.quota == nil {
+		return nil
+	}
+	c.settle()
+	if c.maxDiskBytes > 0 && c.Used() >= c.maxDiskBytes {
+		return ErrDiskQuotaExceeded
+	}
+	if c.quota != nil {
+		if ok, _ := c.quota.admits(c.Storage); !ok {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// settle waits for the queued removals
+func (c *config[T]) settle() {
+	if c.async != nil {
+		c.async.wg.Wait()
+	}
+	c.Wait()
+}
+
+// QuotaManager bounds the size of the disk files of several Slicers,
+// e.g. the dozens a process creates in the same temp dir, see
+// NewQuotaManager and WithQuotaManager. It is safe for concurrent use
+type QuotaManager struct {
+	budget int64
+	borrow bool
+	mu     sync.Mutex
+	// the weights of the registered Slicers
+	members map[*storage.Storage]int
+	// closed and replaced when a registered Slicer removes files
+	freed chan struct{}
+}
+
+// NewQuotaManager returns a QuotaManager sharing budget bytes among the
+// Slicers registered WithQuotaManager. Each Slicer gets a share of the
+// budget proportional to its weight among the registered ones. With
+// borrow, a Slicer may go over its share while the budget isn't used up,
+// at the expense of the others, otherwise the share bounds it
+func NewQuotaManager(budget int64, borrow bool) *QuotaManager {
+	return &QuotaManager{
+		budget:  budget,
+		borrow:  borrow,
+		members: make(map[*storage.Storage]int),
+		freed:   make(chan struct{}),
+	}
+}
+
+// Used returns the size of the disk files of the registered Slicers
+func (q *QuotaManager) Used() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var used int64
+	for s := range q.members {
+		used += s.Used()
+	}
+	return used
+}
+
+func (q *QuotaManager) join(s *storage.Storage, weight int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.members[s] = max(weight, 1)
+}
+
+func (q *QuotaManager) leave(s *storage.Storage) {
+	q.mu.Lock()
+	delete(q.members, s)
+	q.mu.Unlock()
+	// its share goes to the others
+	q.release()
+}
+
+// release wakes up the Slicers waiting for room
+func (q *QuotaManager) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// settle waits for the removals of the registered Slicers
+func (q *QuotaManager) settle() {
+	q.mu.Lock()
+	members := make([]*storage.Storage, 0, len(q.members))
+	for s := range q.members {
+		members = append(members, s)
+	}
+	q.mu.Unlock()
+	for _, s := range members {
+		s.Wait()
+	}
+}
+
+// admits tells if s can have one more disk file. If not, freed is closed
+// on the next removal
+func (q *QuotaManager) admits(s *storage.Storage) (ok bool, freed <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	weight, member := q.members[s]
+	if !member {
+		return true, q.freed
+	}
+	total := 0
+	var used, own int64
+	for m, w := range q.members {
+		total += w
+		u := m.Used()
+		used += u
+		if m == s {
+			own = u
+		}
+	}
+	if used >= q.budget {
+		return false, q.freed
+	}
+	return q.borrow || own < q.budget*int64(weight)/int64(total), q.freed
+}
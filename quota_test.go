@@ -0,0 +1,147 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestQuota(t *testing.T) {
+	// the size of one element file
+	probe, _ := New(make([]int, 0), os.TempDir())
+	probe.Append(1)
+	size := probe.(*config[int]).Used()
+	probe.Cleanup()
+
+	t.Run("fail", func(t *testing.T) {
+		s, _ := New(make([]int, 0, 2), os.TempDir(), WithMaxDiskBytes(5*size, QuotaFail))
+		defer s.Cleanup()
+		for i := 0; i < 7; i++ {
+			if err := s.Append(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Append(7); !errors.Is(err, ErrDiskQuotaExceeded) {
+			t.Errorf("Append() = %v, want ErrDiskQuotaExceeded", err)
+		}
+		if s.Len() != 7 {
+			t.Errorf("Len() = %d, want 7", s.Len())
+		}
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		s, _ := New(make([]int, 0, 2), os.TempDir(), WithMaxDiskBytes(5*size, QuotaEvictOldest))
+		defer s.Cleanup()
+		for i := 0; i < 50; i++ {
+			if err := s.Append(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.Len() != 7 {
+			t.Errorf("Len() = %d, want 7", s.Len())
+		}
+		if x, _ := s.Get(0); x != 43 {
+			t.Errorf("Get(0) = %d, want 43", x)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		s, _ := New(make([]int, 0, 2), os.TempDir(), WithMaxDiskBytes(5*size, QuotaBlock))
+		defer s.Cleanup()
+		for i := 0; i < 7; i++ {
+			s.Append(i)
+		}
+		done := make(chan error)
+		go func() { done <- s.Append(7) }()
+		select {
+		case err := <-done:
+			t.Fatalf("Append() = %v, want it to block", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		s.Delete(0, 1)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if x, _ := s.Get(6); x != 7 {
+			t.Errorf("Get(6) = %d, want 7", x)
+		}
+	})
+}
+
+func TestQuotaManager(t *testing.T) {
+	probe, _ := New(make([]int, 0), os.TempDir())
+	probe.Append(1)
+	size := probe.(*config[int]).Used()
+	probe.Cleanup()
+
+	t.Run("shares", func(t *testing.T) {
+		q := NewQuotaManager(9*size, false)
+		a, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 2, QuotaFail))
+		defer a.Cleanup()
+		b, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 1, QuotaFail))
+		defer b.Cleanup()
+		for s, want := range map[Slicer[int]]int{a: 6, b: 3} {
+			for i := 0; i < want; i++ {
+				if err := s.Append(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Append(want); !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("Append() = %v, want ErrQuotaExceeded", err)
+			}
+		}
+		if q.Used() != 9*size {
+			t.Errorf("Used() = %d, want %d", q.Used(), 9*size)
+		}
+		// the share of b goes to a
+		b.Cleanup()
+		if err := a.Append(6); err != nil {
+			t.Errorf("Append() = %v after the Cleanup of b", err)
+		}
+	})
+
+	t.Run("borrow", func(t *testing.T) {
+		q := NewQuotaManager(6*size, true)
+		a, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 1, QuotaFail))
+		defer a.Cleanup()
+		b, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 1, QuotaEvictOldest))
+		defer b.Cleanup()
+		for i := 0; i < 6; i++ {
+			if err := a.Append(i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := a.Append(6); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Append() = %v, want ErrQuotaExceeded", err)
+		}
+		// b has nothing to evict
+		if err := b.Append(0); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Append() = %v, want ErrQuotaExceeded", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		q := NewQuotaManager(4*size, false)
+		a, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 1, QuotaFail))
+		defer a.Cleanup()
+		b, _ := New(make([]int, 0), os.TempDir(), WithQuotaManager(q, 1, QuotaBlock))
+		defer b.Cleanup()
+		for i := 0; i < 2; i++ {
+			a.Append(i)
+			b.Append(i)
+		}
+		done := make(chan error)
+		go func() { done <- b.Append(2) }()
+		select {
+		case err := <-done:
+			t.Fatalf("Append() = %v, want it to block", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		// a leaving makes the whole budget the share of b
+		a.Cleanup()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+func (c *config[T]) Shuffle(r *rand.Rand) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+
+	return c.permute(perm(r, c.len()))
+}
+
+// permute moves the element perm[p] to the position p for every p.
+// The disk elements moving to the head are read first, their files then
+// take the head elements moving to the disk: only the elements crossing
+// the boundary are read and written
+func (c *config[T]) permute(perm []int) error {
+	c.forget()
+	h := len(c.slice)
+	head := make([]T, h)
+	var freed []int
+	for p, src := range perm[:h] {
+		if src < h {
+			head[p] = c.slice[src]
+			continue
+		}
+		id := c.diskSlice[src-h]
+		t, err := c.read(id)
+		if err != nil {
+			return fmt.Errorf(GetError, err)
+		}
+		head[p] = t
+		freed = append(freed, id)
+	}
+	disk := make([]int, len(c.diskSlice))
+	for p, src := range perm[h:] {
+		if src >= h {
+			disk[p] = c.diskSlice[src-h]
+			continue
+		}
+		id, err := c.overwrite(freed[0], c.slice[src])
+		if err != nil {
+			return err
+		}
+		freed = freed[1:]
+		disk[p] = id
+	}
+
+	copy(c.slice, head)
+	copy(c.diskSlice, disk)
+	if c.ttl > 0 {
+		born := make([]time.Time, len(perm))
+		for p, src := range perm {
+			born[p] = c.born[src]
+		}
+		c.born = born
+		c.shuffled = true
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Sample(n int, r *rand.Rand) ([]T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	if n < 0 || n > c.len() {
+		return nil, IndexOutOfBounds
+	}
+
+	// Floyd's algorithm: n distinct indices in n draws,
+	// without a permutation of all of them
+	picked := make(map[int]bool, n)
+	for j := c.len() - n; j < c.len(); j++ {
+		if k := intn(r, j+1); picked[k] {
+			picked[j] = true
+		} else {
+			picked[k] = true
+		}
+	}
+	indices := make([]int, 0, n)
+	for i := range picked {
+		indices = append(indices, i)
+	}
+	slices.Sort(indices)
+	return c.getBatch(indices)
+}
+
+// perm returns a random permutation of [0, n) from r,
+// or from the default source if r is nil
+func perm(r *rand.Rand, n int) []int {
+	if r == nil {
+		return rand.Perm(n)
+	}
+	return r.Perm(n)
+}
+
+// intn returns a random number in [0, n) from r,
+// or from the default source if r is nil
+func intn(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+	return r.Intn(n)
+}
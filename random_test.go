@@ -0,0 +1,98 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestShuffle(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+
+	if err := s.Shuffle(rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(x, seq(0, 100)) {
+		t.Errorf("Shuffle() kept the order")
+	}
+	sorted := slices.Clone(x)
+	slices.Sort(sorted)
+	if !slices.Equal(sorted, seq(0, 100)) {
+		t.Errorf("Shuffle() = %v, not a permutation", x)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the same source, the same permutation
+	cl.Shuffle(rand.New(rand.NewSource(1)))
+	if y, _ := cl.Slice(); fmt.Sprint(x) != fmt.Sprint(y) {
+		t.Errorf("Shuffle() = %v, then %v with the same source", x, y)
+	}
+}
+
+func TestSample(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	r := rand.New(rand.NewSource(1))
+	seen := make(map[int]bool)
+	for i := 0; i < 50; i++ {
+		x, err := s.Sample(10, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(x) != 10 || !slices.IsSorted(x) || len(slices.Compact(slices.Clone(x))) != 10 {
+			t.Fatalf("Sample() = %v, want 10 distinct elements in order", x)
+		}
+		for _, v := range x {
+			seen[v] = true
+		}
+	}
+	// 500 picks out of 100
+	if len(seen) < 90 {
+		t.Errorf("%d distinct elements sampled", len(seen))
+	}
+
+	if x, err := s.Sample(100, nil); err != nil || !slices.Equal(x, seq(0, 100)) {
+		t.Errorf("Sample(100) = %v, %v", x, err)
+	}
+	if _, err := s.Sample(101, nil); err != IndexOutOfBounds {
+		t.Errorf("Sample(101) = %v, want IndexOutOfBounds", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	want := seq(0, 100)
+	for _, k := range []int{1, 3, 10, 42, -7, 0, 100, 250, -1000} {
+		if err := s.Rotate(k); err != nil {
+			t.Fatal(err)
+		}
+		k = (k%100 + 100) % 100
+		want = append(want[k:], want[:k]...)
+		x, err := s.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(x, want) {
+			t.Fatalf("Rotate(%d) = %v, want %v", k, x, want)
+		}
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// ErrNotRaw is returned by GetReader when the elements are not []byte
+var ErrNotRaw = storage.ErrNotRaw
+
+func (c *config[T]) GetReader(index int) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	var zero T
+	if _, ok := any(zero).([]byte); !ok {
+		return nil, ErrNotRaw
+	}
+	if index < 0 || index >= c.len() {
+		return nil, IndexOutOfBounds
+	}
+
+	if index < len(c.slice) {
+		return io.NopCloser(bytes.NewReader(any(c.slice[index]).([]byte))), nil
+	}
+	id := c.diskSlice[index-len(c.slice)]
+	if t, ok := c.cached(id); ok {
+		return io.NopCloser(bytes.NewReader(any(t).([]byte))), nil
+	}
+	return storage.OpenReader(c.Storage, id)
+}
@@ -0,0 +1,66 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestGetReader(t *testing.T) {
+	blob := bytes.Repeat([]byte("0123456789"), 500)
+	for name, opts := range map[string][]Option{
+		"files":   nil,
+		"packed":  {WithPacking(1 << 16)},
+		"chunked": {WithChunking(1024)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, _ := New(make([][]byte, 0, 1), os.TempDir(), opts...)
+			defer s.Cleanup()
+			s.Append([]byte("head"), blob, []byte{})
+
+			for i, want := range [][]byte{[]byte("head"), blob, {}} {
+				x, err := s.Get(i)
+				if err != nil || !bytes.Equal(x, want) {
+					t.Errorf("Get(%d) = %d bytes, %v, want %d", i, len(x), err, len(want))
+				}
+				r, err := s.GetReader(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				x, err = io.ReadAll(r)
+				r.Close()
+				if err != nil || !bytes.Equal(x, want) {
+					t.Errorf("GetReader(%d) = %d bytes, %v, want %d", i, len(x), err, len(want))
+				}
+			}
+		})
+	}
+
+	// the stored bytes are raw
+	s, _ := New(make([][]byte, 0), os.TempDir())
+	defer s.Cleanup()
+	s.Append(blob)
+	fname := s.(*config[[]byte]).Path(0)
+	if info, _ := os.Stat(fname); info.Size() != int64(len(blob))+12 {
+		t.Errorf("the file is %d bytes for %d", info.Size(), len(blob))
+	}
+	b, _ := os.ReadFile(fname)
+	b[100] ^= 1
+	os.WriteFile(fname, b, 0600)
+	r, err := s.GetReader(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("reading a corrupted element: %v, want ErrCorrupted", err)
+	}
+
+	ints := intSlicer()
+	defer ints.Cleanup()
+	if _, err := ints.GetReader(50); err != ErrNotRaw {
+		t.Errorf("GetReader() of an int = %v, want ErrNotRaw", err)
+	}
+}
@@ -0,0 +1,407 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// the file in the Slicer directory that records the disk tail, see WithManifest
+const manifestName = "manifest"
+
+// manifestVersion is the format of the manifests written by this
+// version, 0 for the ones written before it was recorded, 2 since their
+// changes are logged
+const manifestVersion = 2
+
+// ErrManifest is returned by Open for a manifest it can't adopt:
+// written by a newer version or for elements encoded another way
+var ErrManifest = errors.New("incompatible manifest")
+
+type manifest struct {
+	Version int
+	// the codec of the elements, see RawElement, "" before Version 1
+	Codec string
+	// the schema version of WithSchema, and the ones of the elements
+	// written with another one by id
+	Schema int
+	Legacy map[int]int
+	// the types of RegisterConcrete for the element type
+	Concrete []string
+	// the number of elements the manifest lists, the Head included
+	Len int
+	// ids of the disk files in the Slicer order
+	DiskSlice []int
+	DiskIndex int64
+	// ids of the copies of the head written by Flush, before the disk tail
+	Head []int
+	// the last record of the log included, see WithWAL
+	WALSeq int64
+	// the fan-out of WithSharding
+	Shards int
+	// the elements in the block files, see WithPacking
+	Packed map[int]storage.Entry
+	// the number of chunks of the chunked elements, see WithChunking
+	Chunked map[int]int
+	// the subdirs of WithRootPaths and the ones of their elements
+	Roots  []string
+	Spread Spread
+	Placed map[int]int
+	// a Compact in progress, which Open finishes: the new ids by old id,
+	// and whether the files were all moved to their temporary name yet
+	Renumbered map[int]int
+	Renamed    bool
+	// the last record of the log of the manifest included
+	LogSeq int64
+}
+
+// persist records the disk tail in the manifest if WithManifest is set.
+// With WithWAL, the log records the logged calls and the other ones
+// write a checkpoint
+func (c *config[T]) persist() error {
+	if !c.manifest {
+		return nil
+	}
+	if c.wal {
+		if c.logging {
+			return nil
+		}
+		return c.checkpoint()
+	}
+	return c.save()
+}
+
+// state returns the manifest listing the disk tail
+func (c *config[T]) state() manifest {
+	ids := c.diskSlice
+	if len(c.flushed) > 0 {
+		ids = append(slices.Clone(c.flushed), ids...)
+	}
+	return manifest{
+		Version:   manifestVersion,
+		Codec:     storage.CodecOf[T](),
+		Schema:    c.schema,
+		Legacy:    c.legacy,
+		Concrete:  registered[T](),
+		Len:       len(ids),
+		DiskSlice: c.diskSlice,
+		DiskIndex: c.diskIndex,
+		Head:      c.flushed,
+		WALSeq:    c.walSeq,
+		Shards:    c.Shards(),
+		Packed:    c.Entries(ids),
+		Chunked:   c.ChunkCounts(ids),
+		Roots:     c.RootDirs(),
+		Spread:    c.spread,
+		Placed:    c.Placed(ids),
+	}
+}
+
+// check tells whether the elements the manifest lists can be adopted
+// as ones of the codec, "" for any
+func (m manifest) check(codec string) error {
+	if m.Version > manifestVersion {
+		return fmt.Errorf("%w: version %d, this one reads up to %d", ErrManifest, m.Version, manifestVersion)
+	}
+	if codec != "" && m.Codec != "" && m.Codec != codec {
+		return fmt.Errorf("%w: %s elements, the type is %s", ErrManifest, m.Codec, codec)
+	}
+	if m.Version > 0 && m.Len != len(m.Head)+len(m.DiskSlice) {
+		return fmt.Errorf("%w: %d elements, %d listed", ErrManifest, m.Len, len(m.Head)+len(m.DiskSlice))
+	}
+	return nil
+}
+
+// Open adopts the directory of a Slicer created WithManifest,
+// e.g. after the process died. It accepts 2 parameters:
+// slice:  like with New, the memory for the head. Only its capacity is used.
+// The head is refilled from the disk tail.
+// path:  the Slicer directory, as returned by Dir or ScanOrphans.
+// Only the disk tail survives a restart: the elements that lived in memory
+// are lost, unless they were written by Flush. Leftover files that are not in the manifest are removed.
+// Fails with ErrLocked if another process owns the directory.
+func Open[T any](slice []T, path string, opts ...Option) (Slicer[T], error) {
+	s, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	o := apply(opts)
+	// before reading the manifest the owner may still be changing
+	if err := s.Own(o.stealLock); err != nil {
+		release(s)
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	m, err := loadManifest(path)
+	if err != nil {
+		release(s)
+		return nil, fmt.Errorf("could not read the manifest of %s: %w", path, err)
+	}
+	legacy, err := m.adopt(storage.CodecOf[T](), o)
+	if err == nil {
+		_, err = migration[T](o)
+	}
+	if err == nil {
+		err = probe[T](o)
+	}
+	if err == nil {
+		err = unregistered[T](m.Concrete)
+	}
+	if err != nil {
+		release(s)
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	// the layout of the directory is the one it was created with
+	o.shards = m.Shards
+	o.roots, o.spread = nil, m.Spread
+	s.AdoptRoots(m.Roots, m.Spread, m.Placed)
+	if err := setup(s, o); err != nil {
+		release(s)
+		return nil, err
+	}
+	s.Adopt(m.Packed)
+	s.AdoptChunks(m.Chunked)
+
+	c := newConfig(s, slice[:0], o)
+	c.diskIndex = m.DiskIndex
+	c.legacy = legacy
+	c.mlog = &manifestLog{seq: m.LogSeq}
+	if m.Renumbered != nil {
+		if err := c.resume(&m); err != nil {
+			c.abandon()
+			return nil, fmt.Errorf("could not open %s: %w", path, err)
+		}
+	}
+
+	referenced := make(map[string]bool, len(m.DiskSlice)+2)
+	referenced[filepath.Join(path, manifestName)] = true
+	referenced[filepath.Join(path, walName)] = true
+	referenced[filepath.Join(path, manifestLogName)] = true
+	for _, b := range s.Blocks() {
+		referenced[b] = true
+	}
+	// the head of a Flush comes first, refill moves it back to memory
+	for _, id := range append(m.Head, m.DiskSlice...) {
+		if err := c.Exists(id); err != nil {
+			log.Printf("dropping element %d of %s: %s", id, path, err.Error())
+			continue
+		}
+		for _, name := range c.Names(id) {
+			referenced[name] = true
+		}
+		c.diskSlice = append(c.diskSlice, id)
+		if c.ttl > 0 {
+			c.born = append(c.born, time.Now())
+		}
+	}
+
+	files, err := s.Files()
+	if err != nil {
+		c.abandon()
+		return nil, err
+	}
+	for _, f := range files {
+		if referenced[f] {
+			continue
+		}
+		if err := os.Remove(f); err != nil {
+			log.Printf("error removing file %s: %s", f, err.Error())
+		}
+	}
+
+	if err := c.Recount(c.diskSlice); err != nil {
+		c.abandon()
+		return nil, err
+	}
+	if err := c.Replicate(c.diskSlice...); err != nil {
+		c.abandon()
+		return nil, err
+	}
+	if err := c.refill(); err != nil {
+		c.abandon()
+		return nil, err
+	}
+	if c.wal {
+		c.replay(m.WALSeq)
+	}
+	if err := c.persist(); err != nil {
+		c.abandon()
+		return nil, err
+	}
+	c.register()
+	return c, nil
+}
+
+// release unlocks the directory of an Open that failed and stops its
+// cleaners, leaving the files as they are
+func release(s *storage.Storage) {
+	s.Keep()
+	s.Cleanup()
+}
+
+// abandon is release for the Slicer an Open failed to set up
+func (c *config[T]) abandon() {
+	c.Keep()
+	c.Cleanup()
+}
+
+// OpenReadOnly attaches to the directory of a Slicer created WithManifest
+// that another process keeps using, e.g. to inspect or export it. Like
+// with Open, slice is the memory for the head, which is filled from the
+// disk tail. The returned Slicer is read only and sees the elements as of
+// the call: the ones the owner deletes later fail to be read. The files
+// are never removed, Cleanup only detaches. Until then, the Compact of
+// the owner fails with ErrLocked, as it would renumber the files
+func OpenReadOnly[T any](slice []T, path string, opts ...Option) (Slicer[T], error) {
+	c, _, err := attach(slice, path, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// attach is OpenReadOnly, also returning the manifest it read.
+// With raw, the elements are not decoded: they may be of any codec
+// and schema version
+func attach[T any](slice []T, path string, raw bool, opts ...Option) (*config[T], manifest, error) {
+	s, err := storage.Open(path)
+	if err != nil {
+		return nil, manifest{}, err
+	}
+	// after a renumbering in progress, so the manifest is the new one
+	if err := s.Attach(); err != nil {
+		s.Cleanup()
+		return nil, manifest{}, err
+	}
+	m, err := loadManifest(path)
+	if err != nil {
+		s.Cleanup()
+		return nil, manifest{}, fmt.Errorf("could not read the manifest of %s: %w", path, err)
+	}
+	o := apply(opts)
+	var legacy map[int]int
+	if raw {
+		err = m.check("")
+	} else {
+		legacy, err = m.adopt(storage.CodecOf[T](), o)
+		if err == nil {
+			_, err = migration[T](o)
+		}
+		if err == nil {
+			err = probe[T](o)
+		}
+		if err == nil {
+			err = unregistered[T](m.Concrete)
+		}
+	}
+	if err != nil {
+		s.Cleanup()
+		return nil, manifest{}, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	o.shards = m.Shards
+	o.roots, o.spread = nil, m.Spread
+	s.AdoptRoots(m.Roots, m.Spread, m.Placed)
+	// nothing is written, the directory is left as the owner set it
+	o.readOnly, o.manifest, o.wal, o.appendOnly = true, false, false, false
+	o.fileMode, o.dirMode = 0, 0
+	o.asyncWorkers = 0
+	o.autoCompaction = AutoCompaction{}
+	o.replica = ""
+	// the owner accounts for the files
+	o.quota = nil
+	if err := setup(s, o); err != nil {
+		s.Cleanup()
+		return nil, manifest{}, err
+	}
+	s.Adopt(m.Packed)
+	s.AdoptChunks(m.Chunked)
+
+	c := newConfig(s, slice[:0], o)
+	c.diskIndex = m.DiskIndex
+	c.legacy = legacy
+	c.diskSlice = append(append(c.diskSlice, m.Head...), m.DiskSlice...)
+	if c.ttl > 0 {
+		for range c.diskSlice {
+			c.born = append(c.born, time.Now())
+		}
+	}
+	if err := c.Recount(c.diskSlice); err != nil {
+		c.Cleanup()
+		return nil, manifest{}, err
+	}
+	if err := c.refill(); err != nil {
+		c.Cleanup()
+		return nil, manifest{}, err
+	}
+	c.register()
+	return c, m, nil
+}
+
+// Orphan is a Slicer directory that wasn't modified for a while,
+// most likely left behind by a process that died without Cleanup
+type Orphan struct {
+	Path    string
+	ModTime time.Time
+	// Manifest tells if the directory can be adopted with Open
+	Manifest bool
+}
+
+// ScanOrphans lists the Slicer directories in rootPath
+// that were not modified for at least age. The directories locked by
+// a live Slicer are skipped, whatever their age: the writes to their
+// shards or roots of WithSharding and WithRoots don't touch them
+func ScanOrphans(rootPath string, age time.Duration) ([]Orphan, error) {
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	deadline := time.Now().Add(-age)
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), storage.Prefix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			continue
+		}
+		path := filepath.Join(rootPath, e.Name())
+		if storage.Owned(path) {
+			continue
+		}
+		_, err = os.Stat(filepath.Join(path, manifestName))
+		orphans = append(orphans, Orphan{
+			Path:     path,
+			ModTime:  info.ModTime(),
+			Manifest: err == nil,
+		})
+	}
+	return orphans, nil
+}
+
+// RemoveOrphans removes the directories found by ScanOrphans
+// and returns their paths
+func RemoveOrphans(rootPath string, age time.Duration) ([]string, error) {
+	orphans, err := ScanOrphans(rootPath, age)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	var errs []error
+	for _, o := range orphans {
+		if err := os.RemoveAll(o.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, o.Path)
+	}
+	return removed, errors.Join(errs...)
+}
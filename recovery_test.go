@@ -0,0 +1,448 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+func TestOpen(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		s.Append(i)
+	}
+	s.Delete(20, 5)
+	s.(*config[int]).Wait()
+	// leftovers of a crash: a partial write and an element being removed
+	os.WriteFile(filepath.Join(s.Dir(), "77.123.tmp"), []byte("partial"), 0644)
+	os.WriteFile(filepath.Join(s.Dir(), "12345"), []byte("gone"), 0644)
+
+	// the process "dies": the head is lost, the disk tail survives
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+
+	if o.Len() != 35 {
+		t.Errorf("Len() = %d, want 35", o.Len())
+	}
+	x, err := o.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range append(seq(10, 20), seq(25, 50)...) {
+		if x[i] != want {
+			t.Fatalf("element %d: %d, want %d", i, x[i], want)
+		}
+	}
+	for _, name := range []string{"77.123.tmp", "12345"} {
+		if _, err := os.Stat(filepath.Join(s.Dir(), name)); err == nil {
+			t.Errorf("leftover %s was not removed", name)
+		}
+	}
+
+	o.Append(100)
+	if x, _ := o.Get(35); x != 100 {
+		t.Errorf("Get(35) = %d, want 100", x)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithManifest(), WithServeAfterFlush())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		s.Append(i)
+	}
+	s.Delete(5, 10)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(100); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Append() after Flush = %v, want ErrReadOnly", err)
+	}
+	if x, _ := s.Get(4); x != 4 {
+		t.Errorf("Get(4) after Flush = %d, want 4", x)
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	// the directory outlives the Cleanup of a flushed Slicer
+	s.Close()
+
+	// a smaller head: the rest of the flushed one stays on the disk
+	o, err := Open(make([]int, 0, 4), s.Dir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := o.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(seq(0, 5), seq(15, 30)...); !slices.Equal(x, want) {
+		t.Errorf("Slice() after Open = %v, want %v", x, want)
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := o.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(o.Dir()); err != nil {
+		t.Errorf("Flush removed the directory: %v", err)
+	}
+
+	o, err = Open(make([]int, 0, 10), s.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	if o.Len() != 20 {
+		t.Errorf("Len() after the second Open = %d, want 20", o.Len())
+	}
+
+	n, _ := New(make([]int, 0, 1), os.TempDir())
+	defer n.Cleanup()
+	if err := n.Flush(); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("Flush() without a manifest = %v, want ErrNoManifest", err)
+	}
+}
+
+func TestWAL(t *testing.T) {
+	s, err := New(make([]int, 0, 4), os.TempDir(), WithWAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := seq(0, 20)
+	for i := range want {
+		s.Append(i)
+	}
+	s.Put(1, 100)
+	s.Put(10, 110)
+	s.Delete(5, 3)
+	want[1], want[10] = 100, 110
+	want = slices.Delete(want, 5, 8)
+	// an unlogged call writes a checkpoint
+	s.Swap(0, 12)
+	want[0], want[12] = want[12], want[0]
+	b := s.Batch()
+	b.Append(200, 201)
+	b.Delete(0, 1)
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	s.Truncate(15)
+	want = append(want, 200, 201)[1:16]
+	// the files of the checkpoint are kept until the next one
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+	s.(*config[int]).Wait()
+	// a torn record of a call in flight
+	f, _ := os.OpenFile(filepath.Join(s.Dir(), walName), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{1, 2, 3})
+	f.Close()
+
+	// the process "dies": Open replays the log on the checkpoint
+	o, err := Open(make([]int, 0, 8), s.Dir(), WithWAL(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	x, err := o.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(x, want) {
+		t.Errorf("Slice() after Open = %v, want %v", x, want)
+	}
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	o.Append(300)
+	if x, _ := o.Get(15); x != 300 {
+		t.Errorf("Get(15) = %d, want 300", x)
+	}
+}
+
+func TestWALRewrites(t *testing.T) {
+	for name, tc := range map[string]struct {
+		call func(s Slicer[int]) error
+		want []int
+	}{
+		"PutBatch": {func(s Slicer[int]) error { return s.PutBatch(0, []int{100, 101, 102, 103}) }, []int{100, 101, 102, 103, 4, 5}},
+		"Fill":     {func(s Slicer[int]) error { return s.Fill(7, 0, 4) }, []int{7, 7, 7, 7, 4, 5}},
+		"Swap":     {func(s Slicer[int]) error { return s.Swap(0, 4) }, []int{4, 1, 2, 3, 0, 5}},
+		"Reverse":  {func(s Slicer[int]) error { return s.Reverse() }, []int{5, 4, 3, 2, 1, 0}},
+		"Rotate":   {func(s Slicer[int]) error { return s.Rotate(2) }, []int{2, 3, 4, 5, 0, 1}},
+	} {
+		s, err := New(make([]int, 0, 2), os.TempDir(), WithWAL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Append(seq(0, 6)...)
+		// unlogged calls, the files of the checkpoint hold the head now
+		s.Reverse()
+		s.Reverse()
+		if err := tc.call(s); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		s.(*config[int]).Wait()
+
+		o, err := Open(make([]int, 0, 2), s.Dir(), WithWAL(), WithStealLock())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		x, err := o.Slice()
+		if err != nil || !slices.Equal(x, tc.want) {
+			t.Errorf("%s: Slice() after Open = %v, %v, want %v", name, x, err, tc.want)
+		}
+		if err := o.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		o.Cleanup()
+	}
+}
+
+func TestManifestLog(t *testing.T) {
+	s, err := New(make([]string, 0, 2), os.TempDir(), WithManifest(), WithPacking(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append("a", "b", "c")
+	fname := filepath.Join(s.Dir(), manifestName)
+	written, _ := os.ReadFile(fname)
+	for i := 0; i < 50; i++ {
+		s.Append(strings.Repeat("x", i))
+	}
+	// rewritten in place, reusing the ids of the removed files
+	s.Put(10, "put")
+	s.Delete(20, 5)
+	s.Delete(0, 3)
+	s.Swap(5, 30)
+	s.Append("d", "e")
+	if b, _ := os.ReadFile(fname); !slices.Equal(b, written) {
+		t.Error("the manifest was written in full")
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	reopen := func() {
+		t.Helper()
+		want, _ := s.Slice()
+		c := s.(*config[string])
+		c.Disown()
+		o, err := Open(make([]string, 0, 2), s.Dir(), WithStealLock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Own(true)
+		defer o.Cleanup()
+		o.(*config[string]).Keep()
+		if got, _ := o.Slice(); !slices.Equal(got, want[2:]) {
+			t.Errorf("Slice() after Open = %q, want %q", got, want[2:])
+		}
+		if err := o.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+	reopen()
+
+	// the log outgrows the manifest
+	for i := 0; i < manifestLogMin; i++ {
+		s.Append("f")
+	}
+	if b, _ := os.ReadFile(fname); slices.Equal(b, written) {
+		t.Error("the manifest was not written in full")
+	}
+	s.Delete(3, 1)
+	// a torn record of a crash
+	f, _ := os.OpenFile(filepath.Join(s.Dir(), manifestLogName), os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte("torn"))
+	f.Close()
+	reopen()
+}
+
+func TestOpenFailure(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 20)...)
+	c := s.(*config[int])
+	c.Disown()
+	// the element refill moves to the head is corrupted
+	fname := c.Path(c.diskSlice[0])
+	os.WriteFile(fname, []byte("corrupted"), 0600)
+
+	if _, err := Open(make([]int, 0, 5), s.Dir()); err == nil {
+		t.Fatal("Open() succeeded")
+	}
+	// a failed Open leaves the directory as it was, unlocked
+	if storage.Owned(s.Dir()) {
+		t.Error("the directory is still locked")
+	}
+	if _, err := os.Stat(fname); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	root := t.TempDir()
+	live, err := New(make([]int, 0, 1), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Cleanup()
+	dead, err := New(make([]int, 0, 1), root, WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Append(1, 2, 3)
+	// the process died, its lock is released
+	dead.(*config[int]).Disown()
+	// the writes go to the shards, not to the directory
+	sharded, err := New(make([]int, 0, 1), root, WithSharding(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sharded.Cleanup()
+	sharded.Append(1, 2, 3)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(dead.Dir(), old, old)
+	os.Chtimes(sharded.Dir(), old, old)
+
+	orphans, err := ScanOrphans(root, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].Path != dead.Dir() || !orphans[0].Manifest {
+		t.Fatalf("ScanOrphans() = %v, want %s with a manifest", orphans, dead.Dir())
+	}
+
+	removed, err := RemoveOrphans(root, time.Hour)
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("RemoveOrphans() = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(dead.Dir()); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed", dead.Dir())
+	}
+	for _, s := range []Slicer[int]{live, sharded} {
+		if _, err := os.Stat(s.Dir()); err != nil {
+			t.Errorf("live %s: %v", s.Dir(), err)
+		}
+	}
+}
+
+func seq(start, end int) []int {
+	s := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	owner, err := New(make([]int, 0, 10), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Cleanup()
+	owner.Append(seq(0, 50)...)
+	owner.Delete(0, 5)
+
+	r, err := OpenReadOnly(make([]int, 0, 10), owner.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the disk tail of the owner, as of the call
+	owner.Append(50)
+	if got, _ := r.Slice(); !slices.Equal(got, seq(15, 50)) {
+		t.Errorf("Slice() = %v, want %v", got, seq(15, 50))
+	}
+	if err := r.Append(1); err != ErrReadOnly {
+		t.Errorf("Append() = %v, want ErrReadOnly", err)
+	}
+	if err := owner.Compact(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Compact() with a reader = %v, want ErrLocked", err)
+	}
+
+	// detaching leaves the files alone
+	r.Cleanup()
+	if err := owner.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := owner.Slice(); !slices.Equal(got, seq(5, 51)) {
+		t.Errorf("owner Slice() = %v, want %v", got, seq(5, 51))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	owner, err := New(make([]string, 0, 2), os.TempDir(), WithManifest(), WithPacking(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owner.Cleanup()
+	owner.Append("a", "b", "c", strings.Repeat("d", 100))
+
+	info, err := Inspect(owner.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Len != 2 || info.Packed != 1 || info.Bytes != owner.(*config[string]).Used() {
+		t.Errorf("Inspect() = %+v", info)
+	}
+	if info.Version != manifestVersion || info.Codec != "binary" {
+		t.Errorf("Inspect() = version %d, codec %s, want %d and binary", info.Version, info.Codec, manifestVersion)
+	}
+	if _, err := OpenReadOnly(make([][]byte, 0, 2), owner.Dir()); !errors.Is(err, ErrManifest) {
+		t.Errorf("OpenReadOnly() as []byte = %v, want ErrManifest", err)
+	}
+	if b, codec, err := RawElement(owner.Dir(), 0); err != nil || string(b) != "c" || codec != "binary" {
+		t.Errorf("RawElement(0) = %q, %s, %v", b, codec, err)
+	}
+	if _, _, err := RawElement(owner.Dir(), 2); err != IndexOutOfBounds {
+		t.Errorf("RawElement(2) = %v, want IndexOutOfBounds", err)
+	}
+	if n, err := VerifyDir(owner.Dir()); n != 2 || err != nil {
+		t.Errorf("VerifyDir() = %d, %v, want 2", n, err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(seq(0, 20)...)
+
+	if _, err := Open(make([]int, 0, 10), s.Dir()); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Open() of an owned directory = %v, want ErrLocked", err)
+	}
+	// a hung owner
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	if o.Len() != 10 {
+		t.Errorf("Len() = %d after stealing the lock, want 10", o.Len())
+	}
+	if _, err := Open(make([]int, 0, 10), s.Dir()); !errors.Is(err, ErrLocked) {
+		t.Errorf("Open() after the steal = %v, want ErrLocked", err)
+	}
+}
@@ -0,0 +1,159 @@
+package slice_on_disk
+
+import (
+	"cmp"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// SlicerInfo describes a Slicer registered WithName, see ListSlicers
+type SlicerInfo struct {
+	Name  string
+	Dir   string
+	Stats Stats
+}
+
+// the Slicers of any type, see WithName
+var registry struct {
+	mu      sync.Mutex
+	slicers map[interface{ Stats() Stats }]SlicerInfo
+}
+
+// register adds c to the registry if it has a name, and publishes
+// its counters if it has an expvar name
+func (c *config[T]) register() {
+	if c.expvar != "" {
+		c.hooks.counts = &counts{}
+		publish(c.expvar, c, c.vars)
+	}
+	if c.name == "" {
+		return
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.slicers == nil {
+		registry.slicers = make(map[interface{ Stats() Stats }]SlicerInfo)
+	}
+	registry.slicers[c] = SlicerInfo{Name: c.name, Dir: c.RootPath}
+}
+
+func (c *config[T]) unregister() {
+	if c.expvar != "" {
+		// c.mu is held
+		freeze(c.expvar, c, c.counters(Stats{
+			Len:       c.len(),
+			MemLen:    len(c.slice),
+			DiskLen:   len(c.diskSlice),
+			DiskBytes: c.Used(),
+		}))
+	}
+	if c.name == "" {
+		return
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.slicers, c)
+}
+
+// ListSlicers returns the Slicers registered WithName that are not
+// cleaned up, the largest on the disk first, e.g. to find the buffers
+// that balloon in a long running service
+func ListSlicers() []SlicerInfo {
+	registry.mu.Lock()
+	slicers := make(map[interface{ Stats() Stats }]SlicerInfo, len(registry.slicers))
+	for s, info := range registry.slicers {
+		slicers[s] = info
+	}
+	registry.mu.Unlock()
+
+	// the Slicers are locked one at a time, outside of the registry
+	infos := make([]SlicerInfo, 0, len(slicers))
+	for s, info := range slicers {
+		info.Stats = s.Stats()
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b SlicerInfo) int {
+		if c := cmp.Compare(b.Stats.DiskBytes, a.Stats.DiskBytes); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Dir, b.Dir)
+	})
+	return infos
+}
+
+// RegistryHandler returns an http.Handler rendering ListSlicers as JSON,
+// to be mounted on a debug endpoint, e.g.
+// http.Handle("/debug/slicers", RegistryHandler())
+func RegistryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(ListSlicers()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// the functions behind the published variables and the Slicers they
+// are about: expvar can't replace a variable, a new Slicer with the same
+// name replaces its function instead
+var published struct {
+	mu    sync.Mutex
+	funcs map[string]func() any
+	owner map[string]any
+}
+
+// publish makes fn about owner the function of the variable name
+func publish(name string, owner any, fn func() any) {
+	published.mu.Lock()
+	defer published.mu.Unlock()
+	if published.funcs == nil {
+		published.funcs = make(map[string]func() any)
+		published.owner = make(map[string]any)
+	}
+	if _, ok := published.funcs[name]; !ok {
+		expvar.Publish(name, expvar.Func(func() any {
+			published.mu.Lock()
+			fn := published.funcs[name]
+			published.mu.Unlock()
+			return fn()
+		}))
+	}
+	published.funcs[name], published.owner[name] = fn, owner
+}
+
+// freeze replaces the function of the variable name with the last
+// values of owner, unless another Slicer took it over
+func freeze(name string, owner any, vars map[string]any) {
+	published.mu.Lock()
+	defer published.mu.Unlock()
+	if published.owner[name] == owner {
+		published.funcs[name] = func() any { return vars }
+	}
+}
+
+// vars returns the counters published WithExpvar
+func (c *config[T]) vars() any {
+	return c.counters(c.Stats())
+}
+
+func (c *config[T]) counters(st Stats) map[string]any {
+	return map[string]any{
+		"Len":            st.Len,
+		"MemLen":         st.MemLen,
+		"DiskLen":        st.DiskLen,
+		"Appended":       c.hooks.counts.appended.Load(),
+		"Deleted":        c.hooks.counts.deleted.Load(),
+		"Spilled":        c.hooks.counts.spilled.Load(),
+		"DiskBytes":      st.DiskBytes,
+		"CleanerBacklog": c.Pending(),
+		"Errors":         c.Errors(),
+	}
+}
@@ -0,0 +1,125 @@
+package slice_on_disk
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	small, _ := New(make([]int, 0, 10), os.TempDir(), WithName("small"))
+	defer small.Cleanup()
+	big, _ := New(make([]string, 0, 1), os.TempDir(), WithName("big"))
+	defer big.Cleanup()
+	anonymous := intSlicer()
+	defer anonymous.Cleanup()
+	for i := 0; i < 20; i++ {
+		small.Append(i)
+		big.Append("element")
+	}
+
+	infos := ListSlicers()
+	if len(infos) != 2 || infos[0].Name != "big" || infos[1].Name != "small" {
+		t.Fatalf("ListSlicers() = %+v, want big and small", infos)
+	}
+	if infos[0].Dir != big.Dir() || infos[0].Stats.DiskLen != 19 || infos[1].Stats.MemLen != 10 {
+		t.Errorf("ListSlicers() = %+v", infos)
+	}
+
+	rec := httptest.NewRecorder()
+	RegistryHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/slicers", nil))
+	var got []SlicerInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 2 || got[1].Dir != small.Dir() {
+		t.Errorf("RegistryHandler: %s, %v", rec.Body.String(), err)
+	}
+
+	big.Cleanup()
+	if infos := ListSlicers(); len(infos) != 1 || infos[0].Name != "small" {
+		t.Errorf("ListSlicers() = %+v after Cleanup, want small", infos)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	vars := func() map[string]int64 {
+		var m map[string]int64
+		json.Unmarshal([]byte(expvar.Get("slicer_test").String()), &m)
+		return m
+	}
+	s, _ := New(make([]int, 0, 10), os.TempDir(), WithExpvar("slicer_test"))
+	for i := 0; i < 30; i++ {
+		s.Append(i)
+	}
+	s.Delete(0, 5)
+	m := vars()
+	if m["Len"] != 25 || m["Appended"] != 30 || m["Deleted"] != 5 || m["Spilled"] != 20 || m["DiskBytes"] == 0 {
+		t.Errorf("vars = %v", m)
+	}
+	if _, ok := m["CleanerBacklog"]; !ok {
+		t.Errorf("vars = %v, want CleanerBacklog", m)
+	}
+	os.Remove(s.(*config[int]).Path(s.(*config[int]).diskSlice[0]))
+	if _, err := s.Get(10); err == nil {
+		t.Fatal("Get() of a lost file succeeded")
+	}
+	if m := vars(); m["Errors"] != 1 {
+		t.Errorf("Errors = %d, want 1", m["Errors"])
+	}
+
+	// the last values stay after Cleanup, a new Slicer takes the name over
+	s.Cleanup()
+	if m := vars(); m["Len"] != 25 {
+		t.Errorf("vars = %v after Cleanup", m)
+	}
+	o, _ := New(make([]int, 0, 10), os.TempDir(), WithExpvar("slicer_test"))
+	defer o.Cleanup()
+	if m := vars(); m["Len"] != 0 || m["Appended"] != 0 {
+		t.Errorf("vars = %v of a new Slicer", m)
+	}
+}
+
+func TestExpvarDerived(t *testing.T) {
+	s, _ := New(make([]int, 0, 10), os.TempDir(), WithExpvar("slicer_derived"), WithName("derived"))
+	defer s.Cleanup()
+	for i := 0; i < 30; i++ {
+		s.Append(i)
+	}
+	even := func(v int) bool { return v%2 == 0 }
+	var derived []Slicer[int]
+	add := func(d ...Slicer[int]) func(error) {
+		return func(err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			derived = append(derived, d...)
+		}
+	}
+	f, err := s.Filter(even)
+	add(f)(err)
+	yes, no, err := s.Partition(even)
+	add(yes, no)(err)
+	cl, err := s.Clone()
+	add(cl)(err)
+	a, b, err := s.Split(15)
+	add(a, b)(err)
+	m, err := MapTo(s, func(v int) int { return v * 2 })
+	add(m)(err)
+	g, err := GroupBy(s, even)
+	add(g[true], g[false])(err)
+	mg, err := Merge(func(a, b int) int { return a - b }, s, cl)
+	add(mg)(err)
+
+	if infos := ListSlicers(); len(infos) != 1 {
+		t.Errorf("ListSlicers() = %+v, want the Slicer derived from", infos)
+	}
+	// the derived Slicers don't take the variable over
+	for _, d := range derived {
+		d.Cleanup()
+	}
+	var vars map[string]int64
+	json.Unmarshal([]byte(expvar.Get("slicer_derived").String()), &vars)
+	if vars["Len"] != 30 || vars["Appended"] != 30 {
+		t.Errorf("vars = %v after the Cleanup of the derived Slicers", vars)
+	}
+}
@@ -0,0 +1,201 @@
+package slice_on_disk
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// WithSchema stamps the elements written from now on with the schema
+// version of T, recorded in the manifest of WithManifest. When Open
+// adopts elements of an older version, e.g. before a field of T was
+// added or renamed, they are decoded by migrate from their stored
+// payload, see RawElement, and the version they were written with.
+// A Put rewrites an element with the current version. Without a
+// migration, Open fails with ErrManifest on the elements of another
+// version; the version is 0 without WithSchema
+func WithSchema[T any](version int, migrate func(old []byte, version int) (T, error)) Option {
+	return func(o *options) {
+		o.schema = version
+		if migrate != nil {
+			o.migrate = migrate
+		}
+	}
+}
+
+// ErrUnencodable is returned by New and Open for an element type that
+// can't be stored, e.g. a chan or a struct with unexported fields only
+var ErrUnencodable = storage.ErrUnencodable
+
+// WithSample makes New and Open probe the encoding of the elements with t
+// rather than with the zero value of T, e.g. when the state that gob
+// would drop is not in the zero value. The sample must be stored and
+// decoded back equal, see reflect.DeepEqual
+func WithSample[T any](t T) Option {
+	return func(o *options) {
+		o.sample = t
+	}
+}
+
+// probed caches the probes of the zero values by type
+var probed sync.Map
+
+// probe fails fast if the elements can't be stored, rather than on the
+// first spill: it stores and decodes the sample of WithSample, or the
+// zero value of T. An interface type is left alone, the elements are
+// stored with their dynamic types, see RegisterConcrete
+func probe[T any](o options) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if o.sample != nil {
+		t, ok := o.sample.(T)
+		if !ok {
+			return fmt.Errorf("WithSample of a %T for %v elements", o.sample, typ)
+		}
+		return storage.Probe(t)
+	}
+	if typ.Kind() == reflect.Interface {
+		return nil
+	}
+	if err, ok := probed.Load(typ); ok {
+		err, _ := err.(error)
+		return err
+	}
+	var zero T
+	err := storage.Probe(zero)
+	probed.Store(typ, err)
+	return err
+}
+
+// concrete holds the gob names of the types of RegisterConcrete by the
+// element type they were registered for
+var concrete struct {
+	sync.Mutex
+	names map[reflect.Type][]string
+}
+
+// RegisterConcrete registers the dynamic types of values with gob for
+// the elements of the interface type T, usually in an init function,
+// so that a Slicer[T] can store and read them back. The manifest of
+// WithManifest records the registrations and Open fails with
+// ErrManifest until the process registered them too. Like gob.Register,
+// it panics on a value that is not a T, or whose name is registered for
+// another type
+func RegisterConcrete[T any](values ...any) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	concrete.Lock()
+	defer concrete.Unlock()
+	if concrete.names == nil {
+		concrete.names = make(map[reflect.Type][]string)
+	}
+	for _, v := range values {
+		if _, ok := v.(T); !ok {
+			panic(fmt.Sprintf("RegisterConcrete: %T is not a %v", v, typ))
+		}
+		name := gobName(v)
+		gob.RegisterName(name, v)
+		if !slices.Contains(concrete.names[typ], name) {
+			concrete.names[typ] = append(concrete.names[typ], name)
+		}
+	}
+}
+
+// gobName is the name gob.Register gives to the type of v
+func gobName(v any) string {
+	t := reflect.TypeOf(v)
+	if t.Name() == "" || t.PkgPath() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// registered returns the names RegisterConcrete registered for T
+func registered[T any]() []string {
+	concrete.Lock()
+	defer concrete.Unlock()
+	return slices.Clone(concrete.names[reflect.TypeOf((*T)(nil)).Elem()])
+}
+
+// unregistered fails on the names of the manifest RegisterConcrete has
+// not registered for T in this process, their elements can't be decoded
+func unregistered[T any](names []string) error {
+	var missing []string
+	have := registered[T]()
+	for _, name := range names {
+		if !slices.Contains(have, name) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s not registered for %v elements, see RegisterConcrete", ErrManifest, strings.Join(missing, ", "), reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return nil
+}
+
+// migration returns the migration of WithSchema, nil if there is none
+func migration[T any](o options) (func([]byte, int) (T, error), error) {
+	if o.migrate == nil {
+		return nil, nil
+	}
+	f, ok := o.migrate.(func([]byte, int) (T, error))
+	if !ok {
+		return nil, fmt.Errorf("WithSchema migrates to %T, not %v", o.migrate, reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return f, nil
+}
+
+// adopt checks that the elements of m can be read as ones of codec with
+// the schema of o and returns the versions of the ones written with
+// an older schema by id, which need the migration of o. The codec of
+// those may have changed too
+func (m manifest) adopt(codec string, o options) (map[int]int, error) {
+	if o.migrate != nil {
+		codec = ""
+	}
+	if err := m.check(codec); err != nil {
+		return nil, err
+	}
+	schema, migrates := o.schema, o.migrate != nil
+	var legacy map[int]int
+	for _, id := range append(m.Head, m.DiskSlice...) {
+		v, ok := m.Legacy[id]
+		if !ok {
+			v = m.Schema
+		}
+		if v == schema {
+			continue
+		}
+		if !migrates || v > schema {
+			return nil, fmt.Errorf("%w: elements of schema version %d, WithSchema is %d without a migration from it", ErrManifest, v, schema)
+		}
+		if legacy == nil {
+			legacy = make(map[int]int)
+		}
+		legacy[id] = v
+	}
+	return legacy, nil
+}
+
+// decode reads the disk element id, through the migration of WithSchema
+// if it has an older version
+func (c *config[T]) decode(ctx context.Context, id int) (T, error) {
+	v, old := c.legacy[id]
+	if !old {
+		return storage.ReadContext[T](ctx, c.Storage, id)
+	}
+	b, _, err := storage.Payload(c.Storage, id)
+	if err != nil {
+		var t T
+		return t, err
+	}
+	t, err := c.migrate(b, v)
+	if err != nil {
+		return t, fmt.Errorf("could not migrate element %d from schema version %d: %w", id, v, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,177 @@
+package slice_on_disk
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type personV1 struct{ Name string }
+
+type personV2 struct{ First, Last string }
+
+func migratePerson(old []byte, version int) (personV2, error) {
+	var p personV1
+	if err := gob.NewDecoder(bytes.NewReader(old)).Decode(&p); err != nil {
+		return personV2{}, err
+	}
+	first, last, _ := strings.Cut(p.Name, " ")
+	return personV2{first, last}, nil
+}
+
+func TestSchema(t *testing.T) {
+	s, err := New(make([]personV1, 0, 2), os.TempDir(), WithManifest(), WithSchema[personV1](1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Ada Lovelace", "Alan Turing", "Grace Hopper", "Edsger Dijkstra", "Barbara Liskov"} {
+		s.Append(personV1{name})
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields of the type changed since
+	if _, err := Open(make([]personV2, 0, 2), s.Dir(), WithStealLock()); !errors.Is(err, ErrManifest) {
+		t.Fatalf("Open() without a migration = %v, want ErrManifest", err)
+	}
+	o, err := Open(make([]personV2, 0, 2), s.Dir(), WithManifest(), WithStealLock(), WithSchema(2, migratePerson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := o.Get(4); x != (personV2{"Barbara", "Liskov"}) {
+		t.Errorf("Get(4) = %+v, want Barbara Liskov", x)
+	}
+	o.Put(3, personV2{"E. W.", "Dijkstra"})
+	o.Append(personV2{"Donald", "Knuth"})
+	if err := o.Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := o.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new elements have the current version, the others still migrate
+	r, err := Open(make([]personV2, 0, 2), s.Dir(), WithManifest(), WithStealLock(), WithSchema(2, migratePerson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Cleanup()
+	x, err := r.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []personV2{{"Ada", "Lovelace"}, {"Alan", "Turing"}, {"Grace", "Hopper"}, {"E. W.", "Dijkstra"}, {"Barbara", "Liskov"}, {"Donald", "Knuth"}}
+	if len(x) != len(want) {
+		t.Fatalf("Slice() = %+v, want %+v", x, want)
+	}
+	for i := range want {
+		if x[i] != want[i] {
+			t.Errorf("element %d: %+v, want %+v", i, x[i], want[i])
+		}
+	}
+	if c := r.(*config[personV2]); len(c.legacy) != 2 {
+		t.Errorf("%d elements to migrate, want 2", len(c.legacy))
+	}
+
+	// the copy holds the current version only
+	var buf bytes.Buffer
+	if _, err := r.CopyTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := NewFromReader(make([]personV2, 0, 2), os.TempDir(), &buf, FormatRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Cleanup()
+	if x, err := cp.Slice(); err != nil || !slices.Equal(x, want) {
+		t.Errorf("the copy holds %+v, %v, want %+v", x, err, want)
+	}
+}
+
+type hidden struct {
+	X int
+	y int
+}
+
+func TestProbe(t *testing.T) {
+	fail := map[string]error{}
+	_, fail["chan"] = New(make([]chan int, 0, 1), os.TempDir())
+	_, fail["func field"] = New(make([]struct{ F func() }, 0, 1), os.TempDir())
+	_, fail["unexported"] = New(make([]struct{ a int }, 0, 1), os.TempDir())
+	_, fail["sample"] = New(make([]hidden, 0, 1), os.TempDir(), WithSample(hidden{1, 2}))
+	for name, err := range fail {
+		if !errors.Is(err, ErrUnencodable) {
+			t.Errorf("%s: New() = %v, want ErrUnencodable", name, err)
+		}
+	}
+	if _, err := New(make([]hidden, 0, 1), os.TempDir(), WithSample(1)); err == nil {
+		t.Error("New() with a sample of another type succeeded")
+	}
+
+	for _, s := range []interface{ Cleanup() }{
+		must(New(make([]hidden, 0, 1), os.TempDir())),
+		must(New(make([]hidden, 0, 1), os.TempDir(), WithSample(hidden{X: 1}))),
+		must(New(make([]any, 0, 1), os.TempDir())),
+		must(New(make([][]byte, 0, 1), os.TempDir())),
+	} {
+		s.Cleanup()
+	}
+}
+
+func must[T any](s Slicer[T], err error) Slicer[T] {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type shape interface{ Area() float64 }
+
+type solid interface{ Area() float64 }
+
+type square struct{ Side float64 }
+
+func (s square) Area() float64 { return s.Side * s.Side }
+
+type rect struct{ W, H float64 }
+
+func (r *rect) Area() float64 { return r.W * r.H }
+
+func TestRegisterConcrete(t *testing.T) {
+	RegisterConcrete[shape](square{}, &rect{})
+	s, err := New(make([]shape, 0, 1), os.TempDir(), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(square{2}, &rect{2, 3}, square{3})
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Open(make([]shape, 0, 1), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+	for i, want := range []float64{4, 6, 9} {
+		if x, err := o.Get(i); err != nil || x.Area() != want {
+			t.Errorf("Get(%d) = %v, %v, want an area of %v", i, x, err, want)
+		}
+	}
+	// the same elements, their types were not registered for solid
+	if _, err := Open(make([]solid, 0, 1), s.Dir(), WithStealLock()); !errors.Is(err, ErrManifest) {
+		t.Errorf("Open() of unregistered types = %v, want ErrManifest", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterConcrete() of a value of another type didn't panic")
+		}
+	}()
+	RegisterConcrete[shape](rect{})
+}
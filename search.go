@@ -0,0 +1,115 @@
+package slice_on_disk
+
+import "errors"
+
+// errFound stops a scan at the first match
+var errFound = errors.New("found")
+
+func (c *config[T]) IndexFunc(pred func(v T) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return -1, err
+	}
+	i := 0
+	err := c.each(func(t T) error {
+		if pred(t) {
+			return errFound
+		}
+		i++
+		return nil
+	})
+	if err == errFound {
+		return i, nil
+	}
+	return -1, err
+}
+
+// Index returns the index of the first element of s equal to v,
+// or -1 if there is none. Similar to slices.Index, the memory head
+// is searched first, then the disk tail is read up to the match
+func Index[T comparable](s Slicer[T], v T) (int, error) {
+	return s.IndexFunc(func(x T) bool {
+		return x == v
+	})
+}
+
+// Contains tells if s has an element equal to v, see Index
+func Contains[T comparable](s Slicer[T], v T) (bool, error) {
+	i, err := Index(s, v)
+	return i >= 0, err
+}
+
+// CountFunc returns the number of elements of s pred is true for
+func CountFunc[T any](s Slicer[T], pred func(v T) bool) (int, error) {
+	n := 0
+	err := s.ForEach(func(_ int, v T) error {
+		if pred(v) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// AnyFunc tells if pred is true for some element of s.
+// It stops at the first one
+func AnyFunc[T any](s Slicer[T], pred func(v T) bool) (bool, error) {
+	i, err := s.IndexFunc(pred)
+	return i >= 0, err
+}
+
+// AllFunc tells if pred is true for every element of s, which holds
+// for an empty s. It stops at the first element pred is false for
+func AllFunc[T any](s Slicer[T], pred func(v T) bool) (bool, error) {
+	i, err := s.IndexFunc(func(v T) bool {
+		return !pred(v)
+	})
+	return i < 0, err
+}
+
+func (c *config[T]) CompactFunc(eq func(a, b T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+
+	// the runs of duplicates as [start, end)
+	var spans [][2]int
+	var prev T
+	i := 0
+	err := c.each(func(t T) error {
+		if i > 0 && eq(prev, t) {
+			if n := len(spans); n > 0 && spans[n-1][1] == i {
+				spans[n-1][1]++
+			} else {
+				spans = append(spans, [2]int{i, i + 1})
+			}
+		} else {
+			prev = t
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.deleteSpans(spans)
+}
+
+// Dedup removes the consecutive runs of equal elements of s but the first
+// of each, like slices.Compact, see CompactFunc
+func Dedup[T comparable](s Slicer[T]) error {
+	return s.CompactFunc(func(a, b T) bool {
+		return a == b
+	})
+}
@@ -0,0 +1,94 @@
+package slice_on_disk
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	for v, want := range map[int]int{3: 3, 42: 42, 99: 99, 100: -1} {
+		if i, err := Index(s, v); err != nil || i != want {
+			t.Errorf("Index(%d) = %d, %v, want %d", v, i, err, want)
+		}
+		if ok, err := Contains(s, v); err != nil || ok != (want >= 0) {
+			t.Errorf("Contains(%d) = %v, %v", v, ok, err)
+		}
+	}
+
+	// stops at the match
+	n := 0
+	i, err := s.IndexFunc(func(v int) bool {
+		n++
+		return v > 0 && v%25 == 0
+	})
+	if err != nil || i != 25 || n != 26 {
+		t.Errorf("IndexFunc() = %d, %v after %d elements, want 25 after 26", i, err, n)
+	}
+}
+
+func TestCountAnyAll(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if n, err := CountFunc(s, func(v int) bool { return v%10 == 0 }); err != nil || n != 10 {
+		t.Errorf("CountFunc() = %d, %v, want 10", n, err)
+	}
+	if ok, err := AnyFunc(s, func(v int) bool { return v > 98 }); err != nil || !ok {
+		t.Errorf("AnyFunc() = %v, %v, want true", ok, err)
+	}
+	if ok, err := AnyFunc(s, func(v int) bool { return v < 0 }); err != nil || ok {
+		t.Errorf("AnyFunc() = %v, %v, want false", ok, err)
+	}
+	if ok, err := AllFunc(s, func(v int) bool { return v < 100 }); err != nil || !ok {
+		t.Errorf("AllFunc() = %v, %v, want true", ok, err)
+	}
+	if ok, err := AllFunc(s, func(v int) bool { return v != 50 }); err != nil || ok {
+		t.Errorf("AllFunc() = %v, %v, want false", ok, err)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	// runs across the memory/disk boundary
+	var want []int
+	for i := 0; i < 20; i++ {
+		for k := 0; k <= i%4; k++ {
+			s.Append(i)
+		}
+		want = append(want, i)
+	}
+	if err := Dedup(s); err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(x, want) {
+		t.Errorf("Dedup() = %v, want %v", x, want)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	s.(*config[int]).Wait()
+	// the 15 disk elements and the lock
+	if entries, _ := os.ReadDir(s.Dir()); len(entries) != 16 {
+		t.Errorf("%d files, want 16", len(entries))
+	}
+
+	// by the tens
+	if err := s.CompactFunc(func(a, b int) bool { return a/10 == b/10 }); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := s.Slice(); !slices.Equal(x, []int{0, 10}) {
+		t.Errorf("CompactFunc() = %v, want [0 10]", x)
+	}
+}
@@ -1,49 +1,416 @@
 package slice_on_disk
 
 import (
-	"encoding/gob"
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"iter"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
 )
 
-const GetError = "could not retrive element: %s"
-const CLEANUP = -999999
+const GetError = storage.GetError
+const CLEANUP = storage.CLEANUP
 
 var IndexOutOfBounds = errors.New("index out of bounds")
 
+// ErrAppendOnly is returned by the calls rewriting the elements
+// of a Slicer created WithAppendOnly
+var ErrAppendOnly = errors.New("slicer is append only")
+
+// ErrLocked is returned by Open when another process owns the directory,
+// see WithStealLock, and by Compact while another process is attached
+// with OpenReadOnly
+var ErrLocked = storage.ErrLocked
+
+// ErrCorrupted is returned when a disk element fails its checksum
+// or length verification: bit rot or a partial write
+var ErrCorrupted = storage.ErrCorrupted
+
+// ErrTimeout is returned when a disk operation takes longer than
+// WithOpTimeout or the deadline of the context of GetContext and
+// AppendContext, wrapping the error of the context then
+var ErrTimeout = storage.ErrTimeout
+
+// AppendError is returned by Append and AppendContext when an element
+// can't be stored: the elements before it are appended, the ones from it
+// on are not. With ErrDiskFull the call appends none instead
+type AppendError struct {
+	// the position of the element in the arguments of the call
+	Index int
+	Err   error
+}
+
+func (e *AppendError) Error() string {
+	return fmt.Sprintf("could not append element %d: %v", e.Index, e.Err)
+}
+
+func (e *AppendError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNoIDs is returned when the ids of the disk files run out,
+// only possible on 32 bit platforms: Compact renumbers them
+var ErrNoIDs = errors.New("out of disk file ids, Compact renumbers them")
+
 // Slicer is an interface to work with an object similar to a slice
-// whose head is in memory and potentially long tail is on the disk
+// whose head is in memory and potentially long tail is on the disk.
+// It is safe for concurrent use.
 type Slicer[T any] interface {
-	// Appends: appends the elements to the Slicer as to a regular slice
+	// Appends: appends the elements to the Slicer as to a regular slice.
+	// If one can't be stored, the call fails with an *AppendError telling
+	// which one, the elements before it are appended
 	Append(element ...T) error
+	// AppendContext: Append, failing with an error wrapping ErrTimeout
+	// and ctx.Err() if a disk write outlives ctx
+	AppendContext(ctx context.Context, element ...T) error
+	// AppendFromChan: appends the elements received from ch until it is
+	// closed or the ctx is done, then returns ctx.Err()
+	AppendFromChan(ctx context.Context, ch <-chan T) error
+	// AppendSlicer: appends the elements of other, which is not changed.
+	// The disk files of other are shared via hard links when possible
+	// instead of being read and encoded again
+	AppendSlicer(other Slicer[T]) error
 	// Len: returns the number of elements
 	Len() int
+	// Len64, Get64, Put64, Slice64 and Delete64: Len, Get, Put, Slice and
+	// Delete with int64 indices, for the code counting the elements of the
+	// very large Slicers in int64 whatever the platform. The indices beyond
+	// the range of an int are out of bounds
+	Len64() int64
+	Get64(index int64) (T, error)
+	Put64(index int64, element T) error
+	Slice64(ind ...int64) ([]T, error)
+	Delete64(start, count int64) error
+	// Cap: returns the capacity of the memory head, see SetMemoryCapacity
+	Cap() int
+	// MemLen: returns the number of elements in the memory head
+	MemLen() int
+	// DiskLen: returns the number of elements in the disk tail
+	DiskLen() int
+	// Stats: returns the lengths, the disk usage, per root path
+	// with WithRootPaths, and the backlog of the cleaner
+	Stats() Stats
+	// Placement: tells where the element at the index lives
+	Placement(index int) (Placement, error)
+	// IsOnDisk: tells if the element at the index is in the disk tail
+	IsOnDisk(index int) (bool, error)
 	// Get: retrieves an element at the index. Similar to slice[i]
 	Get(index int) (T, error)
+	// GetContext: Get, failing with an error wrapping ErrTimeout
+	// and ctx.Err() if the disk read outlives ctx
+	GetContext(ctx context.Context, index int) (T, error)
+	// GetVersion: retrieves the k-th previous version of the element at
+	// the index kept WithVersions, or the element itself if k is 0.
+	// Returns ErrNoVersion if fewer are kept
+	GetVersion(index, k int) (T, error)
+	// GetReader: streams the element at the index, which must be a []byte,
+	// without loading it in memory. Returns ErrNotRaw for other types
+	GetReader(index int) (io.ReadCloser, error)
+	// GetBatch: retrieves the elements at the indices, in the same order.
+	// Every disk file is read once, in the file order,
+	// concurrently with WithParallelReads
+	GetBatch(indices ...int) ([]T, error)
+	// ToChan: sends the elements in order to the returned channel, which is
+	// closed at the end or when the ctx is done. Without consume, the elements
+	// of a Snapshot as of the call are sent. With consume, every element
+	// is removed as it is sent, waiting for Append when there is none, until
+	// the ctx is done or Cleanup: an unbounded buffer between goroutines.
+	// The function returns the error that stopped the stream, if any,
+	// once the channel is closed
+	ToChan(ctx context.Context, consume bool) (<-chan T, func() error)
+	// BlockingPopFront: removes and returns the first element, waiting
+	// for an Append if there is none. Returns ctx.Err() if the ctx is done
+	// first, and ErrClosed on Cleanup
+	BlockingPopFront(ctx context.Context) (T, error)
+	// Sample: returns n distinct elements picked at random with r, or with
+	// the default source if r is nil, in their order in the Slicer.
+	// At most n disk elements are read
+	Sample(n int, r *rand.Rand) ([]T, error)
 	// Put: stores a value at the index. Similar to slice[index]=element
 	Put(index int, element T) error
-	// Slice: returns a subslice. Maximum 2 parameters: start and end
-	// similar to slice[start:end]. If only one parameter is given,
-	// it is interpreted as start. So, Slice(3) ~ slice[3:]
+	// PutBatch: overwrites the elements starting at start with elements.
+	// The whole region must exist
+	PutBatch(start int, elements []T) error
+	// Fill: stores v at every index in [start, end). v is encoded once
+	// for all the disk elements
+	Fill(v T, start, end int) error
+	// Swap: exchanges the elements at the indices i and j.
+	// If both are on the disk, only the index is updated: no file I/O
+	Swap(i, j int) error
+	// Reverse: reverses the order of the elements. The disk tail is
+	// reversed in the index, only the elements crossing the memory/disk
+	// boundary are read and written
+	Reverse() error
+	// Shuffle: permutes the elements randomly with r, or with the default
+	// source if r is nil. Like with Reverse, the disk tail is permuted in
+	// the index: only the elements crossing the memory/disk boundary
+	// are read and written
+	Shuffle(r *rand.Rand) error
+	// Rotate: rotates the elements left by k positions, or right by -k
+	// if k is negative: the element k becomes the first one. Like with
+	// Reverse, the disk tail is rotated in the index
+	Rotate(k int) error
+	// Slice: returns a subslice. Maximum 3 parameters: start, end and max
+	// similar to slice[start:end:max], max setting the capacity of the
+	// result. If only one parameter is given, it is interpreted as start.
+	// So, Slice(3) ~ slice[3:]. The bounds Go panics on, e.g. start > end,
+	// fail with an error wrapping IndexOutOfBounds, see WithNegativeIndices
 	Slice(ind ...int) ([]T, error)
-	// Delete: deletes the "count" of elements starting with slice[start]
+	// SliceInto: copies the elements from start on into dst like
+	// copy(dst, slice[start:]), reading no more of the disk tail than dst
+	// holds, and returns the number of elements copied. An export loop
+	// can reuse dst rather than allocating like Slice
+	SliceInto(dst []T, start int) (int, error)
+	// ForEach: calls fn for every element in order with its index,
+	// reading the disk tail one element at a time. Stops at the first
+	// error of fn and returns it. fn must not call the Slicer
+	ForEach(fn func(i int, v T) error) error
+	// Backward: iterates over the elements from the last one to the first
+	// with their indices, reading the disk tail one element at a time.
+	// The Slicer is locked for each read only: the loop may call it, and
+	// goes on before the current element like a Cursor. Stops at the
+	// first element that can't be read: the second result returns its
+	// error after the loop, nil at the end
+	Backward() (iter.Seq2[int, T], func() error)
+	// Chunks: iterates over the elements in order in pages of n, the last
+	// one shorter, reading the disk elements of a page together, see
+	// WithParallelReads. Each page is a new slice the loop may keep. Like
+	// Backward, the loop may call the Slicer and the iteration stops at
+	// the first read error, returned by the second result. Panics if
+	// n < 1, like slices.Chunk
+	Chunks(n int) (iter.Seq[[]T], func() error)
+	// Filter: returns a new Slicer holding the elements pred keeps, in order,
+	// with the same settings in a sibling directory. See also MapTo.
+	// Cleanup the result when done
+	Filter(pred func(v T) bool) (Slicer[T], error)
+	// Partition: returns two new Slicers holding the elements pred keeps
+	// and the others, each in order, like Filter in one pass
+	Partition(pred func(v T) bool) (Slicer[T], Slicer[T], error)
+	// IndexFunc: returns the index of the first element pred is true for,
+	// or -1. The disk tail is read only up to the match. See also Index
+	// and Contains for the comparable types
+	IndexFunc(pred func(v T) bool) (int, error)
+	// Delete: deletes the "count" of elements starting with slice[start].
+	// In the middle of the disk tail, the deleted elements are marked with
+	// tombstones: the call costs their number, their files and the ids of
+	// the following ones are handled in the background, or by the next call
+	// if it comes first. Not WithManifest or WithTTL, which track the order
 	Delete(start, count int) error
+	// DeleteRanges: removes several regions at once, each given as {start, count}
+	// like with Delete. The positions refer to the slice before the call,
+	// the regions may overlap
+	DeleteRanges(ranges ...[2]int) error
+	// Batch: returns a Batch staging Append, Put and Delete calls that its
+	// Commit applies all at once or not at all: a failure half way, even
+	// a crash WithManifest, leaves the Slicer as it was before the Commit
+	Batch() Batch[T]
+	// Undo: reverts the last n Append, Put and Delete calls journaled
+	// WithUndo, restoring the deleted disk elements from their kept files.
+	// Returns ErrNoUndo, undoing nothing, if fewer are journaled
+	Undo(n int) error
+	// DeleteSoft: removes the elements like Delete, but keeps them,
+	// with their disk files, until PurgeDeleted or Cleanup. Open
+	// doesn't adopt the kept files
+	DeleteSoft(start, count int) error
+	// RestoreDeleted: inserts the elements removed by DeleteSoft back
+	// where they were, the latest call first, e.g. at the front for
+	// the elements a consumer rejected
+	RestoreDeleted() error
+	// PurgeDeleted: deletes the elements removed by DeleteSoft for good
+	PurgeDeleted()
+	// Sync: waits until the elements written in the background
+	// (see WithAsyncWrites) are on the disk, and with DurabilityBatch
+	// syncs the files written since the previous Sync.
+	// Returns the first background write error since the previous Sync
+	Sync() error
+	// Flush: writes the head to the disk too and records it in the
+	// manifest, so Open restores all the elements, e.g. before a planned
+	// shutdown. Requires WithManifest. The Slicer is then cleaned up
+	// leaving its directory for Open, or with WithServeAfterFlush stays
+	// read only until Cleanup
+	Flush() error
+	// Verify: reads every disk element and checks its checksum.
+	// The damaged ones are reported as errors wrapping ErrCorrupted
+	Verify() error
+	// Validate: checks the invariants of the Slicer: every disk element
+	// decodes, every file of the directory belongs to an element, the
+	// counts add up and the manifest matches. The broken ones are
+	// reported as the Issues of a *ValidationError
+	Validate() error
+	// Dump: writes a table of the first limit elements, all if limit <= 0:
+	// the index, mem or disk, the file and the encoded size of the disk
+	// ones and a %v preview of the value. Meant for debugging
+	Dump(w io.Writer, limit int) error
+	// Dir: returns the directory holding the disk tail.
+	// See WithManifest and Open to adopt it after a restart
+	Dir() string
+	// Snapshot: returns a read only copy of the Slicer as of the call,
+	// not affected by the later changes. The disk files are shared
+	// via hard links when possible. Cleanup the copy when done
+	Snapshot() (Slicer[T], error)
+	// Clone: returns an independent copy of the Slicer with the same
+	// settings in a new directory. The disk files are shared via hard
+	// links when possible, a Put to one of the copies doesn't affect the other
+	Clone() (Slicer[T], error)
+	// Split: returns two independent Slicers holding the elements before
+	// the index and from the index on, like Clone in sibling directories:
+	// the disk files are shared via hard links when possible, nothing is
+	// encoded again. The Slicer itself is unchanged, Cleanup it if the
+	// halves replace it
+	Split(index int) (Slicer[T], Slicer[T], error)
+	// Backup: writes the whole Slicer, head and tail, to w
+	// as a single archive that Restore can read
+	Backup(w io.Writer) error
+	// ExportJSONL: writes the elements to w as JSON, one per line
+	ExportJSONL(w io.Writer) error
+	// CopyTo: writes the elements in order to w as they are stored, each
+	// one framed with its length and checksum, and returns the number of
+	// bytes written. The disk files are copied without being decoded,
+	// except the elements of an older schema version, which are migrated
+	// and encoded again, see WithSchema.
+	// NewFromReader reads the stream back with FormatRecords
+	CopyTo(w io.Writer) (int64, error)
+	// MarshalJSON: encodes the elements as a JSON array, one at a time, so
+	// a Slicer in a struct is marshaled like a slice
+	MarshalJSON() ([]byte, error)
+	// UnmarshalJSON: replaces the elements with the ones of a JSON array,
+	// all at once by a Batch: if one is invalid or a write fails, the
+	// Slicer is left as it is. null changes nothing
+	UnmarshalJSON(data []byte) error
+	// ImportJSONL: appends the JSON values read from r
+	ImportJSONL(r io.Reader) error
+	// ExportCSV: writes the elements to w as CSV records made by row.
+	// The header is written first unless it is nil
+	ExportCSV(w io.Writer, header []string, row func(t T) []string) error
+	// ImportCSV: appends the elements made by parse from the CSV
+	// records read from r. If header is true, the first record is skipped
+	ImportCSV(r io.Reader, header bool, parse func(record []string) (T, error)) error
+	// Truncate: drops the elements from the index n on, removing their
+	// disk files. Similar to slice = slice[:n]
+	Truncate(n int) error
+	// Clear: removes all the elements. Unlike Cleanup, the Slicer
+	// stays usable
+	Clear() error
+	// SetMemoryCapacity: resizes the in memory head to n elements.
+	// Growing pulls the elements back from the disk, shrinking spills
+	// the end of the head to the disk. The slice given to New is no
+	// longer used afterwards
+	SetMemoryCapacity(n int) error
+	// Compact: renumbers the disk files after Delete churn,
+	// removes the leftover files and restarts the file numbering.
+	// On an error the files keep their numbers. WithManifest, Open
+	// finishes a Compact interrupted by a crash
+	Compact() error
+	// CompactFunc: replaces the consecutive runs of elements eq reports equal
+	// with their first element, like slices.CompactFunc, in one pass
+	// over the elements. The files of the removed disk elements are deleted.
+	// Unlike Compact, it is about the elements, not the files. See also Dedup
+	CompactFunc(eq func(a, b T) bool) error
+	// PauseCompaction: stops the background compaction (see
+	// WithAutoCompaction) until ResumeCompaction, e.g. during latency
+	// sensitive reads
+	PauseCompaction()
+	// ResumeCompaction: resumes the background compaction
+	ResumeCompaction()
+	// Cursor: returns a Cursor iterating over the elements from the first
+	// one, which follows its next element across the deletions
+	Cursor() *Cursor[T]
+	// Iterator: returns an Iterator over the elements from the first one,
+	// which can Seek to an index saved from its Position
+	Iterator() *Iterator[T]
+	// Pin: keeps the elements at the indices decoded in memory
+	// even if they are in the disk tail, until they are unpinned or deleted.
+	// The pins follow the elements when they are moved
+	Pin(indices ...int) error
+	// Unpin: releases the elements pinned with Pin
+	Unpin(indices ...int) error
+	// Warmup: decodes the first n elements of the disk tail in the background,
+	// e.g. after Open, so the first reads don't wait for the disk
+	Warmup(n int)
 	// Cleanup: stops the go routine that is tasked with disk cleanup
 	// necessitated by the Delete calls.
 	Cleanup()
+	// Close: Cleanup, then waits until the pending removals are done
+	// and the directory is removed, see WithCleanerWorkers
+	Close()
 	// other methods
 }
 
 type config[T any] struct {
+	*storage.Storage
+	options
+	mu        tailLock
 	slice     []T
 	diskSlice []int
-	rootPath  string
-	diskIndex int
-	ch        chan int
+	diskIndex int64
+	// append times of the elements, tracked only when there is a TTL
+	born []time.Time
+	// born is out of order since a call reordered the elements, see expire
+	shuffled bool
+	// background writer, nil unless WithAsyncWrites
+	async *writer[T]
+	// disk files removed since the last Compact
+	garbage int
+	// decoded copies of the pinned disk elements by id
+	pinned map[int]T
+	// the open cursors, moved by the deletions
+	cursors map[*Cursor[T]]struct{}
+	// decoded copies of the disk elements loaded by Warmup by id,
+	// and the ids still being loaded with the number of their Warmup call
+	warm    map[int]T
+	warming map[int]int
+	warmups int
+	// ids of the copies of the head written by Flush or the last checkpoint
+	flushed []int
+	// the log of WithWAL, opened by the first logged call, the number
+	// of the last record and the records since the checkpoint
+	walFile    *storage.WAL
+	walSeq     int64
+	walRecords int
+	// a logged call is running, its changes need no checkpoint
+	logging bool
+	// a call changed the elements without logging it
+	unlogged bool
+	// the files listed by the checkpoint, and the ones of them
+	// removed since, which are kept until the next checkpoint
+	durable  map[int]bool
+	retained []int
+	// the log of the changes to the manifest, see WithManifest
+	mlog *manifestLog
+	// the tombstones in the disk tail and the ids they replace, see bury
+	tombs  int
+	buried []int
+	// closed by Cleanup to stop the background go routines
+	done chan struct{}
+	// signaled on removals, for the Append calls waiting for disk space
+	freed  *sync.Cond
+	paused bool
+	// closed and replaced by Append to wake up the consuming ToChan
+	notify chan struct{}
+	// the calls Undo can revert, oldest first, see WithUndo,
+	// and the one being made
+	journal []*change[T]
+	keep    *change[T]
+	// the groups of elements hidden by DeleteSoft, oldest first
+	hidden []*change[T]
+	// the files of the previous versions of the disk elements
+	// by the id of the element, the latest first, see WithVersions
+	past map[int][]int
+	// the schema versions of the disk elements written with an older
+	// one by id, and the migration reading them, see WithSchema
+	legacy  map[int]int
+	migrate func([]byte, int) (T, error)
 }
 
 // New created a Slicer object. It accepts 2 parameters:
@@ -52,103 +419,388 @@ type config[T any] struct {
 // rootPath:  the path on the disk where the Slicer tail will live.
 // a randomly named subdir will be created, so multiple Slicers
 // with the same rootPath (e.g. system temp directory) won't collide
-func New[T any](slice []T, rootPath string) (Slicer[T], error) {
-	stat, err := os.Stat(rootPath)
+// opts: optional settings, see the With... functions
+func New[T any](slice []T, rootPath string, opts ...Option) (Slicer[T], error) {
+	s, err := storage.New(rootPath)
 	if err != nil {
 		return nil, err
 	}
-	if !stat.IsDir() {
-		return nil, fmt.Errorf("%s is not a directory", rootPath)
+	o := apply(opts)
+	if _, err := migration[T](o); err != nil {
+		s.Cleanup()
+		return nil, err
 	}
-
-	// verify permissions
-	rnd := rand.Intn(100)
-	testFname := filepath.Join(rootPath, fmt.Sprintf("probe-%d", rnd))
-	if err = os.WriteFile(testFname, []byte("Hello"), 0755); err != nil {
+	if err := probe[T](o); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	if err := setup(s, o); err != nil {
+		s.Cleanup()
 		return nil, err
 	}
-	defer os.Remove(testFname)
 
-	rootPath, err = os.MkdirTemp(rootPath, "diskslice")
+	c := newConfig(s, slice, o)
+	c.register()
+	return c, nil
+}
+
+// setup applies the options about the disk layout to a new storage
+func setup(s *storage.Storage, o options) error {
+	if err := s.SetModes(o.fileMode, o.dirMode); err != nil {
+		return err
+	}
+	s.SetDurability(o.durability)
+	s.SetRetry(o.retries, o.backoff)
+	s.Cleaners(o.cleaners)
+	s.SetLimits(o.writeRate, o.iops)
+	s.SetTimeout(o.opTimeout)
+	if len(o.roots) > 0 {
+		if err := s.Roots(o.roots, o.spread); err != nil {
+			return err
+		}
+	}
+	if err := s.Shard(o.shards); err != nil {
+		return err
+	}
+	s.Pack(o.packing)
+	s.Chunk(o.chunking)
+	if o.appendOnly {
+		s.Log()
+	}
+	if o.replica != "" {
+		if err := mirror(s, o); err != nil {
+			return err
+		}
+	}
+	if o.readOnly {
+		return nil
+	}
+	// with or without a manifest, which ScanOrphans tells by the lock
+	return s.Own(o.stealLock)
+}
+
+// mirror sets a plain storage named after the directory of s
+// in the replica path as its replica, see WithReplica
+func mirror(s *storage.Storage, o options) error {
+	stat, err := os.Stat(o.replica)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", o.replica)
+	}
+	// left behind by a previous Open of the directory
+	dir := filepath.Join(o.replica, filepath.Base(s.RootPath))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return err
 	}
+	m, err := storage.Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	if err := m.SetModes(o.fileMode, o.dirMode); err != nil {
+		m.Cleanup()
+		return err
+	}
+	m.SetDurability(o.durability)
+	m.SetRetry(o.retries, o.backoff)
+	m.Cleaners(o.cleaners)
+	m.SetTimeout(o.opTimeout)
+	s.Mirror(m)
+	return nil
+}
 
+func newConfig[T any](s *storage.Storage, slice []T, o options) *config[T] {
 	c := &config[T]{
+		Storage:   s,
+		options:   o,
 		slice:     slice,
 		diskSlice: make([]int, 0, 4096),
-		rootPath:  rootPath,
-		diskIndex: cap(slice),
-		ch:        make(chan int, 1024),
-	}
-
-	// cleaner
-	go func() {
-		select {
-		case val := <-c.ch:
-			if val == CLEANUP {
-				os.RemoveAll(rootPath)
-				return
-			}
-			fpath := filepath.Join(c.rootPath, fmt.Sprintf("%d", val))
-			err := os.Remove(fpath)
-			if err != nil {
-				log.Printf("error removing file %s: %s", fpath, err.Error())
-			}
-		default:
+		diskIndex: int64(cap(slice)),
+		done:      make(chan struct{}),
+	}
+	c.mu.sweep = c.sweep
+	c.freed = sync.NewCond(&c.mu)
+	s.ReuseIDs()
+	if c.quota != nil {
+		c.quota.join(s, c.quotaWeight)
+	}
+	if c.asyncWorkers > 0 {
+		c.async = newWriter[T](s, c.asyncWorkers)
+		c.async.tracer = c.tracer
+	}
+	if c.tracer != nil {
+		traceRemovals(c.tracer, s)
+	}
+	if c.autoCompaction.Interval > 0 {
+		go c.compactor()
+	}
+	if c.ttl > 0 {
+		c.born = make([]time.Time, len(slice), cap(slice))
+		for i := range c.born {
+			c.born[i] = time.Now()
 		}
-	}()
+	}
+	// the first logged call writes the first checkpoint
+	c.unlogged = c.wal
+	c.migrate, _ = migration[T](o)
+	return c
+}
 
-	return c, nil
+// newID returns the id of a new disk file, reusing the deleted ones.
+// The ids are ints, so on 32 bit platforms they may run out after
+// 2^31 writes: Compact renumbers them
+func (c *config[T]) newID() (int, error) {
+	if id, ok := c.FreeID(); ok {
+		return id, nil
+	}
+	if c.diskIndex >= math.MaxInt {
+		return 0, ErrNoIDs
+	}
+	c.diskIndex++
+	return int(c.diskIndex - 1), nil
 }
 
 func (c *config[T]) write(id int, t T) error {
-	fname := fmt.Sprintf("%d", id)
-	f, err := os.Create(filepath.Join(c.rootPath, fname))
+	return c.writeOp(id, t, func() error {
+		return storage.Write(c.Storage, id, t)
+	})
+}
+
+// writeNew writes the new disk element id until ctx is done, see Spill
+func (c *config[T]) writeNew(ctx context.Context, id int, t T) error {
+	return c.writeOp(id, t, func() error {
+		return storage.Spill(ctx, c.Storage, id, t)
+	})
+}
+
+// writeOp writes t as the disk element id with op,
+// or in the background with WithAsyncWrites
+func (c *config[T]) writeOp(id int, t T, op func() error) error {
+	c.refresh(id, t)
+	delete(c.legacy, id)
+	if c.mlog != nil {
+		c.mlog.written = append(c.mlog.written, id)
+	}
+	if c.async != nil {
+		c.async.write(id, t)
+		return nil
+	}
+	if c.tracer != nil {
+		return trace(c.tracer, c.Storage, "write", -1, id, op)
+	}
+	return op()
+}
+
+// overwrite writes t to the file id, or to a new file if id belongs to
+// the checkpoint of WithWAL, which must stay as it is until the next one.
+// Returns the id of the file holding t
+func (c *config[T]) overwrite(id int, t T) (int, error) {
+	if !c.durable[id] {
+		return id, c.write(id, t)
+	}
+	n, err := c.newID()
 	if err != nil {
-		return err
+		return id, err
 	}
-	defer f.Close()
-	e := gob.NewEncoder(f)
-	err = e.Encode(t)
-	return err
+	if err := c.write(n, t); err != nil {
+		c.Release(n)
+		return id, err
+	}
+	if _, ok := c.pinned[id]; ok {
+		c.pinned[n] = t
+	}
+	c.remove(id)
+	return n, nil
 }
 
-func (c *config[T]) read(fname string) (T, error) {
-	var retVal T
+// refresh updates the copies of the disk element id kept in memory
+func (c *config[T]) refresh(id int, t T) {
+	if _, ok := c.pinned[id]; ok {
+		c.pinned[id] = t
+	}
+	if _, ok := c.warm[id]; ok {
+		c.warm[id] = t
+	}
+	delete(c.warming, id)
+}
 
-	f, err := os.Open(filepath.Join(c.rootPath, fname))
-	if err != nil {
-		return retVal, fmt.Errorf(GetError, err.Error())
+func (c *config[T]) read(id int) (T, error) {
+	return c.readAt(-1, id)
+}
+
+// readAt reads the disk element id at index, -1 if unknown
+func (c *config[T]) readAt(index, id int) (T, error) {
+	return c.readContext(context.Background(), index, id)
+}
+
+// readContext is readAt until ctx is done
+func (c *config[T]) readContext(ctx context.Context, index, id int) (t T, err error) {
+	if t, ok := c.cached(id); ok {
+		return t, nil
 	}
+	if c.tracer != nil {
+		err = trace(c.tracer, c.Storage, "read", index, id, func() error {
+			t, err = c.decode(ctx, id)
+			return err
+		})
+		return t, err
+	}
+	return c.decode(ctx, id)
+}
 
-	defer f.Close()
-	decoder := gob.NewDecoder(f)
-	err = decoder.Decode(&retVal)
-	if err != nil {
-		return retVal, fmt.Errorf(GetError, err.Error())
+// cached returns the disk element id if it is in memory:
+// pinned, warmed up or not written yet
+func (c *config[T]) cached(id int) (T, bool) {
+	if t, ok := c.pinned[id]; ok {
+		return t, true
 	}
-	return retVal, nil
+	if t, ok := c.warm[id]; ok {
+		return t, true
+	}
+	if c.async != nil {
+		return c.async.read(id)
+	}
+	var zero T
+	return zero, false
+}
+
+// remove marks the files of the deleted elements for removal.
+// They are handed to the cleaner as one batch
+func (c *config[T]) remove(ids ...int) {
+	if len(c.past) > 0 {
+		// the previous versions go with the element
+		var past []int
+		for _, id := range ids {
+			past = append(past, c.past[id]...)
+			delete(c.past, id)
+		}
+		if len(past) > 0 {
+			c.remove(past...)
+		}
+	}
+	c.garbage += len(ids)
+	if len(ids) > 0 {
+		c.freed.Broadcast()
+		if c.quota != nil {
+			c.quota.release()
+		}
+	}
+	for _, id := range ids {
+		delete(c.pinned, id)
+		delete(c.warm, id)
+		delete(c.warming, id)
+		delete(c.legacy, id)
+	}
+	if c.durable != nil {
+		ids = c.retain(ids)
+	}
+	if c.async != nil {
+		for _, id := range ids {
+			c.async.remove(id)
+		}
+		return
+	}
+	c.Remove(ids...)
 }
 
 func (c *config[T]) Append(elements ...T) error {
+	return c.AppendContext(context.Background(), elements...)
+}
+
+func (c *config[T]) AppendContext(ctx context.Context, elements ...T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	return c.logged([]op[T]{{kind: opAppend, values: elements}}, func() error {
+		n := c.len()
+		c.track(opAppend, 0)
+		if i, err := c.append(ctx, elements); err != nil {
+			c.forget()
+			if i < 0 {
+				return err
+			}
+			if i > 0 {
+				// the elements before it stay
+				c.wake()
+				if perr := c.persist(); perr != nil {
+					return perr
+				}
+			}
+			return &AppendError{Index: i, Err: err}
+		}
+		if c.keep != nil {
+			c.record(c.len() - n + len(c.keep.removed))
+		}
+		c.wake()
+		return c.persist()
+	})
+}
+
+// append appends the elements; on an error it returns the position of
+// the element that failed, the ones before it are appended, or -1 when
+// none is, e.g. after ErrDiskFull
+func (c *config[T]) append(ctx context.Context, elements []T) (int, error) {
+	trimmed := 0
+	if c.maxLen > 0 {
+		// the elements that would be evicted right away are never stored
+		if len(elements) > c.maxLen {
+			trimmed = len(elements) - c.maxLen
+			elements = elements[trimmed:]
+		}
+		if over := c.len() + len(elements) - c.maxLen; over > 0 {
+			if err := c.del(0, over); err != nil {
+				return -1, err
+			}
+		}
+	}
+
+	now := time.Now()
+	appended, spilled := 0, 0
+	defer func() {
+		c.hooks.spilled(spilled)
+		c.hooks.appended(appended)
+	}()
 	for _, e := range elements {
 		if len(c.slice) < cap(c.slice) {
 			c.slice = append(c.slice, e)
-			return nil
+		} else if disk, err := c.spill(ctx, e); err != nil {
+			if errors.Is(err, ErrDiskFull) {
+				c.unappend(appended)
+				appended, spilled = 0, 0
+				return -1, err
+			}
+			return trimmed + appended, err
+		} else if disk {
+			spilled++
 		}
-
-		if err := c.write(c.diskIndex, e); err != nil {
-			return err
+		if c.ttl > 0 {
+			c.born = append(c.born, now)
 		}
-
-		c.diskSlice = append(c.diskSlice, c.diskIndex)
-		c.diskIndex++
+		appended++
 	}
-	return nil
+	return 0, nil
 }
 
 func (c *config[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	return c.len()
+}
+
+func (c *config[T]) len() int {
 	if len(c.diskSlice) == 0 {
 		return len(c.slice)
 	}
@@ -156,6 +808,25 @@ func (c *config[T]) Len() int {
 }
 
 func (c *config[T]) Get(index int) (T, error) {
+	return c.GetContext(context.Background(), index)
+}
+
+func (c *config[T]) GetContext(ctx context.Context, index int) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		var t T
+		return t, err
+	}
+	return c.getContext(ctx, index)
+}
+
+func (c *config[T]) get(index int) (T, error) {
+	return c.getContext(context.Background(), index)
+}
+
+func (c *config[T]) getContext(ctx context.Context, index int) (T, error) {
 	var retVal T
 	var err error
 	if index < 0 || index >= len(c.diskSlice)+len(c.slice) {
@@ -166,115 +837,592 @@ func (c *config[T]) Get(index int) (T, error) {
 		return c.slice[index], nil
 	}
 
-	index = index - len(c.slice)
-
-	retVal, err = c.read(fmt.Sprintf("%d", c.diskSlice[index]))
+	retVal, err = c.readContext(ctx, index, c.diskSlice[index-len(c.slice)])
 	if err != nil {
-		return retVal, fmt.Errorf(GetError, err.Error())
+		return retVal, fmt.Errorf(GetError, err)
 	}
 
 	return retVal, nil
 }
 
 func (c *config[T]) Put(index int, element T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+
 	if index >= len(c.diskSlice)+len(c.slice) || index < 0 {
 		return IndexOutOfBounds
 	}
+	return c.logged([]op[T]{{kind: opPut, start: index, values: []T{element}}}, func() error {
+		return c.put(index, element)
+	})
+}
 
+func (c *config[T]) put(index int, element T) error {
+	c.track(opPut, index)
 	if index < len(c.slice) {
+		if c.keep != nil {
+			c.keep.removed = []ref[T]{{v: c.slice[index], id: -1}}
+		}
 		c.slice[index] = element
+		c.record(1)
 		return nil
 	}
 
 	index = index - len(c.slice)
-	return c.write(c.diskSlice[index], element)
+	old := c.diskSlice[index]
+	if c.keep == nil && c.versions == 0 && !c.durable[old] {
+		return c.write(old, element)
+	}
+	// the file is kept for Undo or as a version, the element goes to a new one
+	r := ref[T]{id: old}
+	if c.keep != nil && c.versions > 0 {
+		// the file belongs to the versions
+		t, err := c.read(old)
+		if err != nil {
+			c.untrack()
+			return fmt.Errorf(GetError, err)
+		}
+		r = ref[T]{v: t, id: -1}
+	}
+	id, err := c.newID()
+	if err != nil {
+		c.untrack()
+		return err
+	}
+	if err := c.write(id, element); err != nil {
+		c.Release(id)
+		c.untrack()
+		return err
+	}
+	if _, ok := c.pinned[old]; ok {
+		c.pinned[id] = element
+	}
+	c.diskSlice[index] = id
+	if c.versions > 0 {
+		c.version(old, id)
+	}
+	if c.keep != nil {
+		c.keep.removed = []ref[T]{r}
+		c.record(1)
+	} else if c.versions == 0 {
+		// the file of the checkpoint, see WithWAL
+		c.remove(old)
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Swap(i, j int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+	if i < 0 || i >= c.len() || j < 0 || j >= c.len() {
+		return IndexOutOfBounds
+	}
+	if i == j {
+		return nil
+	}
+	if i > j {
+		i, j = j, i
+	}
+
+	h := len(c.slice)
+	switch {
+	case j < h:
+		c.slice[i], c.slice[j] = c.slice[j], c.slice[i]
+	case i >= h:
+		c.diskSlice[i-h], c.diskSlice[j-h] = c.diskSlice[j-h], c.diskSlice[i-h]
+	default:
+		// across the boundary: the disk file gets the head element
+		t, err := c.read(c.diskSlice[j-h])
+		if err != nil {
+			return fmt.Errorf(GetError, err)
+		}
+		id, err := c.overwrite(c.diskSlice[j-h], c.slice[i])
+		if err != nil {
+			return err
+		}
+		c.diskSlice[j-h] = id
+		c.slice[i] = t
+	}
+	if c.ttl > 0 {
+		c.born[i], c.born[j] = c.born[j], c.born[i]
+		c.shuffled = true
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Reverse() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+
+	// head ++ disk becomes reverse(disk) ++ reverse(head): the last k disk
+	// elements move to the head and k head elements take their files
+	h, d := len(c.slice), len(c.diskSlice)
+	k := min(h, d)
+	tail := c.diskSlice[d-k:]
+	moved := make([]T, k)
+	for m, id := range tail {
+		t, err := c.read(id)
+		if err != nil {
+			return fmt.Errorf(GetError, err)
+		}
+		moved[m] = t
+	}
+	for m, id := range tail {
+		id, err := c.overwrite(id, c.slice[k-1-m])
+		if err != nil {
+			return err
+		}
+		tail[m] = id
+	}
+
+	head := make([]T, 0, h)
+	for m := k - 1; m >= 0; m-- {
+		head = append(head, moved[m])
+	}
+	for m := h - 1; m >= k; m-- {
+		head = append(head, c.slice[m])
+	}
+	copy(c.slice, head)
+	// the tail ids keep their place, now holding the head elements
+	slices.Reverse(c.diskSlice[:d-k])
+	if c.ttl > 0 {
+		slices.Reverse(c.born)
+		c.shuffled = true
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Rotate(k int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+
+	n := c.len()
+	if n == 0 || k%n == 0 {
+		return nil
+	}
+	k = (k%n + n) % n
+	perm := make([]int, n)
+	for p := range perm {
+		perm[p] = (p + k) % n
+	}
+	return c.permute(perm)
 }
 
 func (c *config[T]) Slice(ind ...int) ([]T, error) {
-	if len(ind) > 2 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+
+	if len(ind) > 3 {
 		return nil, fmt.Errorf("invalid number of parameters: %d", len(ind))
 	}
-	start := 0
-	end := len(c.slice) + len(c.diskSlice)
-	if len(ind) == 1 {
-		start = ind[0]
+	start, end, limit, err := c.bounds(ind)
+	if err != nil {
+		return nil, err
 	}
-	if len(ind) == 2 {
-		start = ind[0]
-		end = ind[1]
+
+	retval := make([]T, end-start, limit-start)
+	if err := c.copyOut(retval, start); err != nil {
+		return nil, err
 	}
+	return retval, nil
+}
+
+func (c *config[T]) SliceInto(dst []T, start int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	if start < 0 || start > len(c.slice)+len(c.diskSlice) || end < 0 || end > len(c.slice)+len(c.diskSlice) {
-		return nil, IndexOutOfBounds
+	if err := c.expire(); err != nil {
+		return 0, err
 	}
+	start, end, _, err := c.bounds([]int{start})
+	if err != nil {
+		return 0, err
+	}
+	n := min(len(dst), end-start)
+	if err := c.copyOut(dst[:n], start); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
 
+// copyOut fills dst with the elements from start on, which must exist
+func (c *config[T]) copyOut(dst []T, start int) error {
+	end := start + len(dst)
 	// https://go.dev/ref/spec#Appending_and_copying_slices
 	// The number of elements copied is the minimum of len(src) and len(dst)
-	retval := make([]T, end-start, end-start)
 	var n int
 	if start < len(c.slice) {
-		if end >= len(c.slice) {
-			n = copy(retval, c.slice[start:])
-		} else {
-			n = copy(retval, c.slice[start:end])
-		}
+		n = copy(dst, c.slice[start:])
 		start = len(c.slice)
 	}
 
-	for i := start; i < end; i++ {
-		t, err := c.Get(i)
-		if err != nil {
-			return nil, err
+	if start >= end {
+		return nil
+	}
+	return c.readInto(dst[n:], c.diskSlice[start-len(c.slice):end-len(c.slice)])
+}
+
+// bounds returns the start, end and max of Slice(ind...), checked like
+// the Go slice expressions
+func (c *config[T]) bounds(ind []int) (int, int, int, error) {
+	n := c.len()
+	b := [3]int{0, n, n}
+	copy(b[:], ind)
+	if c.negativeIndices {
+		for i := range ind {
+			if b[i] < 0 {
+				b[i] += n
+			}
 		}
-		retval[n] = t
-		n++
 	}
-	return retval, nil
+	if b[0] < 0 || b[0] > b[1] || b[1] > b[2] || b[2] > n {
+		parts := make([]string, len(ind))
+		for i, x := range ind {
+			parts[i] = strconv.Itoa(x)
+		}
+		if len(ind) == 1 {
+			parts = append(parts, "")
+		}
+		return 0, 0, 0, fmt.Errorf("%w: [%s] with length %d", IndexOutOfBounds, strings.Join(parts, ":"), n)
+	}
+	return b[0], b[1], b[2], nil
 }
 
 func (c *config[T]) Delete(start, n int) error {
-	if start < 0 || start+n > c.Len() {
-		return fmt.Errorf("invalid parameters start=%d, todelete=%d for the slice of length %d", start, n, c.Len())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly && start != 0 {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
 	}
+	return c.logged([]op[T]{{kind: opDelete, start: start, n: n}}, func() error {
+		c.track(opDelete, start)
+		if c.buries(start, n) {
+			c.bury(start, n)
+			c.record(n)
+			return nil
+		}
+		if err := c.del(start, n); err != nil {
+			c.untrack()
+			return err
+		}
+		c.record(n)
+		return c.persist()
+	})
+}
 
-	if start < len(c.slice) {
-		if start+n <= len(c.slice) {
+func (c *config[T]) del(start, n int) error {
+	if start < 0 || n < 0 || n > c.len()-start {
+		return fmt.Errorf("invalid parameters start=%d, todelete=%d for the slice of length %d", start, n, c.len())
+	}
+	c.hooks.deleted(n)
+	c.collect(start, n)
+	c.deleted(start, n)
+	if c.ttl > 0 {
+		if start == 0 {
+			c.born = c.born[n:]
+		} else {
+			c.born = append(c.born[:start], c.born[start+n:]...)
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	// the disk tail follows the head, full unless the tail is empty
+	head := len(c.slice)
+	if start < head {
+		if start+n <= head {
 			copy(c.slice[start:], c.slice[start+n:])
-			c.slice = c.slice[:len(c.slice)-n]
+			c.slice = c.slice[:head-n]
 		} else {
 			c.slice = c.slice[:start]
-			num := start + n - cap(c.slice)
-			for i := 0; i < num; i++ {
-				c.ch <- c.diskSlice[i]
-			}
-			copy(c.diskSlice[0:], c.diskSlice[num:])
-			c.diskSlice = c.diskSlice[:len(c.diskSlice)-num]
+			num := start + n - head
+			c.discard(c.diskSlice[:num]...)
+			c.diskSlice = c.diskSlice[num:]
 		}
 
-		n = min(cap(c.slice)-len(c.slice), len(c.diskSlice))
-		for i := 0; i < n; i++ {
-			t, err := c.read(fmt.Sprintf("%d", c.diskSlice[i]))
+		return c.refill()
+	}
+
+	c.discard(c.diskSlice[start-head : start-head+n]...)
+	if start == head {
+		c.diskSlice = c.diskSlice[n:]
+		return nil
+	}
+	copy(c.diskSlice[start-head:], c.diskSlice[start-head+n:])
+	c.diskSlice = c.diskSlice[:len(c.diskSlice)-n]
+	return nil
+}
+
+func (c *config[T]) Truncate(n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncate(n)
+}
+
+func (c *config[T]) truncate(n int) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly && n != 0 && n < c.len() {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	if n < 0 || n > c.len() {
+		return IndexOutOfBounds
+	}
+	m := c.len() - n
+	return c.logged([]op[T]{{kind: opDelete, start: n, n: m}}, func() error {
+		c.track(opDelete, n)
+		if err := c.del(n, m); err != nil {
+			c.untrack()
+			return err
+		}
+		c.record(m)
+		// let the dropped head elements be garbage collected
+		clear(c.slice[len(c.slice):cap(c.slice)])
+		return c.persist()
+	})
+}
+
+func (c *config[T]) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncate(0)
+}
+
+func (c *config[T]) SetMemoryCapacity(n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	if n < 0 {
+		return IndexOutOfBounds
+	}
+
+	if n < len(c.slice) {
+		ids := make([]int, 0, len(c.slice)-n+len(c.diskSlice))
+		for _, t := range c.slice[n:] {
+			id, err := c.newID()
 			if err != nil {
-				return fmt.Errorf(GetError, err.Error())
+				c.remove(ids...)
+				return err
+			}
+			if err := c.write(id, t); err != nil {
+				c.Release(id)
+				c.remove(ids...)
+				return err
 			}
-			c.slice = append(c.slice, t)
-			c.ch <- c.diskSlice[i]
+			ids = append(ids, id)
 		}
-		if n > 0 {
-			copy(c.diskSlice[0:], c.diskSlice[n:])
-			c.diskSlice = c.diskSlice[:len(c.diskSlice)-n]
+		c.diskSlice = append(ids, c.diskSlice...)
+		c.hooks.spilled(len(ids))
+	}
+
+	slice := make([]T, min(n, len(c.slice)), n)
+	copy(slice, c.slice)
+	c.slice = slice
+	if err := c.refill(); err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+// refill moves the front of the disk tail to the free room of the head
+func (c *config[T]) refill() error {
+	n := min(cap(c.slice)-len(c.slice), len(c.diskSlice))
+	var err error
+	moved := 0
+	for ; moved < n; moved++ {
+		t, e := c.read(c.diskSlice[moved])
+		if e != nil {
+			err = fmt.Errorf(GetError, e)
+			break
 		}
+		c.slice = append(c.slice, t)
+	}
+	c.remove(c.diskSlice[:moved]...)
+	// reslicing instead of copying keeps popping the front O(1):
+	// the dropped ids are reclaimed when append reallocates the array
+	c.diskSlice = c.diskSlice[moved:]
+	return err
+}
+
+// expire deletes the elements that are older than the TTL: the leading
+// ones, unless the elements were reordered
+func (c *config[T]) expire() error {
+	if c.ttl <= 0 {
 		return nil
 	}
+	deadline := time.Now().Add(-c.ttl)
+	if c.shuffled {
+		return c.expireAll(deadline)
+	}
+	n := 0
+	for n < len(c.born) && c.born[n].Before(deadline) {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	c.forget()
+	if err := c.del(0, n); err != nil {
+		return err
+	}
+	return c.persist()
+}
 
-	for i := start - cap(c.slice); i < start-cap(c.slice)+n; i++ {
-		c.ch <- c.diskSlice[i]
+// expireAll deletes the elements appended before deadline wherever
+// they are, in one pass
+func (c *config[T]) expireAll(deadline time.Time) error {
+	var spans [][2]int
+	var last time.Time
+	sorted := true
+	for i, t := range c.born {
+		if !t.Before(deadline) {
+			sorted = sorted && !t.Before(last)
+			last = t
+			continue
+		}
+		if k := len(spans) - 1; k >= 0 && spans[k][1] == i {
+			spans[k][1]++
+		} else {
+			spans = append(spans, [2]int{i, i + 1})
+		}
 	}
-	copy(c.diskSlice[start-cap(c.slice):], c.diskSlice[start-cap(c.slice)+n:])
-	c.diskSlice = c.diskSlice[:len(c.diskSlice)-n]
-	return nil
+	// in order again once the reordered elements are gone
+	c.shuffled = !sorted
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := c.deleteSpans(spans); err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+func (c *config[T]) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sync()
+}
+
+func (c *config[T]) sync() error {
+	if c.async != nil {
+		if err := c.async.sync(); err != nil {
+			return err
+		}
+	}
+	return c.Storage.Flush()
+}
+
+func (c *config[T]) Verify() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := []error{c.sync()}
+	for i, id := range c.diskSlice {
+		if err := storage.Check(c.Storage, id); err != nil {
+			errs = append(errs, fmt.Errorf("element %d: %w", len(c.slice)+i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *config[T]) Dir() string {
+	return c.RootPath
 }
 
 func (c *config[T]) Cleanup() {
-	c.ch <- CLEANUP
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		// cleaned up already
+		return
+	default:
+	}
+	close(c.done)
+	c.freed.Broadcast()
+	if c.async != nil {
+		c.async.stop()
+	}
+	if c.quota != nil {
+		c.quota.leave(c.Storage)
+	}
+	if c.walFile != nil {
+		c.walFile.Close()
+	}
+	if c.mlog != nil && c.mlog.f != nil {
+		c.mlog.f.Close()
+	}
+	c.unregister()
+	c.Storage.Cleanup()
+}
+
+func (c *config[T]) Close() {
+	c.Cleanup()
+	c.Drain()
 }
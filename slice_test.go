@@ -1,13 +1,19 @@
 package slice_on_disk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
 )
 
 func intSlicer() Slicer[int] {
@@ -70,19 +76,18 @@ func TestSlice(t *testing.T) {
 
 		t.Logf("9903 total length %d took %v", total, time.Since(start))
 
-		c, _ := s.(*config[string])
-		if cap(c.slice) != 5 || len(c.slice) != 5 || len(c.diskSlice) != 9900 {
-			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d", cap(c.slice), len(c.slice), len(c.diskSlice))
+		if s.Cap() != 5 || s.MemLen() != 5 || s.DiskLen() != 9900 {
+			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d", s.Cap(), s.MemLen(), s.DiskLen())
 		}
 
 		s.Delete(0, 3)
-		if cap(c.slice) != 5 || len(c.slice) != 5 || len(c.diskSlice) != 9897 {
-			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d", cap(c.slice), len(c.slice), len(c.diskSlice))
+		if s.Cap() != 5 || s.MemLen() != 5 || s.DiskLen() != 9897 {
+			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d", s.Cap(), s.MemLen(), s.DiskLen())
 		}
 
 		s.Delete(0, 100)
-		if cap(c.slice) != 5 || len(c.slice) != 5 || len(c.diskSlice) != 9797 || s.Len() != 9797+5 {
-			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, c.Len()=%d", cap(c.slice), len(c.slice), len(c.diskSlice), c.Len())
+		if s.Cap() != 5 || s.MemLen() != 5 || s.DiskLen() != 9797 || s.Len() != 9797+5 {
+			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, Len()=%d", s.Cap(), s.MemLen(), s.DiskLen(), s.Len())
 		}
 		s.Cleanup()
 
@@ -90,9 +95,8 @@ func TestSlice(t *testing.T) {
 		sl.Delete(7, 5)
 		// 7,8,9 from slice, 10,11 from diskslice are gone
 		// 12,13,14 from diskslice replace 7,8,9
-		cl, _ := sl.(*config[int])
-		if cap(cl.slice) != 10 || len(cl.slice) != 10 || len(cl.diskSlice) != 85 || sl.Len() != 85+10 {
-			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, c.Len()=%d", cap(cl.slice), len(cl.slice), len(cl.diskSlice), sl.Len())
+		if sl.Cap() != 10 || sl.MemLen() != 10 || sl.DiskLen() != 85 || sl.Len() != 85+10 {
+			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, Len()=%d", sl.Cap(), sl.MemLen(), sl.DiskLen(), sl.Len())
 		}
 		if x, _ := sl.Get(7); x != 12 {
 			t.Errorf("unexpeted value of sl[7]: x=%d, want 12", x)
@@ -101,10 +105,8 @@ func TestSlice(t *testing.T) {
 
 		sl = intSlicer()
 		sl.Delete(20, 7)
-		cl, _ = sl.(*config[int])
-
-		if cap(cl.slice) != 10 || len(cl.slice) != 10 || len(cl.diskSlice) != 83 || sl.Len() != 83+10 {
-			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, c.Len()=%d", cap(cl.slice), len(cl.slice), len(cl.diskSlice), sl.Len())
+		if sl.Cap() != 10 || sl.MemLen() != 10 || sl.DiskLen() != 83 || sl.Len() != 83+10 {
+			t.Errorf("unexpeted len or cap: cap=%d, len=%d, disklen=%d, Len()=%d", sl.Cap(), sl.MemLen(), sl.DiskLen(), sl.Len())
 		}
 		if x, _ := sl.Get(20); x != 27 {
 			t.Errorf("unexpeted value of sl[20]: x=%d, want 27", x)
@@ -164,8 +166,8 @@ func TestSlice(t *testing.T) {
 }
 
 type msg struct {
-	placed  time.Time
-	payload string
+	Placed  time.Time
+	Payload string
 }
 
 func TestLong(t *testing.T) {
@@ -187,6 +189,1108 @@ func TestLong(t *testing.T) {
 	if err != nil {
 		t.Errorf("error getting overflow %d: %s", overflow.Len()-1, err)
 	}
-	t.Logf("overflow len %d, payload len: %d", overflow.Len(), len(m.payload))
+	t.Logf("overflow len %d, payload len: %d", overflow.Len(), len(m.Payload))
+
+}
+
+func TestMaxLen(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithMaxLen(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	for i := 0; i < 100; i++ {
+		if err := s.Append(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 20 {
+		t.Errorf("Len() = %d, want 20", s.Len())
+	}
+	if x, _ := s.Get(0); x != 80 {
+		t.Errorf("Get(0) = %d, want 80", x)
+	}
+
+	// a batch longer than the bound keeps its tail only
+	batch := make([]int, 50)
+	for i := range batch {
+		batch[i] = 1000 + i
+	}
+	if err := s.Append(batch...); err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 20 || x[0] != 1030 || x[19] != 1049 {
+		t.Errorf("Slice() = %v, want 1030..1049", x)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	s, err := New([]int{0, 1}, os.TempDir(), WithTTL(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	for i := 2; i < 10; i++ {
+		s.Append(i)
+	}
+	time.Sleep(60 * time.Millisecond)
+	for i := 10; i < 15; i++ {
+		s.Append(i)
+	}
+
+	if s.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", s.Len())
+	}
+	if x, _ := s.Get(0); x != 10 {
+		t.Errorf("Get(0) = %d, want 10", x)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if x, err := s.Slice(); err != nil || len(x) != 0 {
+		t.Errorf("Slice() = %v, %v, want empty", x, err)
+	}
+}
+
+func TestTTLReordered(t *testing.T) {
+	for name, tc := range map[string]struct {
+		call func(s Slicer[int]) error
+		want []int
+	}{
+		"Reverse":   {func(s Slicer[int]) error { return s.Reverse() }, []int{5, 4, 3}},
+		"Rotate":    {func(s Slicer[int]) error { return s.Rotate(3) }, []int{3, 4, 5}},
+		"Swap":      {func(s Slicer[int]) error { return s.Swap(0, 4) }, []int{4, 3, 5}},
+		"Swap disk": {func(s Slicer[int]) error { return s.Swap(2, 5) }, []int{5, 3, 4}},
+	} {
+		s, err := New(make([]int, 0, 2), os.TempDir(), WithTTL(100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Append(0, 1, 2)
+		time.Sleep(60 * time.Millisecond)
+		s.Append(3, 4, 5)
+		if err := tc.call(s); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// the elements appended first expire, wherever they are now
+		time.Sleep(60 * time.Millisecond)
+		if x, err := s.Slice(); err != nil || !slices.Equal(x, tc.want) {
+			t.Errorf("%s: Slice() = %v, %v, want %v", name, x, err, tc.want)
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		s.Cleanup()
+	}
+}
+
+func TestAsyncWrites(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithAsyncWrites(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+
+	for i := 0; i < 200; i++ {
+		if err := s.Append(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// served from memory or the disk, whichever is the case
+	for i := 0; i < 200; i += 7 {
+		if x, err := s.Get(i); err != nil || x != i {
+			t.Errorf("Get(%d) = %d, %v, want %d", i, x, err, i)
+		}
+	}
+	s.Put(100, 1000)
+	s.Delete(0, 10)
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := s.(*config[int])
+	if len(c.async.pending) != 0 {
+		t.Errorf("%d elements are still pending after Sync", len(c.async.pending))
+	}
+	if x, _ := s.Get(90); x != 1000 {
+		t.Errorf("Get(90) = %d, want 1000", x)
+	}
+	x, err := s.Slice(0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range x {
+		if x[i] != i+10 {
+			t.Errorf("Slice(0, 5)[%d] = %d, want %d", i, x[i], i+10)
+		}
+	}
+}
+
+func TestReadOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"prefetch": WithPrefetch(8),
+		"parallel": WithParallelReads(4),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := New(make([]int, 0, 5), os.TempDir(), opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Cleanup()
+
+			for i := 0; i < 100; i++ {
+				s.Append(i)
+			}
+			for _, r := range [][2]int{{0, 100}, {3, 7}, {5, 6}, {10, 12}, {90, 100}} {
+				x, err := s.Slice(r[0], r[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(x) != r[1]-r[0] {
+					t.Fatalf("Slice(%d, %d) returned %d elements", r[0], r[1], len(x))
+				}
+				for i := range x {
+					if x[i] != r[0]+i {
+						t.Errorf("Slice(%d, %d)[%d] = %d, want %d", r[0], r[1], i, x[i], r[0]+i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPrefetchStop(t *testing.T) {
+	s, err := New(make([]int, 0, 5), os.TempDir(), WithPrefetch(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 100)...)
+
+	// the scans stop at the first disk element with reads still running,
+	// Pin and Unpin change the cache those reads look up
+	for range 50 {
+		if i, err := s.IndexFunc(func(v int) bool { return v == 5 }); i != 5 || err != nil {
+			t.Fatalf("IndexFunc() = %d, %v, want 5", i, err)
+		}
+		if err := s.Pin(seq(5, 20)...); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Unpin(seq(5, 20)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// flip a byte of the element 50 and truncate the element 60
+	c, _ := s.(*config[int])
+	fname := c.Path(c.diskSlice[40])
+	b, _ := os.ReadFile(fname)
+	b[len(b)-1] ^= 0xff
+	os.WriteFile(fname, b, 0644)
+	fname = c.Path(c.diskSlice[50])
+	b, _ = os.ReadFile(fname)
+	os.WriteFile(fname, b[:len(b)-1], 0644)
+
+	if _, err := s.Get(50); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Get(50) = %v, want ErrCorrupted", err)
+	}
+	if _, err := s.Get(51); err != nil {
+		t.Errorf("Get(51) = %v", err)
+	}
+	err := s.Verify()
+	if !errors.Is(err, ErrCorrupted) || !strings.Contains(err.Error(), "element 50") || !strings.Contains(err.Error(), "element 60") {
+		t.Errorf("Verify() = %v, want ErrCorrupted for elements 50 and 60", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// truncate the element 50 and leave a stray file behind
+	c, _ := s.(*config[int])
+	fname := c.Path(c.diskSlice[40])
+	b, _ := os.ReadFile(fname)
+	os.WriteFile(fname, b[:len(b)-1], 0644)
+	stray := filepath.Join(c.RootPath, "stray")
+	os.WriteFile(stray, b, 0644)
+
+	var v *ValidationError
+	if err := s.Validate(); !errors.As(err, &v) {
+		t.Fatalf("Validate() = %v, want a *ValidationError", err)
+	}
+	var unreadable, unreferenced bool
+	for _, i := range v.Issues {
+		switch {
+		case i.Kind == IssueUnreadable && i.Index == 50:
+			unreadable = true
+		case i.Kind == IssueUnreferenced && i.Path == stray:
+			unreferenced = true
+		case i.Kind == IssueCount && strings.Contains(i.Detail, "bytes accounted"):
+			// the truncated file
+		default:
+			t.Errorf("unexpected issue %v", i)
+		}
+	}
+	if !unreadable || !unreferenced {
+		t.Errorf("Validate() = %v, want element 50 unreadable and %s unreferenced", v, stray)
+	}
+}
+
+func TestDump(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	c, _ := s.(*config[int])
+
+	var b strings.Builder
+	if err := s.Dump(&b, 12); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 14 {
+		t.Fatalf("Dump(12) wrote %d lines, want a header, 12 elements and a trailer:\n%s", len(lines), b.String())
+	}
+	if f := strings.Fields(lines[1]); f[0] != "0" || f[1] != "mem" || f[4] != "0" {
+		t.Errorf("Dump(12) line 1 = %q", lines[1])
+	}
+	file := filepath.Base(c.Path(c.diskSlice[1]))
+	if f := strings.Fields(lines[12]); f[0] != "11" || f[1] != "disk" || f[2] != file || f[4] != "11" {
+		t.Errorf("Dump(12) line 12 = %q, want element 11 in %s", lines[12], file)
+	}
+	if !strings.Contains(lines[13], "88 more") {
+		t.Errorf("Dump(12) trailer = %q", lines[13])
+	}
+}
+
+func TestCleanerWorkers(t *testing.T) {
+	s, _ := New(make([]int, 0, 10), os.TempDir(), WithCleanerWorkers(4))
+	for i := 0; i < 2000; i++ {
+		s.Append(i)
+	}
+	s.Delete(5, 1500)
+	if x, _ := s.Get(5); s.Len() != 500 || x != 1505 {
+		t.Errorf("Len() = %d, Get(5) = %d, want 500 and 1505", s.Len(), x)
+	}
+	dir := s.Dir()
+	s.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Stat() = %v after Close, want the directory removed", err)
+	}
+}
+
+func TestIOLimits(t *testing.T) {
+	s, _ := New(make([]int, 0), os.TempDir(), WithIOPSLimit(50))
+	defer s.Cleanup()
+	start := time.Now()
+	// a burst of 50, the next 25 take half a second
+	for i := 0; i < 75; i++ {
+		s.Append(i)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("75 writes took %v, want half a second", d)
+	}
+	if st := s.Stats().Throttle; st.Waited < 400*time.Millisecond || st.Waiting != 0 {
+		t.Errorf("Stats().Throttle = %+v", st)
+	}
+
+	big := strings.Repeat("x", 10000)
+	ss, _ := New(make([]string, 0), os.TempDir(), WithWriteRateLimit(100000))
+	defer ss.Cleanup()
+	start = time.Now()
+	for i := 0; i < 15; i++ {
+		ss.Append(big)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("150kB took %v at 100kB/s, want half a second", d)
+	}
+}
+
+// slow takes W ms to encode and R ms to decode, like a file on a hung volume
+type slow struct{ W, R int }
+
+func (s slow) GobEncode() ([]byte, error) {
+	time.Sleep(time.Duration(s.W) * time.Millisecond)
+	return strconv.AppendInt(nil, int64(s.R), 10), nil
+}
+
+func (s *slow) GobDecode(b []byte) error {
+	var err error
+	s.R, err = strconv.Atoi(string(b))
+	time.Sleep(time.Duration(s.R) * time.Millisecond)
+	return err
+}
+
+func TestOpTimeout(t *testing.T) {
+	s, _ := New(make([]slow, 0, 1), os.TempDir(), WithOpTimeout(50*time.Millisecond))
+	defer s.Cleanup()
+	c, _ := s.(*config[slow])
+	s.Append(slow{}, slow{}, slow{R: 200})
+
+	start := time.Now()
+	if err := s.Append(slow{W: 1000}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Append() = %v, want ErrTimeout", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Append() took %v", d)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", s.Len())
+	}
+	if _, err := s.Get(1); err != nil {
+		t.Errorf("Get(1) = %v", err)
+	}
+	if _, err := s.Get(2); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Get(2) = %v, want ErrTimeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.GetContext(ctx, 1); err != nil {
+		t.Errorf("GetContext(1) = %v", err)
+	}
+	if err := s.AppendContext(ctx, slow{W: 40}); !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, ErrTimeout) {
+		t.Errorf("AppendContext() = %v, want ErrTimeout and DeadlineExceeded", err)
+	}
+
+	// the late writes leave nothing behind
+	time.Sleep(time.Second)
+	c.Wait()
+	if files, _ := c.Files(); len(files) != 2 {
+		t.Errorf("%d files, want the ones of the elements 1 and 2", len(files))
+	}
+}
+
+func TestSwap(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	c, _ := s.(*config[int])
+	id40, id70 := c.diskSlice[30], c.diskSlice[60]
+
+	for _, p := range [][2]int{{1, 2}, {40, 70}, {5, 50}, {99, 0}, {7, 7}} {
+		if err := s.Swap(p[0], p[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[int]int{1: 2, 2: 1, 40: 70, 70: 40, 5: 50, 50: 5, 0: 99, 99: 0, 7: 7}
+	for i, w := range want {
+		if x, _ := s.Get(i); x != w {
+			t.Errorf("Get(%d) = %d, want %d", i, x, w)
+		}
+	}
+	// both on the disk: the files stay, the index is swapped
+	if c.diskSlice[30] != id70 || c.diskSlice[60] != id40 {
+		t.Errorf("disk ids were not swapped")
+	}
+	if err := s.Swap(0, 100); err != IndexOutOfBounds {
+		t.Errorf("Swap(0, 100) = %v, want IndexOutOfBounds", err)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	for _, n := range []int{0, 3, 10, 15, 100} {
+		s, _ := New(make([]int, 0, 10), os.TempDir())
+		for i := 0; i < n; i++ {
+			s.Append(i)
+		}
+		if err := s.Reverse(); err != nil {
+			t.Fatal(err)
+		}
+		x, err := s.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(x) != n {
+			t.Errorf("n=%d: Len() = %d", n, len(x))
+		}
+		for i := range x {
+			if x[i] != n-1-i {
+				t.Errorf("n=%d: element %d: %d, want %d", n, i, x[i], n-1-i)
+			}
+		}
+		s.Cleanup()
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if err := s.Truncate(50); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 50 || s.DiskLen() != 40 {
+		t.Errorf("Len() = %d, disklen = %d, want 50, 40", s.Len(), s.DiskLen())
+	}
+	if x, _ := s.Get(49); x != 49 {
+		t.Errorf("Get(49) = %d, want 49", x)
+	}
+	if err := s.Delete(50, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(20, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", s.Len())
+	}
+	if err := s.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 5 || s.DiskLen() != 0 {
+		t.Errorf("Len() = %d, disklen = %d, want 5, 0", s.Len(), s.DiskLen())
+	}
+	if err := s.Truncate(6); err != IndexOutOfBounds {
+		t.Errorf("Truncate(6) = %v, want IndexOutOfBounds", err)
+	}
+	// nothing to drop, the head isn't full
+	if err := s.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 5 {
+		t.Errorf("Len() = %d, want 5", s.Len())
+	}
+
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", s.Len())
+	}
+	if err := s.Clear(); err != nil {
+		t.Errorf("Clear() on an empty Slicer = %v", err)
+	}
+	if err := s.Delete(0, 0); err != nil {
+		t.Errorf("Delete(0, 0) on an empty Slicer = %v", err)
+	}
+	// still usable
+	for i := 0; i < 20; i++ {
+		s.Append(i)
+	}
+	if x, _ := s.Get(15); s.Len() != 20 || x != 15 {
+		t.Errorf("Len() = %d, Get(15) = %d, want 20, 15", s.Len(), x)
+	}
+}
+
+func TestSetMemoryCapacity(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	if err := s.SetMemoryCapacity(25); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cap() != 25 || s.MemLen() != 25 || s.DiskLen() != 75 {
+		t.Errorf("cap=%d, len=%d, disklen=%d, want 25, 25, 75", s.Cap(), s.MemLen(), s.DiskLen())
+	}
+	if err := s.SetMemoryCapacity(4); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cap() != 4 || s.MemLen() != 4 || s.DiskLen() != 96 {
+		t.Errorf("cap=%d, len=%d, disklen=%d, want 4, 4, 96", s.Cap(), s.MemLen(), s.DiskLen())
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range x {
+		if x[i] != i {
+			t.Fatalf("element %d: %d, want %d", i, x[i], i)
+		}
+	}
+	// everything fits in memory
+	if err := s.SetMemoryCapacity(200); err != nil {
+		t.Fatal(err)
+	}
+	if s.MemLen() != 100 || s.DiskLen() != 0 {
+		t.Errorf("len=%d, disklen=%d, want 100, 0", s.MemLen(), s.DiskLen())
+	}
+	s.Append(100)
+	if x, _ := s.Get(100); x != 100 || s.DiskLen() != 0 {
+		t.Errorf("Get(100) = %d, disklen=%d, want 100, 0", x, s.DiskLen())
+	}
+}
+
+func TestDeleteFront(t *testing.T) {
+	s, _ := New(make([]int, 0, 4), os.TempDir())
+	defer s.Cleanup()
+
+	// the queue pattern: the window slides over the disk ids
+	next := 0
+	for i := 0; i < 2000; i++ {
+		s.Append(i)
+		if i%2 == 1 {
+			continue
+		}
+		if x, _ := s.Get(0); x != next {
+			t.Fatalf("front %d, want %d", x, next)
+		}
+		if err := s.Delete(0, 1); err != nil {
+			t.Fatal(err)
+		}
+		next++
+	}
+	if s.Len() != 1000 {
+		t.Errorf("Len %d, want 1000", s.Len())
+	}
+	if c := cap(s.(*config[int]).diskSlice); c > 4000 {
+		t.Errorf("the disk ids hold on to %d slots for %d elements", c, s.Len())
+	}
+}
+
+func TestDeleteMiddle(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	k := s.Cursor()
+	defer k.Close()
+	for k.Next() && k.Value() < 70 {
+	}
+
+	if err := s.Delete(50, 10); err != nil {
+		t.Fatal(err)
+	}
+	// the next calls sweep the tombstones
+	if x, _ := s.Get(50); x != 60 || s.Len() != 90 {
+		t.Errorf("Get(50) = %d, Len() = %d, want 60, 90", x, s.Len())
+	}
+	s.Delete(20, 5)
+	s.Delete(30, 5)
+	want := slices.Concat(seq(0, 20), seq(25, 35), seq(40, 50), seq(60, 100))
+	if x, _ := s.Slice(); !slices.Equal(x, want) {
+		t.Errorf("Slice() = %v, want %v", x, want)
+	}
+	if k.Next(); k.Value() != 71 {
+		t.Errorf("the cursor is at %d, want 71", k.Value())
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	u, _ := New(make([]int, 0, 10), os.TempDir(), WithUndo(2))
+	defer u.Cleanup()
+	u.Append(seq(0, 100)...)
+	u.Delete(50, 10)
+	if err := u.Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := u.Slice(); !slices.Equal(x, seq(0, 100)) {
+		t.Errorf("Slice() after Undo = %v", x)
+	}
+}
+
+func TestSharding(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithSharding(4), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		s.Append(i)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir(), "01", "13")); err != nil {
+		t.Errorf("element 13 is not in its shard: %v", err)
+	}
+	s.Delete(20, 5)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	want := append(seq(0, 20), seq(25, 50)...)
+
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+	// the process "dies", Open reads the layout from the manifest
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+
+	// the head of the dead process is lost
+	for x, want := range map[Slicer[int]][]int{cl: want, o: want[10:]} {
+		got, err := x.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: %v, want %v", x.Dir(), got, want)
+		}
+	}
+}
+
+func TestRootPaths(t *testing.T) {
+	for name, spread := range map[string]Spread{"round robin": SpreadRoundRobin, "free space": SpreadFreeSpace} {
+		a, b := t.TempDir(), t.TempDir()
+		s, err := New(make([]int, 0, 10), os.TempDir(), WithRootPaths(spread, a, b), WithSharding(2), WithManifest())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 70; i++ {
+			s.Append(i)
+		}
+		st := s.Stats()
+		if st.Len != 70 || st.MemLen != 10 || st.DiskLen != 60 || len(st.Roots) != 3 {
+			t.Fatalf("%s: Stats() = %+v", name, st)
+		}
+		files := 0
+		var bytes int64
+		for k, root := range st.Roots {
+			if k > 0 && filepath.Dir(root.Path) != []string{a, b}[k-1] {
+				t.Errorf("%s: root %d is %s", name, k, root.Path)
+			}
+			if spread == SpreadRoundRobin && root.Files != 20 {
+				t.Errorf("%s: %d files in %s, want 20", name, root.Files, root.Path)
+			}
+			files += root.Files
+			bytes += root.Bytes
+		}
+		if files != 60 || bytes != st.DiskBytes {
+			t.Errorf("%s: %d files of %d bytes, want 60 of %d", name, files, bytes, st.DiskBytes)
+		}
+
+		s.Delete(20, 5)
+		if err := s.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		want := append(seq(0, 20), seq(25, 70)...)
+		// the head of o comes from the files of s, which must not be used
+		// after the takeover, see WithStealLock
+		cl, err := s.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, want := range map[Slicer[int]][]int{cl: want, o: want[10:]} {
+			got, err := x.Slice()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s: %v, want %v", name, got, want)
+			}
+		}
+		// the files of the elements moved to the head are gone
+		o.(*config[int]).Wait()
+		files = 0
+		for k, root := range o.Stats().Roots {
+			// the free space of the roots may put all the files in one
+			if root.Path != st.Roots[k].Path || spread == SpreadRoundRobin && root.Files == 0 {
+				t.Errorf("%s: root %d after Open: %+v", name, k, root)
+			}
+			files += root.Files
+		}
+		if files != o.DiskLen() {
+			t.Errorf("%s: %d files after Open, want %d", name, files, o.DiskLen())
+		}
+		o.Cleanup()
+		cl.Cleanup()
+		s.Cleanup()
+	}
+}
+
+func TestModes(t *testing.T) {
+	s, err := New(make([]int, 0, 1), os.TempDir(), WithFileMode(0640), WithDirMode(0750), WithSharding(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(1, 2)
+
+	for name, want := range map[string]os.FileMode{
+		s.Dir():                           0750 | os.ModeDir,
+		filepath.Join(s.Dir(), "01"):      0750 | os.ModeDir,
+		filepath.Join(s.Dir(), "01", "1"): 0640,
+	} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != want {
+			t.Errorf("%s: %s, want %s", name, info.Mode(), want)
+		}
+	}
+}
+
+func TestReuseIDs(t *testing.T) {
+	s, _ := New(make([]int, 0, 4), os.TempDir())
+	defer s.Cleanup()
+	c := s.(*config[int])
+
+	// a queue: the deleted ids are reused once their files are gone
+	for i := 0; i < 1000; i++ {
+		s.Append(i)
+		if s.Len() > 10 {
+			s.Delete(0, 1)
+			c.Wait()
+		}
+	}
+	if c.diskIndex > 20 {
+		t.Errorf("%d ids used for 10 elements", c.diskIndex)
+	}
+	got, _ := s.Slice()
+	if want := seq(990, 1000); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPacking(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithPacking(1024), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.Append(i)
+	}
+	// one block file, the manifest, its log and the lock
+	if entries, _ := os.ReadDir(s.Dir()); len(entries) != 4 {
+		t.Errorf("%d files, want 4", len(entries))
+	}
+	// a large element gets its own file
+	big := strings.Repeat("x", 2048)
+	ss, _ := New(make([]string, 0), os.TempDir(), WithPacking(1024))
+	defer ss.Cleanup()
+	ss.Append("small", big)
+	if x, err := ss.Get(1); err != nil || x != big {
+		t.Errorf("Get(1) = %d bytes, %v", len(x), err)
+	}
+
+	s.Put(50, -50)
+	s.Delete(20, 10)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	want := append(seq(0, 20), seq(30, 100)...)
+	want[40] = -50
+
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+	o, err := Open(make([]int, 0, 10), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+
+	for x, want := range map[Slicer[int]][]int{cl: want, o: want[10:]} {
+		got, err := x.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: %v, want %v", x.Dir(), got, want)
+		}
+	}
 
+	// the block files go away with their elements
+	o.Truncate(0)
+	o.(*config[int]).Wait()
+	if blocks := o.(*config[int]).Blocks(); len(blocks) > 1 {
+		t.Errorf("block files left: %v", blocks)
+	}
+}
+
+func TestChunking(t *testing.T) {
+	s, err := New(make([]string, 0, 1), os.TempDir(), WithChunking(1024), WithManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := strings.Repeat("0123456789", 1000)
+	s.Append("head", "small", big, big+big)
+	c := s.(*config[string])
+	if names := c.Names(c.diskSlice[1]); len(names) != 10 {
+		t.Errorf("%d chunks, want 10", len(names))
+	}
+	if x, err := s.Get(2); err != nil || x != big {
+		t.Errorf("Get(2) = %d bytes, %v", len(x), err)
+	}
+
+	// smaller, then larger again
+	s.Put(3, "medium")
+	s.Put(1, big)
+	s.Delete(2, 1)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"head", big, "medium"}
+
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+	o, err := Open(make([]string, 0, 1), s.Dir(), WithStealLock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Cleanup()
+
+	for x, want := range map[Slicer[string]][]string{cl: want, o: want[1:]} {
+		got, err := x.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: %d elements, want %d", x.Dir(), len(got), len(want))
+		}
+	}
+
+	o.Truncate(0)
+	o.(*config[string]).Wait()
+	// and the locks of the owner and of Compact
+	if entries, _ := os.ReadDir(o.Dir()); len(entries) != 4 {
+		t.Errorf("%d files left, want the manifest and its log", len(entries))
+	}
+}
+
+func TestAppendOnly(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithAppendOnly(), WithDurability(DurabilityBatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	for i := 0; i < 1000; i++ {
+		s.Append(i)
+	}
+	// the whole tail is in the log, next to the lock
+	if entries, _ := os.ReadDir(s.Dir()); len(entries) != 2 {
+		t.Errorf("%d files, want 2", len(entries))
+	}
+	if err := s.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"Put":          s.Put(500, 1),
+		"Swap":         s.Swap(1, 500),
+		"Reverse":      s.Reverse(),
+		"Delete":       s.Delete(5, 1),
+		"DeleteRanges": s.DeleteRanges([2]int{0, 1}, [2]int{5, 1}),
+		"Truncate":     s.Truncate(5),
+	} {
+		if !errors.Is(err, ErrAppendOnly) {
+			t.Errorf("%s() = %v, want ErrAppendOnly", name, err)
+		}
+	}
+
+	// draining
+	if err := s.Delete(0, 600); err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(x) != fmt.Sprint(seq(600, 1000)) {
+		t.Errorf("Slice() = %v, want 600..999", x)
+	}
+	if err := s.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	s.(*config[int]).Wait()
+	s.Append(1)
+	if x, err := s.Get(0); err != nil || x != 1 {
+		t.Errorf("Get(0) = %d, %v, want 1", x, err)
+	}
+}
+
+func TestReplica(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"plain":  nil,
+		"packed": {WithPacking(1024)},
+	} {
+		replica := t.TempDir()
+		s, err := New(make([]int, 0, 10), os.TempDir(), append(opts, WithReplica(replica), WithManifest())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Cleanup()
+		for i := 10; i < 100; i++ {
+			s.Append(i)
+		}
+		c := s.(*config[int])
+		m := c.Replica()
+		if m == nil || filepath.Dir(m.RootPath) != replica {
+			t.Fatalf("%s: no replica in %s", name, replica)
+		}
+
+		// a corrupted element, then a lost file
+		id := c.diskSlice[40]
+		fname, at := c.Path(id), int64(-1)
+		if e, ok := c.Entries([]int{id})[id]; ok {
+			fname, at = c.BlockPath(e.Block), e.Offset+e.Length-1
+		}
+		b, _ := os.ReadFile(fname)
+		if at < 0 {
+			at = int64(len(b) - 1)
+		}
+		b[at] ^= 0xff
+		os.WriteFile(fname, b, 0644)
+		if x, err := s.Get(50); err != nil || x != 60 {
+			t.Errorf("%s: Get(50) = %d, %v, want 60", name, x, err)
+		}
+		if err := storage.Check(c.Storage, c.diskSlice[40]); err != nil {
+			t.Errorf("%s: element 50 not repaired: %v", name, err)
+		}
+		if !c.Packed(c.diskSlice[50]) {
+			os.Remove(c.Path(c.diskSlice[50]))
+			if x, err := s.Get(60); err != nil || x != 70 {
+				t.Errorf("%s: Get(60) = %d, %v, want 70", name, x, err)
+			}
+		}
+
+		// the deleted elements leave the replica
+		s.Delete(20, 10)
+		c.Wait()
+		m.Wait()
+		if files, _ := m.Files(); len(files) != 70 {
+			t.Errorf("%s: %d replica files, want 70", name, len(files))
+		}
+
+		// the renumbered files are still repaired
+		if err := s.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		if !c.Packed(c.diskSlice[0]) {
+			os.Remove(c.Path(c.diskSlice[0]))
+		}
+		if x, err := s.Get(10); err != nil || x != 20 {
+			t.Errorf("%s: Get(10) = %d, %v, want 20", name, x, err)
+		}
+
+		// Open copies the tail to a new replica
+		o, err := Open(make([]int, 0, 10), s.Dir(), append(opts, WithReplica(replica), WithStealLock())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		oc := o.(*config[int])
+		if files, _ := oc.Replica().Files(); len(files) != 70 {
+			t.Errorf("%s: %d replica files after Open, want 70", name, len(files))
+		}
+		o.Cleanup()
+	}
+}
+
+func TestAppendError(t *testing.T) {
+	s, err := New(make([]any, 0, 1), os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	// a chan can't be stored, the head holds the first element whatever it is
+	err = s.Append(1, "two", make(chan int), 4)
+	var ae *AppendError
+	if !errors.As(err, &ae) || ae.Index != 2 {
+		t.Fatalf("Append() = %v, want an AppendError at element 2", err)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d, want the 2 elements before the failed one", s.Len())
+	}
+	if err := s.Append(4); err != nil {
+		t.Fatal(err)
+	}
+	x, err := s.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(x) != "[1 two 4]" {
+		t.Errorf("Slice() = %v, want [1 two 4]", x)
+	}
+	if err := s.Append(make(chan int)); !errors.As(err, &ae) || ae.Index != 0 {
+		t.Errorf("Append() = %v, want an AppendError at element 0", err)
+	}
+}
+
+func TestSliceBounds(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	for _, ind := range [][]int{{4, 2}, {-1}, {0, 101}, {2, 5, 4}, {0, 5, 101}} {
+		if _, err := s.Slice(ind...); !errors.Is(err, IndexOutOfBounds) {
+			t.Errorf("Slice(%v) = %v, want IndexOutOfBounds", ind, err)
+		}
+	}
+	// the head and the disk tail
+	x, err := s.Slice(8, 12, 20)
+	if err != nil || fmt.Sprint(x) != "[8 9 10 11]" || cap(x) != 12 {
+		t.Errorf("Slice(8, 12, 20) = %v, cap %d, %v, want [8 9 10 11], cap 12", x, cap(x), err)
+	}
+
+	n, err := New(make([]int, 0, 3), os.TempDir(), WithNegativeIndices())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Cleanup()
+	n.Append(seq(0, 10)...)
+	for ind, want := range map[[2]int]string{
+		{-3, 10}: "[7 8 9]",
+		{0, -8}:  "[0 1]",
+		{-4, -2}: "[6 7]",
+	} {
+		if x, err := n.Slice(ind[0], ind[1]); err != nil || fmt.Sprint(x) != want {
+			t.Errorf("Slice(%d, %d) = %v, %v, want %s", ind[0], ind[1], x, err, want)
+		}
+	}
+	if _, err := n.Slice(-11); !errors.Is(err, IndexOutOfBounds) {
+		t.Errorf("Slice(-11) = %v, want IndexOutOfBounds", err)
+	}
+}
+
+func TestSliceInto(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	dst := make([]int, 30)
+	var got []int
+	for start := 0; ; {
+		n, err := s.SliceInto(dst, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		got = append(got, dst[:n]...)
+		start += n
+	}
+	if !slices.Equal(got, seq(0, 100)) {
+		t.Errorf("SliceInto() copied %v, want 0..99", got)
+	}
+	if n, err := s.SliceInto(nil, 5); n != 0 || err != nil {
+		t.Errorf("SliceInto(nil, 5) = %d, %v, want 0", n, err)
+	}
+	if _, err := s.SliceInto(dst, 101); !errors.Is(err, IndexOutOfBounds) {
+		t.Errorf("SliceInto(dst, 101) = %v, want IndexOutOfBounds", err)
+	}
 }
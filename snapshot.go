@@ -0,0 +1,178 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+var ErrReadOnly = errors.New("slicer is read only")
+
+func (c *config[T]) Snapshot() (Slicer[T], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+
+	s, err := c.copyTail(c.diskSlice)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := c.copyTo(s, 0, c.len(), options{
+		prefetch:    c.prefetch,
+		readWorkers: c.readWorkers,
+		fileMode:    c.fileMode,
+		dirMode:     c.dirMode,
+		durability:  c.durability,
+		readOnly:    true,
+	})
+	if err != nil {
+		// not a nil *config in a non nil Slicer
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (c *config[T]) Clone() (Slicer[T], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+
+	s, err := c.copyTail(c.diskSlice)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := c.copyTo(s, 0, c.len(), c.options)
+	if err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (c *config[T]) Split(index int) (Slicer[T], Slicer[T], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, nil, err
+	}
+	if index < 0 || index > c.len() {
+		return nil, nil, IndexOutOfBounds
+	}
+
+	cut := max(index-len(c.slice), 0)
+	var parts [2]*config[T]
+	for k, r := range [2][2]int{{0, index}, {index, c.len()}} {
+		ids := c.diskSlice[:cut]
+		if k == 1 {
+			ids = c.diskSlice[cut:]
+		}
+		s, err := c.copyTail(ids)
+		if err == nil {
+			parts[k], err = c.copyTo(s, r[0], r[1], c.options)
+		}
+		if err != nil {
+			if k == 1 {
+				parts[0].Cleanup()
+			}
+			return nil, nil, err
+		}
+	}
+	return parts[0], parts[1], nil
+}
+
+// copyTo creates a Slicer over s, which holds a copy of the disk files
+// of the elements [start, end), with a copy of their head part.
+// s is cleaned up on errors
+func (c *config[T]) copyTo(s *storage.Storage, start, end int, o options) (*config[T], error) {
+	h := len(c.slice)
+	slice := make([]T, min(end, h)-min(start, h), cap(c.slice))
+	copy(slice, c.slice[min(start, h):])
+	// the hooks, the totals of WithExpvar and the registration are about c
+	o.hooks = Hooks{}
+	o.name, o.expvar = "", ""
+	cl := newConfig(s, slice, o)
+	cl.diskSlice = append(cl.diskSlice, c.diskSlice[max(start-h, 0):max(end-h, 0)]...)
+	// the copied files keep their schema versions
+	cl.migrate = c.migrate
+	for _, id := range cl.diskSlice {
+		if v, ok := c.legacy[id]; ok {
+			if cl.legacy == nil {
+				cl.legacy = make(map[int]int)
+			}
+			cl.legacy[id] = v
+		}
+	}
+	cl.diskIndex = c.diskIndex
+	if cl.ttl > 0 {
+		cl.born = append(cl.born[:0], c.born[start:end]...)
+	}
+	// a part starting in the head has room for the front of its tail
+	err := cl.refill()
+	if err == nil {
+		err = cl.persist()
+	}
+	if err != nil {
+		cl.Cleanup()
+		return nil, err
+	}
+	return cl, nil
+}
+
+// copyTail creates a sibling directory holding the disk elements ids.
+// The files are hard linked when possible: Put replaces the files
+// rather than modifying them, so the copy is not affected.
+func (c *config[T]) copyTail(ids []int) (*storage.Storage, error) {
+	if err := c.sync(); err != nil {
+		return nil, err
+	}
+	s, err := storage.New(filepath.Dir(c.RootPath))
+	if err != nil {
+		return nil, err
+	}
+	if err := setup(s, c.options); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	file, _ := c.Modes()
+	// a block file is linked once for all its elements
+	packed := c.Entries(ids)
+	s.Adopt(packed)
+	s.AdoptChunks(c.ChunkCounts(ids))
+	linked := make(map[int]bool)
+	for _, id := range ids {
+		if e, ok := packed[id]; ok {
+			if !linked[e.Block] {
+				linked[e.Block] = true
+				if err := storage.LinkOrCopy(c.BlockPath(e.Block), s.BlockPath(e.Block), file); err != nil {
+					s.Cleanup()
+					return nil, err
+				}
+			}
+			continue
+		}
+		dst := s.Names(id)
+		for k, src := range c.Names(id) {
+			if err := storage.LinkOrCopy(src, dst[k], file); err != nil {
+				s.Cleanup()
+				return nil, err
+			}
+		}
+	}
+	if err := s.Recount(ids); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	if err := s.Replicate(ids...); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,118 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Cleanup()
+
+	s.Put(3, 300)
+	s.Put(50, 500)
+	s.Delete(60, 10)
+	s.Append(1000)
+
+	if snap.Len() != 100 {
+		t.Errorf("snapshot Len() = %d, want 100", snap.Len())
+	}
+	x, err := snap.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range x {
+		if x[i] != i {
+			t.Fatalf("snapshot element %d: %d, want %d", i, x[i], i)
+		}
+	}
+	if x, _ := s.Get(50); x != 500 {
+		t.Errorf("Get(50) = %d, want 500", x)
+	}
+
+	if err := snap.Append(1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("snapshot Append() = %v, want ErrReadOnly", err)
+	}
+	if err := snap.Put(50, 1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("snapshot Put() = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	cl, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Cleanup()
+
+	// drain both independently
+	s.Delete(0, 50)
+	cl.Put(60, -60)
+	cl.Delete(0, 10)
+
+	if s.Len() != 50 || cl.Len() != 90 {
+		t.Errorf("Len() = %d, %d, want 50, 90", s.Len(), cl.Len())
+	}
+	if x, _ := s.Get(10); x != 60 {
+		t.Errorf("original Get(10) = %d, want 60", x)
+	}
+	if x, _ := cl.Get(50); x != -60 {
+		t.Errorf("clone Get(50) = %d, want -60", x)
+	}
+	if err := cl.Append(100); err != nil {
+		t.Errorf("clone Append() = %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	for _, index := range []int{0, 5, 10, 40, 100} {
+		a, b, err := s.Split(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, part := range []struct {
+			s    Slicer[int]
+			want []int
+		}{{a, seq(0, index)}, {b, seq(index, 100)}} {
+			x, err := part.s.Slice()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(x, part.want) {
+				t.Errorf("Split(%d): %v, want %v", index, x, part.want)
+			}
+			// the head is full whenever there is a tail
+			if part.s.DiskLen() > 0 && part.s.MemLen() != 10 {
+				t.Errorf("Split(%d): %d elements in the head", index, part.s.MemLen())
+			}
+			if err := part.s.Verify(); err != nil {
+				t.Error(err)
+			}
+		}
+		// independent of each other and of s
+		a.Append(-1)
+		b.Put(b.Len()-1, -1)
+		if x, _ := s.Get(99); x != 99 {
+			t.Errorf("Get(99) = %d after a Put to a half", x)
+		}
+		a.Cleanup()
+		b.Cleanup()
+	}
+
+	if _, _, err := s.Split(101); err != IndexOutOfBounds {
+		t.Errorf("Split(101) = %v, want IndexOutOfBounds", err)
+	}
+}
@@ -0,0 +1,66 @@
+package slice_on_disk
+
+func (c *config[T]) DeleteSoft(start, n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly && start != 0 {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+	// like a journaled Delete, the files are kept
+	c.keep = &change[T]{kind: opDelete, start: start}
+	err := c.del(start, n)
+	ch := c.keep
+	c.keep = nil
+	// even if del failed half way, what it removed is hidden, not lost
+	if len(ch.removed) > 0 {
+		ch.n = len(ch.removed)
+		c.hidden = append(c.hidden, ch)
+	}
+	if err != nil {
+		return err
+	}
+	return c.persist()
+}
+
+func (c *config[T]) RestoreDeleted() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	c.forget()
+	// the latest first, so the earlier positions are right again
+	for len(c.hidden) > 0 {
+		ch := c.hidden[len(c.hidden)-1]
+		if err := c.restore(min(ch.start, c.len()), ch.removed); err != nil {
+			c.persist()
+			return err
+		}
+		c.hidden[len(c.hidden)-1] = nil
+		c.hidden = c.hidden[:len(c.hidden)-1]
+	}
+	c.wake()
+	return c.persist()
+}
+
+func (c *config[T]) PurgeDeleted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ch := range c.hidden {
+		c.drop(ch)
+	}
+	c.hidden = nil
+}
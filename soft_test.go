@@ -0,0 +1,53 @@
+package slice_on_disk
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDeleteSoft(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	// a consumer takes a batch across the head and the disk, the producer goes on
+	if err := s.DeleteSoft(0, 15); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := s.Get(0); s.Len() != 85 || x != 15 {
+		t.Errorf("Len(), Get(0) = %d, %d, want 85, 15", s.Len(), x)
+	}
+	s.Append(100)
+	// and rejects it
+	if err := s.RestoreDeleted(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Slice(); !slices.Equal(got, seq(0, 101)) {
+		t.Errorf("after RestoreDeleted: %v", got)
+	}
+
+	// the kept files survive a Compact
+	s.DeleteSoft(0, 15)
+	s.DeleteSoft(10, 5)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreDeleted(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Slice(); !slices.Equal(got, seq(0, 101)) {
+		t.Errorf("after Compact and RestoreDeleted: %v", got)
+	}
+
+	// and go with PurgeDeleted
+	s.DeleteSoft(0, 20)
+	s.PurgeDeleted()
+	if err := s.RestoreDeleted(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Slice(); !slices.Equal(got, seq(20, 101)) {
+		t.Errorf("after PurgeDeleted: %v", got)
+	}
+	if err := s.Verify(); err != nil {
+		t.Error(err)
+	}
+}
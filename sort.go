@@ -0,0 +1,51 @@
+package slice_on_disk
+
+// Sorter adapts a Slicer to sort.Interface. sort.Interface can't
+// return errors, so the first error of the disk operations is kept
+// and reported by Err. After an error the order is undefined.
+type Sorter[T any] struct {
+	s    Slicer[T]
+	less func(a, b T) bool
+	err  error
+}
+
+// SortAdapter returns a sort.Interface over s ordered by less,
+// e.g. sort.Sort(SortAdapter(s, less))
+func SortAdapter[T any](s Slicer[T], less func(a, b T) bool) *Sorter[T] {
+	return &Sorter[T]{s: s, less: less}
+}
+
+func (a *Sorter[T]) Len() int {
+	return a.s.Len()
+}
+
+func (a *Sorter[T]) Less(i, j int) bool {
+	x, err := a.s.Get(i)
+	if err != nil {
+		a.fail(err)
+		return false
+	}
+	y, err := a.s.Get(j)
+	if err != nil {
+		a.fail(err)
+		return false
+	}
+	return a.less(x, y)
+}
+
+func (a *Sorter[T]) Swap(i, j int) {
+	if err := a.s.Swap(i, j); err != nil {
+		a.fail(err)
+	}
+}
+
+// Err returns the first error of the disk operations
+func (a *Sorter[T]) Err() error {
+	return a.err
+}
+
+func (a *Sorter[T]) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
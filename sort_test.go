@@ -0,0 +1,28 @@
+package slice_on_disk
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+)
+
+func TestSortAdapter(t *testing.T) {
+	s, _ := New(make([]int, 0, 10), os.TempDir())
+	defer s.Cleanup()
+	for _, x := range rand.Perm(60) {
+		s.Append(x)
+	}
+
+	a := SortAdapter(s, func(a, b int) bool { return a < b })
+	sort.Sort(a)
+	if a.Err() != nil {
+		t.Fatal(a.Err())
+	}
+	x, _ := s.Slice()
+	for i := range x {
+		if x[i] != i {
+			t.Fatalf("element %d: %d, want %d", i, x[i], i)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package slice_on_disk
+
+import "github.com/yurizf/slice-on-disk/internal/storage"
+
+// Stats is a snapshot of the sizes of a Slicer, see Slicer.Stats
+type Stats struct {
+	Len     int
+	MemLen  int
+	DiskLen int
+	// the size of the disk files
+	DiskBytes int64
+	// the removals of disk files the cleaner hasn't done yet
+	CleanerBacklog int
+	// how much WithWriteRateLimit and WithIOPSLimit slowed the disk
+	// operations down
+	Throttle ThrottleStats
+	// the usage of the Slicer directory followed by the subdirs
+	// of WithRootPaths, in order
+	Roots []RootStats
+}
+
+// ThrottleStats tells how many disk operations are waiting for the
+// limits of the Slicer now and how long they waited in all
+type ThrottleStats = storage.ThrottleState
+
+// RootStats is the usage of a directory holding disk files
+type RootStats = storage.RootUsage
+
+func (c *config[T]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	return Stats{
+		Len:            c.len(),
+		MemLen:         len(c.slice),
+		DiskLen:        len(c.diskSlice),
+		DiskBytes:      c.Used(),
+		CleanerBacklog: c.Pending(),
+		Throttle:       c.Throttle(),
+		Roots:          c.Usage(),
+	}
+}
@@ -0,0 +1,79 @@
+package slice_on_disk
+
+import "sync"
+
+// tombstone marks the ids of the disk elements deleted from the middle
+// of the disk tail
+const tombstone = -1
+
+// tailLock is the mutex of a Slicer. A Delete in the middle of the disk tail
+// marks the ids with tombstones instead of moving the following ones
+// down: the next call locking the Slicer sweeps them first, at the latest
+// the one the Delete starts in the background, so the others never see
+// a tombstone
+type tailLock struct {
+	sync.Mutex
+	// sweep drops the tombstones, set by newConfig
+	sweep   func()
+	pending bool
+}
+
+func (l *tailLock) Lock() {
+	l.Mutex.Lock()
+	if l.pending {
+		l.pending = false
+		l.sweep()
+	}
+}
+
+// bury is del for the elements in the middle of the disk tail, marking
+// them with tombstones. Their files are removed by the sweep, so the
+// call costs the number of elements deleted
+func (c *config[T]) bury(start, n int) {
+	c.hooks.deleted(n)
+	c.collect(start, n)
+	c.deleted(start, n)
+	ids := c.diskSlice[start-len(c.slice) : start-len(c.slice)+n]
+	// the journal of WithUndo keeps the files
+	if c.keep == nil {
+		c.buried = append(c.buried, ids...)
+	}
+	for i := range ids {
+		ids[i] = tombstone
+	}
+	c.tombs += n
+	if !c.mu.pending {
+		c.mu.pending = true
+		go func() {
+			c.mu.Lock()
+			c.mu.Unlock()
+		}()
+	}
+}
+
+// buries tells whether Delete can bury the elements [start, start+n):
+// the ones in the middle of the disk tail, when nothing else the call
+// does reads the disk tail or the append times
+func (c *config[T]) buries(start, n int) bool {
+	return n > 0 && start > len(c.slice) && start+n < c.len() &&
+		!c.manifest && c.ttl <= 0
+}
+
+// sweep drops the tombstones from the disk tail and removes the files of
+// the buried elements
+func (c *config[T]) sweep() {
+	if c.tombs == 0 {
+		return
+	}
+	k := 0
+	for _, id := range c.diskSlice {
+		if id != tombstone {
+			c.diskSlice[k] = id
+			k++
+		}
+	}
+	c.diskSlice = c.diskSlice[:k]
+	c.tombs = 0
+	c.remove(c.buried...)
+	c.buried = nil
+}
@@ -0,0 +1,51 @@
+package slice_on_disk
+
+import "github.com/yurizf/slice-on-disk/internal/storage"
+
+// Span describes a disk operation traced WithTracer
+type Span struct {
+	// "read", "write" or "delete"
+	Op string
+	// the position of the element, -1 when the call doesn't name one,
+	// e.g. the spills, the background operations and the deletes
+	Index int
+	// the disk file of the element, as named in Dir
+	ID int
+	// the size of the element on the disk and where it lives: "file",
+	// "block" with WithPacking or "chunks" with WithChunking. Known by the
+	// end of the span
+	Bytes   int64
+	Backend string
+}
+
+// Tracer starts the spans around the disk operations of a Slicer, see
+// WithTracer. Start is called before an operation and returns the function
+// called after it with its error. It may be called concurrently, from the
+// background writers and the cleaner too. An OpenTelemetry adapter starts
+// a span named after span.Op and sets the attributes on end
+type Tracer interface {
+	Start(span *Span) (end func(err error))
+}
+
+// trace runs the disk operation fn about the element id at index in a span
+func trace(t Tracer, s *storage.Storage, op string, index, id int, fn func() error) error {
+	span := &Span{Op: op, Index: index, ID: id}
+	// a deleted element has no size left
+	span.Bytes, span.Backend = s.Describe(id)
+	end := t.Start(span)
+	err := fn()
+	if op != "delete" {
+		span.Bytes, span.Backend = s.Describe(id)
+	}
+	end(err)
+	return err
+}
+
+// traceRemovals makes the cleaner of s report the deletes to t
+func traceRemovals(t Tracer, s *storage.Storage) {
+	s.TraceRemovals(func(id int) func(error) {
+		span := &Span{Op: "delete", Index: -1, ID: id}
+		span.Bytes, span.Backend = s.Describe(id)
+		return t.Start(span)
+	})
+}
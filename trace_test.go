@@ -0,0 +1,56 @@
+package slice_on_disk
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+// spans records the ended spans
+type spans struct {
+	mu    sync.Mutex
+	ended []Span
+}
+
+func (s *spans) Start(span *Span) func(error) {
+	return func(err error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.ended = append(s.ended, *span)
+	}
+}
+
+func (s *spans) of(op string) []Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ended []Span
+	for _, span := range s.ended {
+		if span.Op == op {
+			ended = append(ended, span)
+		}
+	}
+	return ended
+}
+
+func TestTracer(t *testing.T) {
+	tr := &spans{}
+	s, _ := New(make([]int, 0, 10), os.TempDir(), WithTracer(tr), WithPacking(1024))
+	defer s.Cleanup()
+	for i := 0; i < 20; i++ {
+		s.Append(i)
+	}
+	if got := tr.of("write"); len(got) != 10 || got[0].Bytes == 0 || got[0].Backend != "block" {
+		t.Errorf("write spans: %+v", got)
+	}
+	s.Get(15)
+	if got := tr.of("read"); len(got) != 1 || got[0].Index != 15 || got[0].Bytes == 0 {
+		t.Errorf("read spans: %+v", got)
+	}
+	s.Delete(15, 2)
+	// the next call sweeps the tombstones, removing the files
+	s.Len()
+	s.(*config[int]).Wait()
+	if got := tr.of("delete"); len(got) != 2 || got[0].Bytes == 0 || got[0].Index != -1 {
+		t.Errorf("delete spans: %+v", got)
+	}
+}
@@ -0,0 +1,179 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+func (c *config[T]) ForEach(fn func(i int, v T) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return err
+	}
+	i := 0
+	return c.each(func(t T) error {
+		i++
+		return fn(i-1, t)
+	})
+}
+
+func (c *config[T]) Filter(pred func(v T) bool) (Slicer[T], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	return transform(c, func(t T) (T, bool) {
+		return t, pred(t)
+	})
+}
+
+func (c *config[T]) Partition(pred func(v T) bool) (Slicer[T], Slicer[T], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, nil, err
+	}
+	yes, err := derive[T, T](c)
+	if err != nil {
+		return nil, nil, err
+	}
+	no, err := derive[T, T](c)
+	if err != nil {
+		yes.Cleanup()
+		return nil, nil, err
+	}
+	err = c.each(func(t T) error {
+		if pred(t) {
+			return yes.Append(t)
+		}
+		return no.Append(t)
+	})
+	if err != nil {
+		yes.Cleanup()
+		no.Cleanup()
+		return nil, nil, err
+	}
+	return yes, no, nil
+}
+
+// MapTo returns a new Slicer holding fn of every element of s, in order.
+// The elements are streamed one at a time, like with ForEach, and the
+// result gets the settings of s and a sibling directory. s must be
+// created by New, Open or their derivatives. Cleanup the result when done
+func MapTo[T, U any](s Slicer[T], fn func(v T) U) (Slicer[U], error) {
+	c, ok := s.(*config[T])
+	if !ok {
+		return nil, fmt.Errorf("can't map a %T", s)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	return transform(c, func(t T) (U, bool) {
+		return fn(t), true
+	})
+}
+
+// GroupBy returns a new Slicer per key holding the elements of s with
+// that key, each in order, in one pass. The groups get the settings of s
+// and sibling directories, like with MapTo. Cleanup them when done
+func GroupBy[T any, K comparable](s Slicer[T], key func(v T) K) (map[K]Slicer[T], error) {
+	c, ok := s.(*config[T])
+	if !ok {
+		return nil, fmt.Errorf("can't group a %T", s)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.expire(); err != nil {
+		return nil, err
+	}
+	groups := make(map[K]Slicer[T])
+	err := c.each(func(t T) error {
+		k := key(t)
+		g, ok := groups[k]
+		if !ok {
+			d, err := derive[T, T](c)
+			if err != nil {
+				return err
+			}
+			groups[k], g = d, d
+		}
+		return g.Append(t)
+	})
+	if err != nil {
+		for _, g := range groups {
+			g.Cleanup()
+		}
+		return nil, err
+	}
+	return groups, nil
+}
+
+// transform creates a Slicer with the settings of c in a sibling
+// directory and appends to it fn of the elements of c that fn keeps.
+// c.mu must be held
+func transform[T, U any](c *config[T], fn func(t T) (U, bool)) (Slicer[U], error) {
+	dst, err := derive[T, U](c)
+	if err != nil {
+		return nil, err
+	}
+	err = c.each(func(t T) error {
+		if u, ok := fn(t); ok {
+			return dst.Append(u)
+		}
+		return nil
+	})
+	if err != nil {
+		dst.Cleanup()
+		return nil, err
+	}
+	return dst, nil
+}
+
+// derive creates an empty Slicer with the settings of c and the same
+// head capacity in a sibling directory. c.mu must be held
+func derive[T, U any](c *config[T]) (*config[U], error) {
+	s, err := storage.New(filepath.Dir(c.RootPath))
+	if err != nil {
+		return nil, err
+	}
+	o := c.options
+	o.readOnly = false
+	// the hooks, the migration, the sample and the registration are
+	// about c
+	o.hooks = Hooks{}
+	o.name, o.expvar = "", ""
+	o.migrate, o.sample = nil, nil
+	if err := probe[U](o); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	if err := setup(s, o); err != nil {
+		s.Cleanup()
+		return nil, err
+	}
+	return newConfig(s, make([]U, 0, cap(c.slice)), o), nil
+}
+
+// Reduce folds the elements of s in order into an accumulator
+// starting with initial, e.g. the sum of the elements with
+// Reduce(s, 0, func(acc, v int) int { return acc + v }).
+// The elements are streamed one at a time, like with ForEach
+func Reduce[T, U any](s Slicer[T], initial U, fn func(acc U, v T) U) (U, error) {
+	acc := initial
+	err := s.ForEach(func(_ int, v T) error {
+		acc = fn(acc, v)
+		return nil
+	})
+	return acc, err
+}
@@ -0,0 +1,143 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	n := 0
+	err := s.ForEach(func(i int, v int) error {
+		if i != v {
+			t.Errorf("element %d: %d", i, v)
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != 100 {
+		t.Errorf("ForEach() = %v after %d elements, want 100", err, n)
+	}
+
+	stop := errors.New("stop")
+	n = 0
+	err = s.ForEach(func(i int, v int) error {
+		n++
+		if i == 50 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 51 {
+		t.Errorf("ForEach() = %v after %d elements, want stop after 51", err, n)
+	}
+}
+
+func TestFilterMap(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	even, err := s.Filter(func(v int) bool { return v%2 == 0 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer even.Cleanup()
+	strs, err := MapTo(even, strconv.Itoa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strs.Cleanup()
+
+	want := make([]string, 0, 50)
+	for i := 0; i < 100; i += 2 {
+		want = append(want, strconv.Itoa(i))
+	}
+	got, err := strs.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Slice() = %v, want %v", got, want)
+	}
+	// the results spill like the source
+	if strs.MemLen() != 10 || strs.DiskLen() != 40 {
+		t.Errorf("MemLen(), DiskLen() = %d, %d, want 10, 40", strs.MemLen(), strs.DiskLen())
+	}
+	if even.Dir() == s.Dir() || strs.Dir() == even.Dir() {
+		t.Errorf("the results share a directory")
+	}
+}
+
+func TestReduce(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	sum, err := Reduce(s, 0, func(acc, v int) int { return acc + v })
+	if err != nil || sum != 4950 {
+		t.Errorf("Reduce() = %d, %v, want 4950", sum, err)
+	}
+	longest, err := Reduce(s, "", func(acc string, v int) string {
+		if x := strconv.Itoa(v); len(x) > len(acc) {
+			return x
+		}
+		return acc
+	})
+	if err != nil || longest != "10" {
+		t.Errorf("Reduce() = %q, %v, want 10", longest, err)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	small, large, err := s.Partition(func(v int) bool { return v < 30 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer small.Cleanup()
+	defer large.Cleanup()
+	for _, part := range []struct {
+		s    Slicer[int]
+		want []int
+	}{{small, seq(0, 30)}, {large, seq(30, 100)}} {
+		x, err := part.s.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(x) != fmt.Sprint(part.want) {
+			t.Errorf("Partition(): %v, want %v", x, part.want)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+
+	groups, err := GroupBy(s, func(v int) int { return v % 3 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("%d groups, want 3", len(groups))
+	}
+	for k, g := range groups {
+		defer g.Cleanup()
+		var want []int
+		for i := k; i < 100; i += 3 {
+			want = append(want, i)
+		}
+		x, err := g.Slice()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(x) != fmt.Sprint(want) {
+			t.Errorf("group %d: %v, want %v", k, x, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoUndo is returned by Undo when fewer calls are journaled,
+// see WithUndo
+var ErrNoUndo = errors.New("not enough calls to undo")
+
+// change is a journaled call: Append, Put or Delete at start, with the
+// elements it removed. The disk files of the removed elements are kept
+// until the change leaves the journal
+type change[T any] struct {
+	kind    int
+	start   int
+	n       int
+	removed []ref[T]
+}
+
+// track starts journaling a call: the elements removed by del
+// are kept in c.keep instead of being deleted
+func (c *config[T]) track(kind, start int) {
+	if c.undo > 0 {
+		c.keep = &change[T]{kind: kind, start: start}
+	}
+}
+
+// record adds the tracked call to the journal, if it is still tracked
+func (c *config[T]) record(n int) {
+	ch := c.keep
+	if ch == nil {
+		return
+	}
+	c.keep = nil
+	ch.n = n
+	c.journal = append(c.journal, ch)
+	if len(c.journal) > c.undo {
+		c.drop(c.journal[0])
+		c.journal[0] = nil
+		c.journal = c.journal[1:]
+	}
+}
+
+// untrack stops journaling a failed call. If it removed elements
+// before failing, the journal is emptied
+func (c *config[T]) untrack() {
+	if c.keep != nil && len(c.keep.removed) > 0 {
+		c.forget()
+	}
+	c.keep = nil
+}
+
+// forget empties the journal after a call that can't be undone,
+// which makes the earlier ones impossible to undo too
+func (c *config[T]) forget() {
+	if c.wal && !c.logging {
+		c.unlogged = true
+	}
+	if c.keep != nil {
+		c.drop(c.keep)
+		c.keep = nil
+	}
+	for _, ch := range c.journal {
+		c.drop(ch)
+	}
+	c.journal = nil
+}
+
+// drop deletes the kept files of the removed elements of ch
+func (c *config[T]) drop(ch *change[T]) {
+	var ids []int
+	for _, r := range ch.removed {
+		if r.id >= 0 {
+			ids = append(ids, r.id)
+		}
+	}
+	c.remove(ids...)
+}
+
+// discard removes the files of deleted elements, unless they are kept
+// for the journal
+func (c *config[T]) discard(ids ...int) {
+	if c.keep == nil {
+		c.remove(ids...)
+	}
+}
+
+// collect keeps the elements del is about to remove for the journal
+func (c *config[T]) collect(start, n int) {
+	if c.keep == nil {
+		return
+	}
+	h := len(c.slice)
+	for i := start; i < start+n; i++ {
+		r := ref[T]{id: -1}
+		if i < h {
+			r.v = c.slice[i]
+		} else {
+			r.id = c.diskSlice[i-h]
+		}
+		if c.ttl > 0 {
+			r.born = c.born[i]
+		}
+		c.keep.removed = append(c.keep.removed, r)
+	}
+}
+
+func (c *config[T]) Undo(n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.appendOnly {
+		return ErrAppendOnly
+	}
+	if err := c.expire(); err != nil {
+		return err
+	}
+	if n < 0 || n > len(c.journal) {
+		return fmt.Errorf("%w: %d, %d are journaled", ErrNoUndo, n, len(c.journal))
+	}
+	for ; n > 0; n-- {
+		ch := c.journal[len(c.journal)-1]
+		if err := c.revert(ch); err != nil {
+			// the journal doesn't match the elements anymore
+			c.forget()
+			c.persist()
+			return err
+		}
+		c.journal = c.journal[:len(c.journal)-1]
+	}
+	return c.persist()
+}
+
+// revert undoes the last journaled call ch
+func (c *config[T]) revert(ch *change[T]) error {
+	switch ch.kind {
+	case opAppend:
+		if err := c.del(c.len()-ch.n, ch.n); err != nil {
+			return err
+		}
+		// what WithMaxLen evicted from the front
+		return c.restore(0, ch.removed)
+
+	case opPut:
+		r, h := ch.removed[0], len(c.slice)
+		switch {
+		case ch.start >= h && r.id >= 0:
+			c.remove(c.diskSlice[ch.start-h])
+			c.diskSlice[ch.start-h] = r.id
+		case ch.start >= h:
+			id, err := c.overwrite(c.diskSlice[ch.start-h], r.v)
+			if err != nil {
+				return err
+			}
+			c.diskSlice[ch.start-h] = id
+		case r.id >= 0:
+			t, err := c.read(r.id)
+			if err != nil {
+				return fmt.Errorf(GetError, err)
+			}
+			c.slice[ch.start] = t
+			c.remove(r.id)
+		default:
+			c.slice[ch.start] = r.v
+		}
+		return nil
+	}
+	return c.restore(ch.start, ch.removed)
+}
+
+// restore inserts the removed elements back at start, reusing their files
+func (c *config[T]) restore(start int, removed []ref[T]) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	n := c.len()
+	plan := make([]int, 0, n+len(removed))
+	for p := 0; p < start; p++ {
+		plan = append(plan, p)
+	}
+	for k := range removed {
+		plan = append(plan, ^k)
+	}
+	for p := start; p < n; p++ {
+		plan = append(plan, p)
+	}
+	written, err := c.rebuild(plan, removed)
+	if err != nil {
+		return err
+	}
+	c.hooks.appended(len(removed))
+	c.hooks.spilled(written)
+	return nil
+}
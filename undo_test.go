@@ -0,0 +1,102 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestUndo(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithUndo(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 100)...)
+
+	// the states before each call
+	var states [][]int
+	step := func(f func() error) {
+		got, _ := s.Slice()
+		states = append(states, got)
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	step(func() error { return s.Delete(5, 20) })
+	step(func() error { return s.Put(50, -50) })
+	step(func() error { return s.Put(2, -2) })
+	step(func() error { return s.Append(100, 101) })
+	step(func() error { return s.Truncate(30) })
+
+	if err := s.Undo(6); !errors.Is(err, ErrNoUndo) {
+		t.Errorf("Undo(6) = %v, want ErrNoUndo", err)
+	}
+	for _, n := range []int{1, 2, 2} {
+		if err := s.Undo(n); err != nil {
+			t.Fatal(err)
+		}
+		want := states[len(states)-n]
+		states = states[:len(states)-n]
+		if got, _ := s.Slice(); !slices.Equal(got, want) {
+			t.Errorf("Undo(%d): %v, want %v", n, got, want)
+		}
+	}
+	if err := s.Undo(1); !errors.Is(err, ErrNoUndo) {
+		t.Errorf("Undo(1) with an empty journal = %v, want ErrNoUndo", err)
+	}
+	if err := s.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUndoEvictions(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithUndo(5), WithMaxLen(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 30)...)
+
+	// evicts 0..14, Undo brings them back
+	s.Append(seq(30, 45)...)
+	if err := s.Undo(1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Slice(); !slices.Equal(got, seq(0, 30)) {
+		t.Errorf("Undo(1): %v, want %v", got, seq(0, 30))
+	}
+
+	// a call that isn't journaled empties the journal
+	s.Delete(0, 1)
+	s.Swap(0, 20)
+	if err := s.Undo(1); !errors.Is(err, ErrNoUndo) {
+		t.Errorf("Undo(1) after Swap = %v, want ErrNoUndo", err)
+	}
+}
+
+func TestUndoCompact(t *testing.T) {
+	s, err := New(make([]int, 0, 2), os.TempDir(), WithUndo(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 6)...)
+	s.Delete(3, 2)
+	s.Put(3, 50)
+
+	// the files of the deleted and overwritten elements are renumbered too
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Undo(2); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Slice(); !slices.Equal(got, seq(0, 6)) {
+		t.Errorf("Slice() after Undo = %v, want %v", got, seq(0, 6))
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+}
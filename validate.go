@@ -0,0 +1,159 @@
+package slice_on_disk
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// IssueKind classifies the issues found by Validate
+type IssueKind int
+
+const (
+	// an element of the disk tail can't be read or decoded
+	IssueUnreadable IssueKind = iota
+	// a file of the directory belongs to no element
+	IssueUnreferenced
+	// the bookkeeping of the elements doesn't add up
+	IssueCount
+	// the manifest doesn't match the disk tail
+	IssueManifest
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueUnreadable:
+		return "unreadable"
+	case IssueUnreferenced:
+		return "unreferenced"
+	case IssueCount:
+		return "count"
+	case IssueManifest:
+		return "manifest"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Issue is a broken invariant found by Validate
+type Issue struct {
+	Kind IssueKind
+	// the element, -1 when the issue is not about one
+	Index int
+	// the file, if the issue is about one
+	Path   string
+	Detail string
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	b.WriteString(i.Kind.String())
+	if i.Index >= 0 {
+		fmt.Fprintf(&b, ": element %d", i.Index)
+	}
+	if i.Path != "" {
+		fmt.Fprintf(&b, ": %s", i.Path)
+	}
+	fmt.Fprintf(&b, ": %s", i.Detail)
+	return b.String()
+}
+
+// ValidationError is the report of Validate, one issue per broken invariant
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for k, i := range e.Issues {
+		lines[k] = i.String()
+	}
+	return fmt.Sprintf("%d issues: %s", len(e.Issues), strings.Join(lines, "; "))
+}
+
+func (c *config[T]) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var issues []Issue
+	report := func(kind IssueKind, index int, path, format string, args ...any) {
+		issues = append(issues, Issue{Kind: kind, Index: index, Path: path, Detail: fmt.Sprintf(format, args...)})
+	}
+	// the queued writes and removals are not on the disk yet
+	if err := c.sync(); err != nil {
+		report(IssueUnreadable, -1, "", "background write: %v", err)
+	}
+	c.settle()
+
+	h := len(c.slice)
+	if len(c.diskSlice) > 0 && h < cap(c.slice) {
+		report(IssueCount, -1, "", "the head holds %d of %d elements with %d on the disk", h, cap(c.slice), len(c.diskSlice))
+	}
+	if c.ttl > 0 && len(c.born) != c.len() {
+		report(IssueCount, -1, "", "%d append times for %d elements", len(c.born), c.len())
+	}
+	seen := make(map[int]int, len(c.diskSlice))
+	for i, id := range c.diskSlice {
+		if j, ok := seen[id]; ok {
+			report(IssueCount, h+i, c.Path(id), "shares its file with element %d", j)
+		}
+		seen[id] = h + i
+		if int64(id) >= c.diskIndex {
+			report(IssueCount, h+i, c.Path(id), "id %d beyond the next one, %d", id, c.diskIndex)
+		}
+		if _, err := c.decode(context.Background(), id); err != nil {
+			report(IssueUnreadable, h+i, c.Path(id), "%v", err)
+		}
+	}
+
+	ids := c.fileIDs()
+	live := c.liveFiles(ids)
+	files, err := c.Files()
+	if err != nil {
+		report(IssueUnreferenced, -1, c.RootPath, "%v", err)
+	}
+	for _, f := range files {
+		if !live[f] {
+			report(IssueUnreferenced, -1, f, "not a file of an element")
+		}
+	}
+	var size int64
+	for _, id := range ids {
+		n, _ := c.Describe(id)
+		size += n
+	}
+	if used := c.Used(); used != size {
+		report(IssueCount, -1, "", "%d bytes accounted, the elements take %d", used, size)
+	}
+
+	if c.manifest {
+		fname := filepath.Join(c.RootPath, manifestName)
+		m, err := loadManifest(c.RootPath)
+		if err == nil {
+			_, err = m.adopt(storage.CodecOf[T](), c.options)
+		}
+		switch {
+		case err != nil:
+			report(IssueManifest, -1, fname, "%v", err)
+		case c.wal:
+			// a checkpoint, its files must be kept
+			for _, id := range append(m.Head, m.DiskSlice...) {
+				if err := c.Exists(id); err != nil {
+					report(IssueManifest, -1, fname, "the checkpoint lists a missing element: %v", err)
+				}
+			}
+		case !slices.Equal(m.DiskSlice, c.diskSlice):
+			report(IssueManifest, -1, fname, "lists %d elements, the disk tail has %d", len(m.DiskSlice), len(c.diskSlice))
+		case m.Shards != c.Shards():
+			report(IssueManifest, -1, fname, "%d shards, the directory has %d", m.Shards, c.Shards())
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
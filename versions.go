@@ -0,0 +1,53 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoVersion is returned by GetVersion when the element
+// has fewer previous versions, see WithVersions
+var ErrNoVersion = errors.New("no such version")
+
+func (c *config[T]) GetVersion(index, k int) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	if err := c.expire(); err != nil {
+		return zero, err
+	}
+	if k == 0 {
+		return c.get(index)
+	}
+	if index < 0 || index >= c.len() {
+		return zero, IndexOutOfBounds
+	}
+	var past []int
+	if index >= len(c.slice) {
+		past = c.past[c.diskSlice[index-len(c.slice)]]
+	}
+	if k < 0 || k > len(past) {
+		return zero, fmt.Errorf("%w: %d of element %d, %d are kept", ErrNoVersion, k, index, len(past))
+	}
+	t, err := c.read(past[k-1])
+	if err != nil {
+		return zero, fmt.Errorf(GetError, err)
+	}
+	return t, nil
+}
+
+// version makes the file old the latest previous version
+// of the element now in the file id
+func (c *config[T]) version(old, id int) {
+	if c.past == nil {
+		c.past = make(map[int][]int)
+	}
+	past := append([]int{old}, c.past[old]...)
+	delete(c.past, old)
+	if len(past) > c.versions {
+		c.remove(past[c.versions:]...)
+		past = past[:c.versions]
+	}
+	c.past[id] = past
+}
@@ -0,0 +1,50 @@
+package slice_on_disk
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestGetVersion(t *testing.T) {
+	s, err := New(make([]int, 0, 10), os.TempDir(), WithVersions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Cleanup()
+	s.Append(seq(0, 30)...)
+
+	for _, v := range []int{-1, -2, -3} {
+		if err := s.Put(20, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(index int, want ...int) {
+		t.Helper()
+		for k, w := range want {
+			if x, err := s.GetVersion(index, k); err != nil || x != w {
+				t.Errorf("GetVersion(%d, %d) = %d, %v, want %d", index, k, x, err, w)
+			}
+		}
+		if _, err := s.GetVersion(index, len(want)); !errors.Is(err, ErrNoVersion) {
+			t.Errorf("GetVersion(%d, %d) = %v, want ErrNoVersion", index, len(want), err)
+		}
+	}
+	// the original 20 is beyond the two kept versions
+	check(20, -3, -2, -1)
+	check(21, 21)
+	check(5, 5)
+
+	// the versions follow the element
+	s.Swap(20, 25)
+	check(25, -3, -2, -1)
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	check(25, -3, -2, -1)
+	s.Delete(0, 10)
+	check(15, -3, -2, -1)
+	if err := s.Verify(); err != nil {
+		t.Error(err)
+	}
+}
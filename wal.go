@@ -0,0 +1,113 @@
+package slice_on_disk
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/yurizf/slice-on-disk/internal/storage"
+)
+
+// the file in the Slicer directory that logs the calls since the
+// last checkpoint, see WithWAL
+const walName = "wal"
+
+// walCheckpoint is the number of logged calls after which the next one
+// writes a checkpoint first, so the log and its replay stay short
+const walCheckpoint = 1024
+
+// walRecord is a logged call: an Append, a Put or a Delete at Start,
+// or the calls of a committed Batch, which Open applies like a Batch
+type walRecord[T any] struct {
+	Seq int64
+	Ops []walOp[T]
+}
+
+type walOp[T any] struct {
+	Kind     int
+	Start, N int
+	Values   []T
+}
+
+// logged appends the call ops to the log with WithWAL, then runs fn
+// applying it. A call that fails may be applied in part, or logged
+// in part: the next change writes a checkpoint
+func (c *config[T]) logged(ops []op[T], fn func() error) error {
+	if !c.wal || c.logging {
+		return fn()
+	}
+	if c.unlogged || c.walRecords >= walCheckpoint {
+		if err := c.checkpoint(); err != nil {
+			return err
+		}
+	}
+	if c.walFile == nil {
+		f, err := c.OpenWAL(filepath.Join(c.RootPath, walName))
+		if err != nil {
+			return fmt.Errorf("could not open the log: %w", err)
+		}
+		c.walFile = f
+	}
+	r := walRecord[T]{Seq: c.walSeq + 1, Ops: make([]walOp[T], len(ops))}
+	for k, o := range ops {
+		r.Ops[k] = walOp[T]{Kind: o.kind, Start: o.start, N: o.n, Values: o.values}
+	}
+	if err := storage.AppendRecord(c.walFile, r); err != nil {
+		c.unlogged = true
+		return fmt.Errorf("could not log the call: %w", err)
+	}
+	c.walSeq++
+	c.walRecords++
+
+	c.logging = true
+	err := fn()
+	c.logging = false
+	if err != nil {
+		c.unlogged = true
+	}
+	return err
+}
+
+// replay applies the calls of the log made after the checkpoint seq.
+// The calls that fail again are skipped: they failed before the crash
+// too, the ones applied in part were followed by a checkpoint
+func (c *config[T]) replay(seq int64) {
+	fname := filepath.Join(c.RootPath, walName)
+	records, err := storage.Records[walRecord[T]](fname)
+	if err != nil {
+		log.Printf("error reading %s, replaying %d calls: %s", fname, len(records), err.Error())
+	}
+	c.walSeq = seq
+	c.logging = true
+	for _, r := range records {
+		if r.Seq <= c.walSeq {
+			continue
+		}
+		ops := make([]op[T], len(r.Ops))
+		for k, o := range r.Ops {
+			ops[k] = op[T]{kind: o.Kind, start: o.Start, n: o.N, values: o.Values}
+		}
+		if err := c.commit(ops); err != nil {
+			log.Printf("skipping call %d of %s: %s", r.Seq, fname, err.Error())
+		}
+		c.walSeq = r.Seq
+	}
+	c.logging = false
+	// the replayed calls go to a checkpoint
+	c.unlogged = true
+}
+
+// retain keeps the files of the checkpoint among ids until the next
+// one, see WithWAL, and returns the others
+func (c *config[T]) retain(ids []int) []int {
+	var rest []int
+	for _, id := range ids {
+		if c.durable[id] {
+			delete(c.durable, id)
+			c.retained = append(c.retained, id)
+		} else {
+			rest = append(rest, id)
+		}
+	}
+	return rest
+}
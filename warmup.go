@@ -0,0 +1,75 @@
+package slice_on_disk
+
+import "github.com/yurizf/slice-on-disk/internal/storage"
+
+func (c *config[T]) Warmup(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.expire() != nil {
+		return
+	}
+	n = min(max(n, 0), len(c.diskSlice))
+	c.warmups++
+	ids := make([]int, 0, n)
+	for _, id := range c.diskSlice[:n] {
+		if _, ok := c.pinned[id]; ok {
+			continue
+		}
+		if _, ok := c.warm[id]; ok {
+			continue
+		}
+		// migrated on every read, see WithSchema
+		if _, ok := c.legacy[id]; ok {
+			continue
+		}
+		if c.warming == nil {
+			c.warming = make(map[int]int)
+		}
+		c.warming[id] = c.warmups
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		go c.warmup(ids, c.warmups)
+	}
+}
+
+// warmup decodes the elements without holding the lock.
+// An element written or deleted meanwhile is no longer in c.warming,
+// or belongs to a later call, and its stale copy is dropped
+func (c *config[T]) warmup(ids []int, call int) {
+	for _, id := range ids {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		var t T
+		var err error
+		ok := false
+		if c.async != nil {
+			t, ok = c.async.read(id)
+		}
+		if !ok && c.tracer != nil {
+			err = trace(c.tracer, c.Storage, "read", -1, id, func() error {
+				t, err = storage.Read[T](c.Storage, id)
+				return err
+			})
+		} else if !ok {
+			t, err = storage.Read[T](c.Storage, id)
+		}
+
+		c.mu.Lock()
+		if c.warming[id] == call {
+			if err == nil {
+				if c.warm == nil {
+					c.warm = make(map[int]T)
+				}
+				c.warm[id] = t
+			}
+			delete(c.warming, id)
+		}
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,39 @@
+package slice_on_disk
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	s := intSlicer()
+	defer s.Cleanup()
+	c := s.(*config[int])
+
+	s.Warmup(20)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if p, _ := s.Placement(29); p == Cached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the warmup didn't finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if p, _ := s.Placement(30); p != OnDisk {
+		t.Errorf("Placement(30) = %s, want disk", p)
+	}
+
+	// served from the cache
+	os.Remove(c.Path(c.diskSlice[5]))
+	if x, err := s.Get(15); err != nil || x != 15 {
+		t.Errorf("Get(15) = %d, %v, want 15", x, err)
+	}
+	// and kept up to date
+	s.Put(16, -16)
+	if x, err := s.Get(16); err != nil || x != -16 {
+		t.Errorf("Get(16) = %d, %v, want -16", x, err)
+	}
+}